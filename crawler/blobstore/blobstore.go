@@ -0,0 +1,148 @@
+// Package blobstore persists the raw content of fetched pages keyed by link
+// ID and fetch time. Stored content can be served back to the crawler via
+// a Replayer to reindex pages without refetching them.
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when no stored content matches a lookup.
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is a stored copy of the content of a page.
+type Blob struct {
+	LinkID      uuid.UUID
+	URL         string
+	FetchedAt   time.Time
+	ContentType string
+	Content     []byte
+}
+
+// Store is implemented by objects that can store page content.
+type Store interface {
+	// Put stores a new version of the content of b.LinkID.
+	Put(b *Blob) error
+
+	// Get returns the latest version of the content of linkID fetched at
+	// or before at. A zero at selects the latest version.
+	Get(linkID uuid.UUID, at time.Time) (*Blob, error)
+
+	// GetByURL is like Get but looks up the content by page URL.
+	GetByURL(url string, at time.Time) (*Blob, error)
+
+	// Versions returns the fetch times of the stored versions of linkID
+	// in ascending order.
+	Versions(linkID uuid.UUID) ([]time.Time, error)
+
+	// Prune removes the content that has expired according to the
+	// retention policy of the store.
+	Prune() error
+
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// RetentionPolicy controls how long stored content is kept.
+type RetentionPolicy struct {
+	// The max number of versions kept per link. Zero means no limit.
+	MaxVersions int
+
+	// The max age of a version. Zero means no limit. The latest version
+	// of each link is always kept.
+	MaxAge time.Duration
+}
+
+// expired returns the indices of the versions (sorted in ascending order)
+// that should be removed according to the policy.
+func (rp RetentionPolicy) expired(versions []time.Time, now time.Time) []int {
+	var out []int
+	for i := range versions[:len(versions)-1] {
+		tooMany := rp.MaxVersions > 0 && len(versions)-i > rp.MaxVersions
+		tooOld := rp.MaxAge > 0 && now.Sub(versions[i]) > rp.MaxAge
+		if tooMany || tooOld {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// selectVersion returns the index of the latest version (sorted in ascending
+// order) fetched at or before at or -1 if there is none.
+func selectVersion(versions []time.Time, at time.Time) int {
+	if at.IsZero() {
+		return len(versions) - 1
+	}
+	return sort.Search(len(versions), func(i int) bool { return versions[i].After(at) }) - 1
+}
+
+// encodeBlob serializes b as a gzip member containing a MIME-style header
+// followed by the content.
+func encodeBlob(w io.Writer, b *Blob) error {
+	gz := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gz)
+	fmt.Fprintf(bw, "Link-Id: %s\r\n", b.LinkID)
+	fmt.Fprintf(bw, "Url: %s\r\n", b.URL)
+	fmt.Fprintf(bw, "Fetched-At: %d\r\n", b.FetchedAt.UnixNano())
+	fmt.Fprintf(bw, "Content-Type: %s\r\n", b.ContentType)
+	fmt.Fprintf(bw, "Content-Length: %d\r\n\r\n", len(b.Content))
+	_, _ = bw.Write(b.Content)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// decodeBlob deserializes a blob written by encodeBlob.
+func decodeBlob(r io.Reader) (*Blob, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	gz.Multistream(false)
+
+	tr := textproto.NewReader(bufio.NewReader(gz))
+	header, err := tr.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("malformed blob header: %w", err)
+	}
+
+	b := &Blob{
+		URL:         header.Get("Url"),
+		ContentType: header.Get("Content-Type"),
+	}
+	if b.LinkID, err = uuid.Parse(header.Get("Link-Id")); err != nil {
+		return nil, fmt.Errorf("malformed blob link ID: %w", err)
+	}
+	fetchedAt, err := strconv.ParseInt(header.Get("Fetched-At"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed blob fetch time: %w", err)
+	}
+	b.FetchedAt = time.Unix(0, fetchedAt).UTC()
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("malformed blob length: %w", err)
+	}
+
+	b.Content = make([]byte, length)
+	if _, err = io.ReadFull(tr.R, b.Content); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// decodeBlobBytes is a convenience wrapper for decodeBlob.
+func decodeBlobBytes(data []byte) (*Blob, error) {
+	return decodeBlob(bytes.NewReader(data))
+}
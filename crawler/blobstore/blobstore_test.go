@@ -0,0 +1,281 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// storeFactory opens a store in dir.
+type storeFactory func(t *testing.T, dir string, rp RetentionPolicy, clock func() time.Time) Store
+
+func openFSStore(t *testing.T, dir string, rp RetentionPolicy, clock func() time.Time) Store {
+	s, err := NewFSStore(FSConfig{Dir: dir, Retention: rp, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func openSegmentStore(t *testing.T, dir string, rp RetentionPolicy, clock func() time.Time) Store {
+	s, err := NewSegmentStore(SegmentConfig{Dir: dir, Retention: rp, Clock: clock, MaxSegmentSize: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+var storeFactories = []struct {
+	name string
+	open storeFactory
+}{
+	{"fs", openFSStore},
+	{"segment", openSegmentStore},
+}
+
+func TestStoreGet(t *testing.T) {
+	for _, factory := range storeFactories {
+		s := factory.open(t, t.TempDir(), RetentionPolicy{}, func() time.Time { return testEpoch })
+		linkID := uuid.New()
+		for i, content := range []string{"v1", "v2", "v3"} {
+			mustPut(t, s, &Blob{
+				LinkID:      linkID,
+				URL:         "https://example.com/",
+				FetchedAt:   testEpoch.Add(time.Duration(i) * time.Hour),
+				ContentType: "text/html",
+				Content:     []byte(content),
+			})
+		}
+
+		specs := []struct {
+			descr string
+			at    time.Time
+			exp   string
+		}{
+			{"latest", time.Time{}, "v3"},
+			{"exact version", testEpoch.Add(time.Hour), "v2"},
+			{"between versions", testEpoch.Add(90 * time.Minute), "v2"},
+			{"after all versions", testEpoch.Add(time.Hour * 24), "v3"},
+		}
+		for _, spec := range specs {
+			b, err := s.Get(linkID, spec.at)
+			if err != nil {
+				t.Errorf("%s: %s: unexpected error %v", factory.name, spec.descr, err)
+			} else if string(b.Content) != spec.exp {
+				t.Errorf("%s: %s: expected %q; got %q", factory.name, spec.descr, spec.exp, b.Content)
+			}
+		}
+
+		b, err := s.GetByURL("https://example.com/", testEpoch)
+		if err != nil {
+			t.Fatalf("%s: %v", factory.name, err)
+		}
+		exp := &Blob{LinkID: linkID, URL: "https://example.com/", FetchedAt: testEpoch, ContentType: "text/html", Content: []byte("v1")}
+		if !reflect.DeepEqual(b, exp) {
+			t.Errorf("%s: expected %+v; got %+v", factory.name, exp, b)
+		}
+
+		if _, err = s.Get(linkID, testEpoch.Add(-time.Second)); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound before the first version; got %v", factory.name, err)
+		}
+		if _, err = s.Get(uuid.New(), time.Time{}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound for an unknown link; got %v", factory.name, err)
+		}
+		if _, err = s.GetByURL("https://example.com/missing", time.Time{}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound for an unknown URL; got %v", factory.name, err)
+		}
+
+		versions, err := s.Versions(linkID)
+		if err != nil || len(versions) != 3 || !versions[0].Equal(testEpoch) || !versions[2].Equal(testEpoch.Add(2*time.Hour)) {
+			t.Errorf("%s: unexpected versions %v, %v", factory.name, versions, err)
+		}
+
+		if err = s.Close(); err != nil {
+			t.Errorf("%s: %v", factory.name, err)
+		}
+	}
+}
+
+func TestStoreReopen(t *testing.T) {
+	for _, factory := range storeFactories {
+		dir := t.TempDir()
+		s := factory.open(t, dir, RetentionPolicy{}, time.Now)
+		a, b := uuid.New(), uuid.New()
+		mustPut(t, s, &Blob{LinkID: a, URL: "https://example.com/a", FetchedAt: testEpoch, Content: []byte(strings.Repeat("a", 200))})
+		mustPut(t, s, &Blob{LinkID: b, URL: "https://example.com/b", FetchedAt: testEpoch, Content: []byte("b")})
+		mustPut(t, s, &Blob{LinkID: a, URL: "https://example.com/a", FetchedAt: testEpoch.Add(time.Hour), Content: []byte("a2")})
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		s = factory.open(t, dir, RetentionPolicy{}, time.Now)
+		if got, err := s.GetByURL("https://example.com/a", time.Time{}); err != nil || string(got.Content) != "a2" {
+			t.Errorf("%s: unexpected blob after reopening %v, %v", factory.name, got, err)
+		}
+		if got, err := s.GetByURL("https://example.com/b", time.Time{}); err != nil || string(got.Content) != "b" {
+			t.Errorf("%s: unexpected blob after reopening %v, %v", factory.name, got, err)
+		}
+		if versions, _ := s.Versions(a); len(versions) != 2 {
+			t.Errorf("%s: expected 2 versions after reopening; got %v", factory.name, versions)
+		}
+		_ = s.Close()
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	for _, factory := range storeFactories {
+		now := testEpoch.Add(10 * 24 * time.Hour)
+		s := factory.open(t, t.TempDir(), RetentionPolicy{MaxVersions: 3, MaxAge: 48 * time.Hour}, func() time.Time { return now })
+
+		stale, fresh := uuid.New(), uuid.New()
+		for day := 0; day < 5; day++ {
+			mustPut(t, s, &Blob{LinkID: stale, URL: "https://example.com/stale", FetchedAt: testEpoch.Add(time.Duration(day) * 24 * time.Hour)})
+			mustPut(t, s, &Blob{LinkID: fresh, URL: "https://example.com/fresh", FetchedAt: now.Add(-time.Duration(4-day) * time.Hour)})
+		}
+
+		// Put only enforces MaxVersions.
+		if versions, _ := s.Versions(stale); len(versions) != 3 {
+			t.Errorf("%s: expected 3 versions after Put; got %d", factory.name, len(versions))
+		}
+
+		// Prune drops old versions but always keeps the latest one.
+		if err := s.Prune(); err != nil {
+			t.Fatal(err)
+		}
+		if versions, _ := s.Versions(stale); len(versions) != 1 || !versions[0].Equal(testEpoch.Add(4*24*time.Hour)) {
+			t.Errorf("%s: expected only the latest stale version to be kept; got %v", factory.name, versions)
+		}
+		if versions, _ := s.Versions(fresh); len(versions) != 3 {
+			t.Errorf("%s: expected 3 fresh versions to be kept; got %v", factory.name, versions)
+		}
+		_ = s.Close()
+	}
+}
+
+func TestSegmentStorePrunesSegments(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewSegmentStore(SegmentConfig{Dir: dir, MaxSegmentSize: 1, Retention: RetentionPolicy{MaxVersions: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	linkID := uuid.New()
+	for i := 0; i < 3; i++ {
+		mustPut(t, s, &Blob{LinkID: linkID, URL: "https://example.com/", FetchedAt: testEpoch.Add(time.Duration(i) * time.Hour), Content: []byte{byte(i)}})
+	}
+	if got := countFiles(t, dir, "seg-*.dat"); got != 3 {
+		t.Fatalf("expected a segment per blob; got %d", got)
+	}
+
+	if err = s.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if got := countFiles(t, dir, "seg-*.dat"); got != 1 {
+		t.Errorf("expected the segments without live versions to be removed; got %d", got)
+	}
+	if got := countFiles(t, dir, "seg-*.idx"); got != 1 {
+		t.Errorf("expected the index files to be removed with their segments; got %d", got)
+	}
+	if b, err := s.Get(linkID, time.Time{}); err != nil || b.Content[0] != 2 {
+		t.Errorf("unexpected latest blob %v, %v", b, err)
+	}
+}
+
+func TestSegmentStoreIgnoresTruncatedIndexLines(t *testing.T) {
+	dir := t.TempDir()
+	s := openSegmentStore(t, dir, RetentionPolicy{}, time.Now)
+	linkID := uuid.New()
+	mustPut(t, s, &Blob{LinkID: linkID, URL: "https://example.com/", FetchedAt: testEpoch, Content: []byte("ok")})
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idxFiles, _ := filepath.Glob(filepath.Join(dir, "seg-*.idx"))
+	f, err := os.OpenFile(idxFiles[0], os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(uuid.New().String() + " 123")
+	_ = f.Close()
+
+	s = openSegmentStore(t, dir, RetentionPolicy{}, time.Now)
+	defer func() { _ = s.Close() }()
+	if b, err := s.Get(linkID, time.Time{}); err != nil || string(b.Content) != "ok" {
+		t.Errorf("unexpected blob %v, %v", b, err)
+	}
+}
+
+func TestRetentionPolicyExpired(t *testing.T) {
+	now := testEpoch.Add(10 * time.Hour)
+	versions := []time.Time{testEpoch, testEpoch.Add(4 * time.Hour), testEpoch.Add(8 * time.Hour), testEpoch.Add(9 * time.Hour)}
+
+	specs := []struct {
+		descr string
+		rp    RetentionPolicy
+		exp   []int
+	}{
+		{"no limits", RetentionPolicy{}, nil},
+		{"max versions", RetentionPolicy{MaxVersions: 2}, []int{0, 1}},
+		{"max age", RetentionPolicy{MaxAge: 5 * time.Hour}, []int{0, 1}},
+		{"latest is kept", RetentionPolicy{MaxAge: time.Minute}, []int{0, 1, 2}},
+		{"both", RetentionPolicy{MaxVersions: 3, MaxAge: 8 * time.Hour}, []int{0}},
+	}
+
+	for _, spec := range specs {
+		if got := spec.rp.expired(versions, now); !reflect.DeepEqual(got, spec.exp) {
+			t.Errorf("%s: expected %v; got %v", spec.descr, spec.exp, got)
+		}
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	s := openFSStore(t, t.TempDir(), RetentionPolicy{}, time.Now)
+	mustPut(t, s, &Blob{LinkID: uuid.New(), URL: "https://example.com/", FetchedAt: testEpoch, ContentType: "text/html", Content: []byte("old")})
+	mustPut(t, s, &Blob{LinkID: uuid.New(), URL: "https://example.com/other", FetchedAt: testEpoch, Content: []byte("other")})
+
+	r := NewReplayer(s, testEpoch.Add(time.Hour))
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	res, err := r.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || string(body) != "old" || res.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("unexpected response %d %v %q", res.StatusCode, res.Header, body)
+	}
+	if res.Header.Get("Date") != testEpoch.Format(http.TimeFormat) || res.Request != req {
+		t.Errorf("unexpected response metadata %v", res.Header)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://example.com/missing", nil)
+	if _, err = r.Do(req); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound; got %v", err)
+	}
+}
+
+func mustPut(t *testing.T, s Store, b *Blob) {
+	t.Helper()
+	if err := s.Put(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countFiles(t *testing.T, dir, pattern string) int {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(matches)
+}
@@ -0,0 +1,260 @@
+package blobstore
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	blobFileExt = ".blob.gz"
+	urlFileName = "url"
+)
+
+// FSConfig encapsulates the settings for an FSStore.
+type FSConfig struct {
+	// The directory where content is stored. Required.
+	Dir string
+
+	// The retention policy applied by Put (for MaxVersions) and Prune.
+	Retention RetentionPolicy
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// FSStore stores each version of a page as a separate gzip-compressed file
+// in a per-link directory. It is safe for concurrent use.
+type FSStore struct {
+	cfg FSConfig
+
+	mu   sync.RWMutex
+	urls map[string]uuid.UUID
+}
+
+// NewFSStore returns a new FSStore using the provided config. The URLs of
+// previously stored links are loaded from cfg.Dir.
+func NewFSStore(cfg FSConfig) (*FSStore, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("blobstore: directory not specified")
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+
+	s := &FSStore{cfg: cfg, urls: make(map[string]uuid.UUID)}
+	urlFiles, err := filepath.Glob(filepath.Join(cfg.Dir, "*", "*", urlFileName))
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+	for _, urlFile := range urlFiles {
+		linkID, err := uuid.Parse(filepath.Base(filepath.Dir(urlFile)))
+		if err != nil {
+			continue
+		}
+		url, err := os.ReadFile(urlFile)
+		if err != nil {
+			return nil, fmt.Errorf("blobstore: %w", err)
+		}
+		s.urls[string(url)] = linkID
+	}
+
+	return s, nil
+}
+
+// Put stores a new version of the content of b.LinkID.
+func (s *FSStore) Put(b *Blob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkDir := s.linkDir(b.LinkID)
+	if err := os.MkdirAll(linkDir, 0755); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(linkDir, urlFileName), func(f *os.File) error {
+		_, err := f.WriteString(b.URL)
+		return err
+	}); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+
+	blobFile := filepath.Join(linkDir, strconv.FormatInt(b.FetchedAt.UnixNano(), 10)+blobFileExt)
+	if err := writeFileAtomic(blobFile, func(f *os.File) error { return encodeBlob(f, b) }); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+	s.urls[b.URL] = b.LinkID
+
+	if s.cfg.Retention.MaxVersions > 0 {
+		if err := s.prune(b.LinkID, RetentionPolicy{MaxVersions: s.cfg.Retention.MaxVersions}); err != nil {
+			return fmt.Errorf("blobstore: put: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get returns the latest version of the content of linkID fetched at or
+// before at. A zero at selects the latest version.
+func (s *FSStore) Get(linkID uuid.UUID, at time.Time) (*Blob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions, err := s.versions(linkID)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get: %w", err)
+	}
+	idx := selectVersion(versions, at)
+	if idx < 0 {
+		return nil, fmt.Errorf("blobstore: get %s: %w", linkID, ErrNotFound)
+	}
+
+	f, err := os.Open(s.blobPath(linkID, versions[idx]))
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	b, err := decodeBlob(f)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get: %w", err)
+	}
+	return b, nil
+}
+
+// GetByURL is like Get but looks up the content by page URL.
+func (s *FSStore) GetByURL(url string, at time.Time) (*Blob, error) {
+	s.mu.RLock()
+	linkID, found := s.urls[url]
+	s.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("blobstore: get %s: %w", url, ErrNotFound)
+	}
+	return s.Get(linkID, at)
+}
+
+// Versions returns the fetch times of the stored versions of linkID in
+// ascending order.
+func (s *FSStore) Versions(linkID uuid.UUID) ([]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions, err := s.versions(linkID)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: versions: %w", err)
+	}
+	return versions, nil
+}
+
+// Prune removes the versions that have expired according to the retention
+// policy.
+func (s *FSStore) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	linkDirs, err := filepath.Glob(filepath.Join(s.cfg.Dir, "*", "*"))
+	if err != nil {
+		return fmt.Errorf("blobstore: prune: %w", err)
+	}
+	for _, linkDir := range linkDirs {
+		linkID, err := uuid.Parse(filepath.Base(linkDir))
+		if err != nil {
+			continue
+		}
+		if err = s.prune(linkID, s.cfg.Retention); err != nil {
+			return fmt.Errorf("blobstore: prune: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; FSStore does not keep any files open.
+func (s *FSStore) Close() error {
+	return nil
+}
+
+// prune removes the versions of linkID that have expired according to rp.
+func (s *FSStore) prune(linkID uuid.UUID, rp RetentionPolicy) error {
+	versions, err := s.versions(linkID)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+
+	for _, idx := range rp.expired(versions, s.cfg.Clock()) {
+		if err = os.Remove(s.blobPath(linkID, versions[idx])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// versions returns the sorted fetch times of the stored versions of linkID.
+func (s *FSStore) versions(linkID uuid.UUID) ([]time.Time, error) {
+	entries, err := os.ReadDir(s.linkDir(linkID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var versions []time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, blobFileExt) {
+			continue
+		}
+		nanos, err := strconv.ParseInt(strings.TrimSuffix(name, blobFileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, time.Unix(0, nanos).UTC())
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Before(versions[j]) })
+	return versions, nil
+}
+
+// linkDir returns the directory for the content of linkID. Directories are
+// sharded by the first two characters of the ID to keep them small.
+func (s *FSStore) linkDir(linkID uuid.UUID) string {
+	id := linkID.String()
+	return filepath.Join(s.cfg.Dir, id[:2], id)
+}
+
+func (s *FSStore) blobPath(linkID uuid.UUID, fetchedAt time.Time) string {
+	return filepath.Join(s.linkDir(linkID), strconv.FormatInt(fetchedAt.UnixNano(), 10)+blobFileExt)
+}
+
+// writeFileAtomic writes a file via a temporary file so that readers never
+// observe partially written contents.
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if err = write(tmp); err == nil {
+		err = tmp.Close()
+	} else {
+		_ = tmp.Close()
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), fs.FileMode(0644))
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package blobstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Replayer serves the content kept in a Store as HTTP responses. It
+// implements the crawler.URLGetter interface so that stored pages can be
+// reprocessed and reindexed without refetching them. Only successful
+// responses are stored, so redirects are not replayed; pages are served
+// under the URL they were stored with.
+type Replayer struct {
+	store Store
+	at    time.Time
+}
+
+// NewReplayer returns a Replayer that serves the latest content in store
+// that was fetched at or before at. A zero at selects the latest version.
+func NewReplayer(store Store, at time.Time) *Replayer {
+	return &Replayer{store: store, at: at}
+}
+
+// Do serves the stored content for req.
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	b, err := r.store.GetByURL(req.URL.String(), r.at)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore replay: %w", err)
+	}
+
+	header := make(http.Header)
+	if b.ContentType != "" {
+		header.Set("Content-Type", b.ContentType)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(b.Content)))
+	header.Set("Date", b.FetchedAt.UTC().Format(http.TimeFormat))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(b.Content)),
+		ContentLength: int64(len(b.Content)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,398 @@
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultMaxSegmentSize = 256 << 20
+
+// SegmentConfig encapsulates the settings for a SegmentStore.
+type SegmentConfig struct {
+	// The directory where segments are stored. Required.
+	Dir string
+
+	// The size at which the active segment is closed and a new one is
+	// started. Defaults to 256MiB.
+	MaxSegmentSize int64
+
+	// The retention policy applied by Put (for MaxVersions) and Prune.
+	Retention RetentionPolicy
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *SegmentConfig) validate() error {
+	if cfg.Dir == "" {
+		return fmt.Errorf("directory not specified")
+	}
+	if cfg.MaxSegmentSize <= 0 {
+		cfg.MaxSegmentSize = defaultMaxSegmentSize
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// SegmentStore appends gzip-compressed versions to large segment files
+// and keeps an in-memory index of their locations, which is rebuilt on
+// startup from the index file written alongside each segment. Compared to
+// FSStore it needs far fewer files and inodes for large crawls.
+//
+// Expired versions are dropped from the index immediately, but their disk
+// space is only reclaimed by Prune once every version in a segment has
+// expired. SegmentStore is safe for concurrent use.
+type SegmentStore struct {
+	cfg SegmentConfig
+
+	mu       sync.RWMutex
+	index    map[uuid.UUID][]segmentEntry
+	urls     map[string]uuid.UUID
+	segments []int
+
+	activeID   int
+	activeSize int64
+	activeData *os.File
+	activeIdx  *os.File
+}
+
+// segmentEntry locates a stored version.
+type segmentEntry struct {
+	fetchedAt time.Time
+	segment   int
+	offset    int64
+	length    int64
+}
+
+// NewSegmentStore returns a new SegmentStore using the provided config. The
+// index files of existing segments in cfg.Dir are loaded and new content is
+// written to a fresh segment.
+func NewSegmentStore(cfg SegmentConfig) (*SegmentStore, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("blobstore: config validation failed: %w", err)
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+
+	s := &SegmentStore{
+		cfg:   cfg,
+		index: make(map[uuid.UUID][]segmentEntry),
+		urls:  make(map[string]uuid.UUID),
+	}
+
+	idxFiles, err := filepath.Glob(filepath.Join(cfg.Dir, "seg-*.idx"))
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+	for _, idxFile := range idxFiles {
+		segID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(idxFile), "seg-"), ".idx"))
+		if err != nil {
+			continue
+		}
+		if err = s.loadIndex(idxFile, segID); err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, segID)
+		if segID > s.activeID {
+			s.activeID = segID
+		}
+	}
+	sort.Ints(s.segments)
+
+	for linkID := range s.index {
+		s.sortVersions(linkID)
+		s.applyRetention(linkID, cfg.Retention)
+	}
+
+	if err = s.rotate(); err != nil {
+		return nil, fmt.Errorf("blobstore: %w", err)
+	}
+	return s, nil
+}
+
+// Put stores a new version of the content of b.LinkID.
+func (s *SegmentStore) Put(b *Blob) error {
+	var buf bytes.Buffer
+	if err := encodeBlob(&buf, b); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.activeSize > 0 && s.activeSize+int64(buf.Len()) > s.cfg.MaxSegmentSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("blobstore: put: %w", err)
+		}
+	}
+
+	entry := segmentEntry{
+		fetchedAt: b.FetchedAt.UTC(),
+		segment:   s.activeID,
+		offset:    s.activeSize,
+		length:    int64(buf.Len()),
+	}
+	if _, err := s.activeData.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+	s.activeSize += entry.length
+
+	if _, err := fmt.Fprintf(s.activeIdx, "%s %d %d %d %s\n", b.LinkID, entry.fetchedAt.UnixNano(), entry.offset, entry.length, b.URL); err != nil {
+		return fmt.Errorf("blobstore: put: %w", err)
+	}
+
+	s.index[b.LinkID] = append(s.index[b.LinkID], entry)
+	s.urls[b.URL] = b.LinkID
+	s.sortVersions(b.LinkID)
+	s.applyRetention(b.LinkID, RetentionPolicy{MaxVersions: s.cfg.Retention.MaxVersions})
+	return nil
+}
+
+// Get returns the latest version of the content of linkID fetched at or
+// before at. A zero at selects the latest version.
+func (s *SegmentStore) Get(linkID uuid.UUID, at time.Time) (*Blob, error) {
+	s.mu.RLock()
+	entries := s.index[linkID]
+	versions := make([]time.Time, len(entries))
+	for i, entry := range entries {
+		versions[i] = entry.fetchedAt
+	}
+	idx := selectVersion(versions, at)
+	var entry segmentEntry
+	if idx >= 0 {
+		entry = entries[idx]
+	}
+	s.mu.RUnlock()
+
+	if idx < 0 {
+		return nil, fmt.Errorf("blobstore: get %s: %w", linkID, ErrNotFound)
+	}
+
+	data, err := s.readEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get: %w", err)
+	}
+	b, err := decodeBlobBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("blobstore: get: %w", err)
+	}
+	return b, nil
+}
+
+// GetByURL is like Get but looks up the content by page URL.
+func (s *SegmentStore) GetByURL(url string, at time.Time) (*Blob, error) {
+	s.mu.RLock()
+	linkID, found := s.urls[url]
+	s.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("blobstore: get %s: %w", url, ErrNotFound)
+	}
+	return s.Get(linkID, at)
+}
+
+// Versions returns the fetch times of the stored versions of linkID in
+// ascending order.
+func (s *SegmentStore) Versions(linkID uuid.UUID) ([]time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var versions []time.Time
+	for _, entry := range s.index[linkID] {
+		versions = append(versions, entry.fetchedAt)
+	}
+	return versions, nil
+}
+
+// Prune removes the versions that have expired according to the retention
+// policy and deletes the segments that no longer contain any live versions.
+func (s *SegmentStore) Prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := make(map[int]bool)
+	for linkID := range s.index {
+		s.applyRetention(linkID, s.cfg.Retention)
+		for _, entry := range s.index[linkID] {
+			live[entry.segment] = true
+		}
+	}
+
+	var retained []int
+	for _, segID := range s.segments {
+		if live[segID] || segID == s.activeID {
+			retained = append(retained, segID)
+			continue
+		}
+		for _, path := range []string{s.segmentPath(segID, ".dat"), s.segmentPath(segID, ".idx")} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("blobstore: prune: %w", err)
+			}
+		}
+	}
+	s.segments = retained
+	return nil
+}
+
+// Close closes the active segment.
+func (s *SegmentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.activeData == nil {
+		return nil
+	}
+	err := s.activeData.Close()
+	if idxErr := s.activeIdx.Close(); err == nil {
+		err = idxErr
+	}
+	s.activeData, s.activeIdx = nil, nil
+	if err != nil {
+		return fmt.Errorf("blobstore: %w", err)
+	}
+	return nil
+}
+
+// rotate closes the active segment (if any) and starts a new one.
+func (s *SegmentStore) rotate() error {
+	if s.activeData != nil {
+		if err := s.activeData.Close(); err != nil {
+			return err
+		}
+		if err := s.activeIdx.Close(); err != nil {
+			return err
+		}
+	}
+
+	segID := s.activeID + 1
+	data, err := os.OpenFile(s.segmentPath(segID, ".dat"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	idx, err := os.OpenFile(s.segmentPath(segID, ".idx"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		_ = data.Close()
+		return err
+	}
+
+	s.activeID, s.activeSize = segID, 0
+	s.activeData, s.activeIdx = data, idx
+	s.segments = append(s.segments, segID)
+	return nil
+}
+
+// applyRetention drops the entries of linkID that have expired according
+// to rp from the index.
+func (s *SegmentStore) applyRetention(linkID uuid.UUID, rp RetentionPolicy) {
+	entries := s.index[linkID]
+	if len(entries) == 0 {
+		return
+	}
+	versions := make([]time.Time, len(entries))
+	for i, entry := range entries {
+		versions[i] = entry.fetchedAt
+	}
+
+	expired := rp.expired(versions, s.cfg.Clock())
+	if len(expired) == 0 {
+		return
+	}
+	drop := make(map[int]bool, len(expired))
+	for _, idx := range expired {
+		drop[idx] = true
+	}
+	retained := entries[:0]
+	for i, entry := range entries {
+		if !drop[i] {
+			retained = append(retained, entry)
+		}
+	}
+	s.index[linkID] = retained
+}
+
+// sortVersions sorts the entries of linkID by ascending fetch time.
+func (s *SegmentStore) sortVersions(linkID uuid.UUID) {
+	entries := s.index[linkID]
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].fetchedAt.Before(entries[j].fetchedAt) })
+}
+
+// readEntry reads the encoded blob described by entry.
+func (s *SegmentStore) readEntry(entry segmentEntry) ([]byte, error) {
+	f, err := os.Open(s.segmentPath(entry.segment, ".dat"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	data := make([]byte, entry.length)
+	if _, err = f.ReadAt(data, entry.offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// loadIndex adds the entries of a segment index file to the index.
+func (s *SegmentStore) loadIndex(path string, segID int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("blobstore: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.SplitN(scanner.Text(), " ", 5)
+		if len(fields) != 5 {
+			// A truncated trailing line is left behind if the process
+			// crashed while writing; the blob it refers to is ignored.
+			continue
+		}
+
+		linkID, err := uuid.Parse(fields[0])
+		if err != nil {
+			return fmt.Errorf("blobstore: %s:%d: %w", path, lineNum, err)
+		}
+		nanos, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("blobstore: %s:%d: %w", path, lineNum, err)
+		}
+		offset, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("blobstore: %s:%d: %w", path, lineNum, err)
+		}
+		length, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return fmt.Errorf("blobstore: %s:%d: %w", path, lineNum, err)
+		}
+
+		s.index[linkID] = append(s.index[linkID], segmentEntry{
+			fetchedAt: time.Unix(0, nanos).UTC(),
+			segment:   segID,
+			offset:    offset,
+			length:    length,
+		})
+		s.urls[fields[4]] = linkID
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("blobstore: %s: %w", path, err)
+	}
+	return nil
+}
+
+func (s *SegmentStore) segmentPath(segID int, ext string) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("seg-%08d%s", segID, ext))
+}
@@ -0,0 +1,61 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"unicode/utf8"
+
+	"github.com/iamleson98/go-search/pipeline"
+	"golang.org/x/net/html/charset"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// charsetDecoder detects the character encoding of fetched pages and
+// transcodes their content to UTF-8 so that the downstream processors can
+// treat RawContent as UTF-8.
+type charsetDecoder struct{}
+
+func newCharsetDecoder() *charsetDecoder {
+	return &charsetDecoder{}
+}
+
+func (d *charsetDecoder) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
+
+	content := payload.RawContent.Bytes()
+
+	// The encoding is determined (in order of precedence) from the BOM,
+	// the Content-Type header and <meta charset> or http-equiv elements.
+	enc, name, certain := charset.DetermineEncoding(content, payload.Header.Get("Content-Type"))
+
+	// Without an authoritative declaration, content that is valid UTF-8
+	// is far more likely to be UTF-8 than anything else. This also covers
+	// pages whose first KB (which is all DetermineEncoding inspects) is
+	// plain ASCII.
+	if !certain && name != "utf-8" && utf8.Valid(content) {
+		payload.Charset = "utf-8"
+		return payload, nil
+	}
+
+	payload.Charset = name
+	if name == "utf-8" {
+		if bytes.HasPrefix(content, utf8BOM) {
+			payload.RawContent.Next(len(utf8BOM))
+		}
+		return payload, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		// Undecodable content cannot be indexed meaningfully.
+		return nil, nil
+	}
+
+	payload.RawContent.Reset()
+	_, _ = payload.RawContent.Write(decoded)
+	return payload, nil
+}
@@ -0,0 +1,40 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/iamleson98/go-search/crawler/blobstore"
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+// contentStoreWriter stores a copy of the raw content of each changed page
+// keyed by the ID of the page's link and its fetch time.
+type contentStoreWriter struct {
+	store ContentStore
+}
+
+func newContentStoreWriter(store ContentStore) *contentStoreWriter {
+	return &contentStoreWriter{
+		store: store,
+	}
+}
+
+func (w *contentStoreWriter) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
+
+	err := w.store.Put(&blobstore.Blob{
+		LinkID:      payload.LinkID,
+		URL:         payload.URL,
+		FetchedAt:   payload.FetchedAt,
+		ContentType: payload.Header.Get("Content-Type"),
+		Content:     append([]byte(nil), payload.RawContent.Bytes()...),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
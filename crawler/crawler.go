@@ -2,17 +2,28 @@ package crawler
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/crawler/blobstore"
+	"github.com/iamleson98/go-search/crawler/frontier"
+	"github.com/iamleson98/go-search/crawler/politeness"
+	"github.com/iamleson98/go-search/crawler/simhash"
+	"github.com/iamleson98/go-search/crawler/traps"
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/crawler/warc"
 	"github.com/iamleson98/go-search/linkgraph/graph"
 	"github.com/iamleson98/go-search/pipeline"
 	"github.com/iamleson98/go-search/textindexer/index"
 )
 
+// URLGetter is implemented by objects that can perform HTTP requests.
+// *http.Client satisfies this interface.
 type URLGetter interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
 // PrivateNetworkDetector is implemented by objects that can detect whether a host resolves to a private network address
@@ -20,15 +31,95 @@ type PrivateNetworkDetector interface {
 	IsPrivate(host string) (bool, error)
 }
 
+// RobotsPolicy is implemented by objects that can decide whether a URL may
+// be crawled according to the robots.txt rules of its host.
+type RobotsPolicy interface {
+	// IsAllowed checks the robots.txt rules for u, retrieving them if needed.
+	IsAllowed(ctx context.Context, u *url.URL) (bool, error)
+
+	// IsAllowedCached checks u against already retrieved rules. The known
+	// return value is false if no rules are available for the host of u.
+	IsAllowedCached(u *url.URL) (allowed, known bool)
+
+	// CrawlDelay returns the min delay between requests to the host of u.
+	CrawlDelay(u *url.URL) time.Duration
+}
+
+// ScopePolicy is implemented by objects that can decide whether a URL is
+// within the scope of the crawl. *scope.Policy satisfies this interface.
+type ScopePolicy interface {
+	// InScope returns true if u, which is distance links away from the
+	// crawl seeds, should be crawled.
+	InScope(u *url.URL, distance int) bool
+}
+
+// TrapDetector is implemented by objects that can detect crawler traps.
+// *traps.Detector satisfies this interface.
+type TrapDetector interface {
+	// Check returns the reason why u looks like a crawler trap or
+	// traps.ReasonNone.
+	Check(u *url.URL) traps.Reason
+
+	// Admit is like Check but also charges newly discovered URLs against
+	// the per-pass URL budget of their host. URLs rejected with
+	// traps.ReasonHostBudget may still be linked to if they are already
+	// in the link graph.
+	Admit(u *url.URL) traps.Reason
+
+	// EndPass returns the hosts that were trapped during the current
+	// pass and starts a new one.
+	EndPass() []traps.HostReport
+}
+
+// FrontierRecorder is implemented by objects that schedule recrawls based on
+// the outcome of fetching links. *frontier.Frontier satisfies this
+// interface.
+type FrontierRecorder interface {
+	Record(o frontier.Outcome) error
+}
+
+// ArchiveWriter is implemented by objects that can archive fetched HTTP
+// exchanges. *warc.Writer satisfies this interface.
+type ArchiveWriter interface {
+	WriteExchange(ex *warc.Exchange) error
+}
+
+// ContentStore is implemented by objects that can store the raw content of
+// fetched pages. blobstore.Store implementations satisfy this interface.
+type ContentStore interface {
+	Put(b *blobstore.Blob) error
+}
+
+// FeedRegistry is implemented by objects that keep track of the feeds
+// discovered by the crawler. *FeedPoller satisfies this interface.
+type FeedRegistry interface {
+	AddFeed(link *graph.Link)
+}
+
 type Graph interface {
 	UpsertLink(link *graph.Link) error
+	FindLinkByURL(url string) (*graph.Link, error)
+	UpdateFetchStatus(id uuid.UUID, status *graph.FetchStatus) error
 	UpsertEdge(edge *graph.Edge) error
 	RemoveStaleEdges(fromID uuid.UUID, updateBefore time.Time) error
+	UpsertRedirect(redirect *graph.Redirect) error
+	TransferInboundEdges(fromID, toID uuid.UUID) error
+	InboundAnchorText(dstID uuid.UUID, limit int) ([]string, error)
 }
 
 // Indexer is implemented by objects that can index the contents of web-pages retrieved by the crawler pipeline.
 type Indexer interface {
 	Index(doc *index.Document) error
+	UpdateAnchorText(linkID uuid.UUID, anchors []string) error
+	Delete(linkID uuid.UUID) error
+}
+
+// FingerprintStore is implemented by objects that can persist content
+// fingerprints and look up near-duplicate pages.
+type FingerprintStore interface {
+	Upsert(e simhash.Entry) error
+	Lookup(linkID uuid.UUID) (simhash.Entry, bool, error)
+	FindNearDuplicate(exclude uuid.UUID, fp uint64, maxDistance int) (uuid.UUID, bool, error)
 }
 
 type Config struct {
@@ -37,41 +128,146 @@ type Config struct {
 	Graph                  Graph
 	Indexer                Indexer
 	FetchWorkers           int
+
+	// FetchTimeout bounds each request, including reading the response
+	// body. Defaults to 30s.
+	FetchTimeout time.Duration
+
+	// MaxBodySize caps the (decoded) size of response bodies. Defaults to
+	// 8 MiB. Pages whose body exceeds the limit are skipped unless
+	// TruncateOversizedBodies is set, in which case they are truncated.
+	MaxBodySize             int64
+	TruncateOversizedBodies bool
+
+	// ReprocessUnchanged disables conditional requests and content hash
+	// comparisons so that every retrieved page is fully processed. This
+	// is useful when replaying archived crawls (see warc.Replayer) after
+	// changing the extraction logic.
+	ReprocessUnchanged bool
+
+	// Links whose fetch fails (due to a request error or a non-2xx
+	// status) are not retried before FailureBackoff has elapsed. The
+	// backoff doubles with every consecutive failure up to
+	// MaxFailureBackoff. Defaults to 1h and 7 days respectively. If a
+	// Frontier is configured, it schedules the retries instead (see
+	// frontier.Config.RetryBackoff) and the backoff is not enforced.
+	// Fetch statuses are not tracked if ReprocessUnchanged is set.
+	FailureBackoff    time.Duration
+	MaxFailureBackoff time.Duration
+
+	// The number of consecutive failed fetches after which a link is
+	// marked as dead and removed from the index. Defaults to 5.
+	MaxFailures int
+
+	// An optional RobotsPolicy for honoring robots.txt rules. If not
+	// specified, the crawler does not perform any robots.txt checks.
+	Robots RobotsPolicy
+
+	// An optional ScopePolicy for restricting the crawl to a subset of
+	// the web. If not specified, all URLs are in scope.
+	Scope ScopePolicy
+
+	// An optional TrapDetector for keeping crawler traps (e.g. infinite
+	// calendars or faceted navigation) out of the link graph. Each Crawl
+	// call is treated as a separate pass.
+	Traps TrapDetector
+
+	// An optional FrontierRecorder that is notified of the outcome of
+	// each fetch so it can schedule the next crawl of the link.
+	Frontier FrontierRecorder
+
+	// An optional ArchiveWriter for archiving every request that received
+	// a response, along with the response (e.g. into WARC files). This
+	// includes non-2xx, non-HTML and failed exchanges; requests that fail
+	// before a response arrives are not archived.
+	Archive ArchiveWriter
+
+	// An optional ContentStore for keeping a copy of the raw content of
+	// every changed page (e.g. for serving cached pages or reindexing
+	// without refetching).
+	ContentStore ContentStore
+
+	// An optional FeedRegistry that is notified about the RSS and Atom
+	// feeds advertised by crawled pages. Feeds are stored in the link
+	// graph either way but are never crawled as regular pages.
+	Feeds FeedRegistry
+
+	// The normalizer applied to extracted links before they are added to
+	// the link graph. If not specified, a normalizer with the default
+	// urlnorm settings is used.
+	URLNormalizer *urlnorm.Normalizer
+
+	// An optional FingerprintStore for detecting near-duplicate pages.
+	// Near-duplicates are recorded in the store but not indexed, and pages
+	// that turn into near-duplicates are deleted from the index. Use a
+	// simhash.FileStore to keep fingerprints across restarts.
+	Fingerprints FingerprintStore
+
+	// The max Hamming distance between the SimHash fingerprints of two
+	// near-duplicate pages. Defaults to 3.
+	NearDuplicateDistance int
+
+	// Politeness configures the host-aware scheduler used by the fetch
+	// stage. The Workers, Target and HostDelay fields are populated by
+	// the crawler.
+	Politeness politeness.Config
 }
 
 type Crawler struct {
-	p *pipeline.Pipeline
+	p     *pipeline.Pipeline
+	traps TrapDetector
 }
 
 func NewCrawler(cfg Config) *Crawler {
 	return &Crawler{
-		p: assembleCrawlerPipeline(cfg),
+		p:     assembleCrawlerPipeline(cfg),
+		traps: cfg.Traps,
+	}
+}
+
+// NewCrawlerFromSpec returns a Crawler whose pipeline is assembled from spec
+// instead of the default layout. The spec may reference any of the built-in
+// crawler processors as well as the ones supplied via extraProcs.
+func NewCrawlerFromSpec(cfg Config, spec *pipeline.Spec, extraProcs map[string]pipeline.ProcessorFactory) (*Crawler, error) {
+	reg := NewProcessorRegistry(cfg)
+	for name, f := range extraProcs {
+		reg.RegisterProcessor(name, f)
+	}
+
+	p, err := reg.Build(spec)
+	if err != nil {
+		return nil, err
 	}
+
+	return &Crawler{p: p, traps: cfg.Traps}, nil
 }
 
 func assembleCrawlerPipeline(cfg Config) *pipeline.Pipeline {
-	return pipeline.New(
-		pipeline.FixedWorkerPool(
-			newLinkFetcher(cfg.URLGetter, cfg.PrivateNetworkDetector),
-			cfg.FetchWorkers,
-		),
-		pipeline.FIFO(newLinkExtractor(cfg.PrivateNetworkDetector)),
-		pipeline.FIFO(newTextExtrator()),
-		pipeline.Broadcast(
-			newGraphUpdater(cfg.Graph),
-			newTextIndexer(cfg.Indexer),
-		),
-	)
-}
-
-func (c *Crawler) Crawl(ctx context.Context, linkIt graph.LinkIterator) (int, error) {
-	sink := new(countingSink)
-	err := c.p.Process(ctx, &linkSource{linkIt: linkIt}, sink)
-	return sink.getCount(), err
+	p, err := NewProcessorRegistry(cfg).Build(DefaultPipelineSpec(cfg))
+	if err != nil {
+		panic(fmt.Sprintf("[BUG] invalid default crawler pipeline: %v", err))
+	}
+
+	return p
+}
+
+// Crawl fetches and processes the links returned by linkIt and returns a
+// report describing the outcome for each link. The report is returned even
+// if the crawl is aborted due to an error.
+func (c *Crawler) Crawl(ctx context.Context, linkIt graph.LinkIterator) (*CrawlReport, error) {
+	rc := newReportCollector()
+	err := c.p.Process(withReportCollector(ctx, rc), &linkSource{linkIt: linkIt, report: rc}, nopSink{})
+
+	report := rc.finish()
+	if c.traps != nil {
+		report.TrappedHosts = c.traps.EndPass()
+	}
+	return report, err
 }
 
 type linkSource struct {
 	linkIt graph.LinkIterator
+	report *reportCollector
 }
 
 func (ls *linkSource) Error() error {
@@ -79,7 +275,11 @@ func (ls *linkSource) Error() error {
 }
 
 func (ls *linkSource) Next(context.Context) bool {
-	return ls.linkIt.Next()
+	if !ls.linkIt.Next() {
+		return false
+	}
+	ls.report.recordLink()
+	return true
 }
 
 func (ls *linkSource) Payload() pipeline.Payload {
@@ -88,20 +288,21 @@ func (ls *linkSource) Payload() pipeline.Payload {
 
 	p.LinkID = link.ID
 	p.URL = link.URL
+	p.LinkURL = link.URL
 	p.RetrievedAt = link.RetrievedAt
+	p.ETag = link.ETag
+	p.LastModified = link.LastModified
+	p.ContentHash = link.ContentHash
+	p.Depth = link.Depth
+	p.Kind = link.Kind
+	p.FetchStatus = link.Status
 
 	return p
 }
 
-type countingSink struct {
-	count int
-}
+// nopSink discards the payloads that reach the end of the pipeline.
+type nopSink struct{}
 
-func (s *countingSink) Consume(_ context.Context, p pipeline.Payload) error {
-	s.count++
+func (nopSink) Consume(context.Context, pipeline.Payload) error {
 	return nil
 }
-
-func (s *countingSink) getCount() int {
-	return s.count / 2
-}
@@ -0,0 +1,271 @@
+// Package feed parses RSS (0.9x, 1.0 and 2.0), Atom and JSON feeds.
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// feedTypes are the media types of the feed formats supported by Parse.
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// IsFeedType returns true if mediaType (e.g. the type attribute of a
+// <link rel=alternate> element) denotes a feed format supported by Parse.
+// Media type parameters are ignored.
+func IsFeedType(mediaType string) bool {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	return err == nil && feedTypes[mt]
+}
+
+// Item is an entry of a feed. The URL is returned as it appears in the feed
+// and may be relative to the feed URL.
+type Item struct {
+	// ID uniquely identifies the item within the feed. It is the RSS guid,
+	// Atom id or JSON feed id if present and the item URL otherwise.
+	ID string
+
+	URL   string
+	Title string
+
+	// The publication (or, if unknown, last update) time of the item or
+	// the zero time if the feed does not specify one.
+	Published time.Time
+}
+
+// Feed is a parsed feed.
+type Feed struct {
+	Title string
+
+	// TTL is the min time between polls requested by the feed (RSS <ttl>)
+	// or zero if the feed does not specify one.
+	TTL time.Duration
+
+	Items []Item
+}
+
+type xmlFeed struct {
+	XMLName xml.Name
+
+	// RSS channel (for RSS 1.0 the items are siblings of the channel).
+	Channel struct {
+		Title string    `xml:"title"`
+		TTL   string    `xml:"ttl"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	Items []rssItem `xml:"item"`
+
+	// Atom feed.
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title string `xml:"title"`
+
+	// Items may also carry atom:link elements which match the same tag;
+	// the first link with text content is the item URL.
+	Links   []string `xml:"link"`
+	GUID    string   `xml:"guid"`
+	About   string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	PubDate string   `xml:"pubDate"`
+	Date    string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+type jsonFeed struct {
+	Title string `json:"title"`
+	Items []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		Title         string          `json:"title"`
+		DatePublished string          `json:"date_published"`
+		DateModified  string          `json:"date_modified"`
+	} `json:"items"`
+}
+
+// Parse parses an RSS, Atom or JSON feed. At most maxSize bytes of content
+// are read; a non-positive maxSize disables the limit. Items without a URL
+// are skipped.
+func Parse(r io.Reader, maxSize int64) (*Feed, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(data) != 0 && data[0] == '{' {
+		return parseJSON(data)
+	}
+	return parseXML(data)
+}
+
+func parseXML(data []byte) (*Feed, error) {
+	var doc xmlFeed
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+
+	f := new(Feed)
+	switch doc.XMLName.Local {
+	case "rss", "RDF":
+		f.Title = strings.TrimSpace(doc.Channel.Title)
+		if ttl, err := strconv.Atoi(strings.TrimSpace(doc.Channel.TTL)); err == nil && ttl > 0 {
+			f.TTL = time.Duration(ttl) * time.Minute
+		}
+		for _, it := range append(doc.Channel.Items, doc.Items...) {
+			if item, ok := it.toItem(); ok {
+				f.Items = append(f.Items, item)
+			}
+		}
+	case "feed":
+		f.Title = strings.TrimSpace(doc.Title)
+		for _, e := range doc.Entries {
+			if item, ok := e.toItem(); ok {
+				f.Items = append(f.Items, item)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("feed: unexpected root element %q", doc.XMLName.Local)
+	}
+	return f, nil
+}
+
+func (it rssItem) toItem() (Item, bool) {
+	item := Item{Title: strings.TrimSpace(it.Title)}
+	for _, link := range it.Links {
+		if item.URL = strings.TrimSpace(link); item.URL != "" {
+			break
+		}
+	}
+	if item.URL == "" {
+		// RSS 1.0 items are identified by their URL.
+		item.URL = strings.TrimSpace(it.About)
+	}
+	if item.URL == "" {
+		return Item{}, false
+	}
+
+	item.ID = strings.TrimSpace(it.GUID)
+	if item.ID == "" {
+		item.ID = item.URL
+	}
+	if item.Published = parseTime(it.PubDate); item.Published.IsZero() {
+		item.Published = parseTime(it.Date)
+	}
+	return item, true
+}
+
+func (e atomEntry) toItem() (Item, bool) {
+	item := Item{ID: strings.TrimSpace(e.ID), Title: strings.TrimSpace(e.Title)}
+	for _, link := range e.Links {
+		rel := strings.TrimSpace(link.Rel)
+		if rel != "" && rel != "alternate" {
+			continue
+		}
+		if item.URL = strings.TrimSpace(link.Href); item.URL != "" {
+			break
+		}
+	}
+	if item.URL == "" {
+		return Item{}, false
+	}
+
+	if item.ID == "" {
+		item.ID = item.URL
+	}
+	if item.Published = parseTime(e.Published); item.Published.IsZero() {
+		item.Published = parseTime(e.Updated)
+	}
+	return item, true
+}
+
+func parseJSON(data []byte) (*Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("feed: %w", err)
+	}
+
+	f := &Feed{Title: strings.TrimSpace(doc.Title)}
+	for _, it := range doc.Items {
+		item := Item{URL: strings.TrimSpace(it.URL), Title: strings.TrimSpace(it.Title)}
+		if item.URL == "" {
+			continue
+		}
+
+		// The spec requires string IDs but numeric ones are common.
+		var id string
+		if err := json.Unmarshal(it.ID, &id); err != nil {
+			id = string(it.ID)
+		}
+		if item.ID = strings.TrimSpace(id); item.ID == "" {
+			item.ID = item.URL
+		}
+		if item.Published = parseTime(it.DatePublished); item.Published.IsZero() {
+			item.Published = parseTime(it.DateModified)
+		}
+		f.Items = append(f.Items, item)
+	}
+	return f, nil
+}
+
+// timeLayouts are the date formats found in feeds: RFC 822 variants for RSS
+// and RFC 3339 for Atom, RSS 1.0 (dc:date) and JSON feeds.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"Mon, 2 Jan 2006 15:04 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseTime returns the parsed time or the zero time if s is missing or
+// malformed.
+func parseTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
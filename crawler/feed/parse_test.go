@@ -0,0 +1,187 @@
+package feed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRSS2(t *testing.T) {
+	f := mustParse(t, `<?xml version="1.0"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title> Example News </title>
+    <ttl>30</ttl>
+    <item>
+      <title>First</title>
+      <atom:link href="https://example.com/feed.xml" rel="self"/>
+      <link>https://example.com/first</link>
+      <guid isPermaLink="false">item-1</guid>
+      <pubDate>Fri, 01 Mar 2024 10:00:00 +0100</pubDate>
+    </item>
+    <item>
+      <title>Second</title>
+      <link>/second</link>
+      <pubDate>Sat, 2 Mar 2024 08:30 GMT</pubDate>
+    </item>
+    <item><title>No link</title></item>
+  </channel>
+</rss>`)
+
+	exp := &Feed{
+		Title: "Example News",
+		TTL:   30 * time.Minute,
+		Items: []Item{
+			{ID: "item-1", URL: "https://example.com/first", Title: "First", Published: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)},
+			{ID: "/second", URL: "/second", Title: "Second", Published: time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)},
+		},
+	}
+	if !reflect.DeepEqual(f, exp) {
+		t.Errorf("expected %+v; got %+v", exp, f)
+	}
+}
+
+func TestParseRSS1(t *testing.T) {
+	f := mustParse(t, `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.com/"><title>RDF feed</title></channel>
+  <item rdf:about="https://example.com/a">
+    <title>A</title>
+    <dc:date>2024-03-01T12:00:00Z</dc:date>
+  </item>
+</rdf:RDF>`)
+
+	exp := []Item{{ID: "https://example.com/a", URL: "https://example.com/a", Title: "A", Published: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}}
+	if f.Title != "RDF feed" || !reflect.DeepEqual(f.Items, exp) {
+		t.Errorf("unexpected feed %+v", f)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	f := mustParse(t, `<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom feed</title>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>Entry</title>
+    <link rel="edit" href="https://example.com/edit/1"/>
+    <link href="https://example.com/entry/1"/>
+    <updated>2024-03-02T00:00:00Z</updated>
+    <published>2024-03-01T00:00:00+02:00</published>
+  </entry>
+  <entry>
+    <title>Updated only</title>
+    <link rel="alternate" type="text/html" href="https://example.com/entry/2"/>
+    <updated>2024-03-03T00:00:00Z</updated>
+  </entry>
+  <entry>
+    <title>No alternate link</title>
+    <link rel="enclosure" href="https://example.com/audio.mp3"/>
+  </entry>
+</feed>`)
+
+	exp := []Item{
+		{ID: "urn:uuid:1", URL: "https://example.com/entry/1", Title: "Entry", Published: time.Date(2024, 2, 29, 22, 0, 0, 0, time.UTC)},
+		{ID: "https://example.com/entry/2", URL: "https://example.com/entry/2", Title: "Updated only", Published: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	if f.Title != "Atom feed" || !reflect.DeepEqual(f.Items, exp) {
+		t.Errorf("unexpected feed %+v", f)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	f := mustParse(t, "\xef\xbb\xbf"+`{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON feed",
+  "items": [
+    {"id": "a", "url": "https://example.com/a", "title": "A", "date_published": "2024-03-01T00:00:00Z"},
+    {"id": 42, "url": "https://example.com/b", "date_modified": "2024-03-02T00:00:00Z"},
+    {"url": "https://example.com/c"},
+    {"id": "d", "title": "No URL"}
+  ]
+}`)
+
+	exp := []Item{
+		{ID: "a", URL: "https://example.com/a", Title: "A", Published: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "42", URL: "https://example.com/b", Published: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{ID: "https://example.com/c", URL: "https://example.com/c"},
+	}
+	if f.Title != "JSON feed" || !reflect.DeepEqual(f.Items, exp) {
+		t.Errorf("unexpected feed %+v", f)
+	}
+}
+
+func TestParseLatin1(t *testing.T) {
+	f := mustParse(t, "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n"+
+		"<rss><channel><title>Caf\xe9 news</title><item><title>Cr\xe8me br\xfbl\xe9e</title><link>https://example.com/cr%C3%A8me</link></item></channel></rss>")
+
+	if f.Title != "Café news" || len(f.Items) != 1 || f.Items[0].Title != "Crème brûlée" {
+		t.Errorf("expected the feed to be transcoded to UTF-8; got %+v", f)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	specs := []struct {
+		descr   string
+		doc     string
+		maxSize int64
+	}{
+		{"unexpected root", `<urlset><url><loc>https://example.com/</loc></url></urlset>`, 0},
+		{"malformed json", `{"items": [`, 0},
+		{"empty document", ``, 0},
+		{"truncated by size limit", `{"title": "JSON feed", "items": []}`, 10},
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(strings.NewReader(spec.doc), spec.maxSize); err == nil {
+			t.Errorf("%s: expected an error", spec.descr)
+		}
+	}
+}
+
+func TestIsFeedType(t *testing.T) {
+	specs := []struct {
+		mediaType string
+		exp       bool
+	}{
+		{"application/rss+xml", true},
+		{"application/atom+xml; charset=utf-8", true},
+		{"APPLICATION/FEED+JSON", true},
+		{"text/html", false},
+		{"", false},
+	}
+
+	for _, spec := range specs {
+		if got := IsFeedType(spec.mediaType); got != spec.exp {
+			t.Errorf("IsFeedType(%q): expected %t; got %t", spec.mediaType, spec.exp, got)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	exp := time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC)
+	for _, s := range []string{
+		"2024-03-01T09:05:00Z",
+		"2024-03-01T10:05:00.000+01:00",
+		"Fri, 01 Mar 2024 09:05:00 +0000",
+		"Fri, 1 Mar 2024 09:05:00 GMT",
+		"1 Mar 2024 10:05:00 +0100",
+		"Fri, 1 Mar 2024 09:05 +0000",
+	} {
+		if got := parseTime(s); !got.Equal(exp) {
+			t.Errorf("parseTime(%q): expected %s; got %s", s, exp, got)
+		}
+	}
+
+	if got := parseTime("last tuesday"); !got.IsZero() {
+		t.Errorf("expected the zero time for a malformed date; got %s", got)
+	}
+}
+
+func mustParse(t *testing.T, doc string) *Feed {
+	t.Helper()
+	f, err := Parse(strings.NewReader(doc), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
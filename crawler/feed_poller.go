@@ -0,0 +1,471 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+	"github.com/iamleson98/go-search/crawler/feed"
+	"github.com/iamleson98/go-search/crawler/frontier"
+	"github.com/iamleson98/go-search/crawler/traps"
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+)
+
+const (
+	defaultFeedMinInterval     = 5 * time.Minute
+	defaultFeedMaxInterval     = 6 * time.Hour
+	defaultFeedInitialInterval = 15 * time.Minute
+	defaultFeedMaxSeenItems    = 1000
+	defaultFeedItemPriority    = 1.0
+	defaultFeedWorkers         = 4
+
+	feedAcceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8"
+)
+
+// FeedPollerConfig encapsulates the scheduling settings of a FeedPoller.
+type FeedPollerConfig struct {
+	// The bounds for the time between two polls of a feed. Feeds that
+	// publish new items are polled more often and quiet or failing feeds
+	// less often. Defaults to 5 minutes and 6 hours respectively.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// The poll interval of newly added feeds. Defaults to 15 minutes.
+	InitialInterval time.Duration
+
+	// The number of item IDs remembered per feed for telling new items
+	// apart from ones that have already been injected. Defaults to 1000.
+	MaxSeenItems int
+
+	// The priority (see frontier.Hint) of the links of new feed items.
+	// Defaults to 1.
+	ItemPriority float64
+
+	// The number of feeds polled concurrently. Defaults to 4.
+	Workers int
+
+	// An optional clock for obtaining the current time. Defaults to
+	// time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *FeedPollerConfig) validate() error {
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = defaultFeedMinInterval
+	}
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = defaultFeedMaxInterval
+	}
+	if cfg.MinInterval > cfg.MaxInterval {
+		return fmt.Errorf("min interval exceeds max interval")
+	}
+	if cfg.InitialInterval <= 0 {
+		cfg.InitialInterval = defaultFeedInitialInterval
+	}
+	if cfg.InitialInterval < cfg.MinInterval {
+		cfg.InitialInterval = cfg.MinInterval
+	} else if cfg.InitialInterval > cfg.MaxInterval {
+		cfg.InitialInterval = cfg.MaxInterval
+	}
+	if cfg.MaxSeenItems <= 0 {
+		cfg.MaxSeenItems = defaultFeedMaxSeenItems
+	}
+	if cfg.ItemPriority <= 0 {
+		cfg.ItemPriority = defaultFeedItemPriority
+	} else if cfg.ItemPriority > 1 {
+		return fmt.Errorf("item priority must be in the (0, 1] range")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultFeedWorkers
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// feedState tracks the polling schedule of a feed. The schedule is guarded
+// by the poller mutex; the remaining fields are only accessed by the
+// goroutine that claimed the feed for polling.
+type feedState struct {
+	interval   time.Duration
+	nextPollAt time.Time
+	polling    bool
+
+	link graph.Link
+
+	// The IDs of recently seen items in insertion order.
+	seen      map[string]bool
+	seenOrder []string
+}
+
+// markSeen records an item ID and returns false if it has been seen before.
+func (st *feedState) markSeen(id string, maxSeen int) bool {
+	if st.seen[id] {
+		return false
+	}
+
+	st.seen[id] = true
+	st.seenOrder = append(st.seenOrder, id)
+	if len(st.seenOrder) > maxSeen {
+		delete(st.seen, st.seenOrder[0])
+		st.seenOrder = st.seenOrder[1:]
+	}
+	return true
+}
+
+// FeedPoller polls the RSS and Atom feeds discovered by the crawler on a
+// per-feed schedule and adds the links of new items to the link graph. This
+// gets fresh articles crawled within minutes of their publication instead
+// of at the next crawl pass.
+//
+// Poll should be invoked periodically (e.g. every minute); only the feeds
+// that are due are retrieved. FeedPoller is safe for concurrent use.
+type FeedPoller struct {
+	cfg         FeedPollerConfig
+	urlGetter   URLGetter
+	netDetector PrivateNetworkDetector
+	robots      RobotsPolicy
+	scope       ScopePolicy
+	traps       TrapDetector
+	normalizer  *urlnorm.Normalizer
+	graph       Graph
+	hinter      ScheduleHinter
+	limits      fetchLimits
+
+	mu    sync.Mutex
+	feeds map[uuid.UUID]*feedState
+}
+
+// NewFeedPoller returns a FeedPoller that retrieves feeds via cfg.URLGetter
+// and adds new items to cfg.Graph, subject to the private network detector,
+// robots policy, URL normalizer, scope and trap detector in cfg. If hinter is
+// not nil, new items are forwarded to it with pollCfg.ItemPriority so they
+// are crawled ahead of other due links.
+func NewFeedPoller(cfg Config, pollCfg FeedPollerConfig, hinter ScheduleHinter) (*FeedPoller, error) {
+	if err := pollCfg.validate(); err != nil {
+		return nil, fmt.Errorf("feed poller: config validation failed: %w", err)
+	}
+	if cfg.URLNormalizer == nil {
+		cfg.URLNormalizer = urlnorm.New(urlnorm.Config{})
+	}
+
+	// Truncated feeds cannot be parsed.
+	limits := makeFetchLimits(cfg)
+	limits.truncate = false
+
+	return &FeedPoller{
+		cfg:         pollCfg,
+		urlGetter:   cfg.URLGetter,
+		netDetector: cfg.PrivateNetworkDetector,
+		robots:      cfg.Robots,
+		scope:       cfg.Scope,
+		traps:       cfg.Traps,
+		normalizer:  cfg.URLNormalizer,
+		graph:       cfg.Graph,
+		hinter:      hinter,
+		limits:      limits,
+		feeds:       make(map[uuid.UUID]*feedState),
+	}, nil
+}
+
+// Sync adds the feed links returned by it to the poller. Links of any other
+// kind are ignored.
+func (p *FeedPoller) Sync(it graph.LinkIterator) error {
+	for it.Next() {
+		if link := it.Link(); link.Kind == graph.LinkKindFeed {
+			p.AddFeed(link)
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("feed poller sync: %w", err)
+	}
+	return nil
+}
+
+// AddFeed adds a feed to the poller. New feeds are due immediately; the
+// schedule of known feeds is left unchanged.
+func (p *FeedPoller) AddFeed(link *graph.Link) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, found := p.feeds[link.ID]; found {
+		return
+	}
+	p.feeds[link.ID] = &feedState{
+		link:     *link,
+		interval: p.cfg.InitialInterval,
+		seen:     make(map[string]bool),
+	}
+}
+
+// Len returns the number of feeds tracked by the poller.
+func (p *FeedPoller) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.feeds)
+}
+
+// Poll retrieves the feeds that are due and adds the links of their new
+// items to the link graph. It returns the number of added links. Feeds that
+// cannot be retrieved or parsed are polled less often and their errors are
+// returned once all other due feeds have been processed.
+func (p *FeedPoller) Poll(ctx context.Context) (int, error) {
+	due := p.claimDue()
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	var (
+		wg    sync.WaitGroup
+		resMu sync.Mutex
+		added int
+		errs  error
+		feeds = make(chan *feedState)
+	)
+	for i := 0; i < p.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for st := range feeds {
+				n, err := p.poll(ctx, st)
+				resMu.Lock()
+				added += n
+				if err != nil {
+					errs = multierror.Append(errs, err)
+				}
+				resMu.Unlock()
+			}
+		}()
+	}
+
+	for _, st := range due {
+		feeds <- st
+	}
+	close(feeds)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return added, err
+	}
+	return added, errs
+}
+
+// claimDue returns the feeds that are due and flags them as being polled.
+func (p *FeedPoller) claimDue() []*feedState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		due []*feedState
+		now = p.cfg.Clock()
+	)
+	for _, st := range p.feeds {
+		if !st.polling && !st.nextPollAt.After(now) {
+			st.polling = true
+			due = append(due, st)
+		}
+	}
+	return due
+}
+
+// poll retrieves a single feed and reschedules it.
+func (p *FeedPoller) poll(ctx context.Context, st *feedState) (int, error) {
+	added, ttl, err := p.fetchItems(ctx, st)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	st.polling = false
+
+	// Polls cut short by cancellation are not the fault of the feed; it
+	// stays due and keeps its interval.
+	if ctx.Err() != nil {
+		return added, nil
+	}
+
+	switch {
+	case err != nil:
+		st.interval *= 2
+	case added != 0:
+		st.interval /= 2
+	default:
+		st.interval += st.interval / 2
+	}
+	if st.interval < ttl {
+		st.interval = ttl
+	}
+	if st.interval < p.cfg.MinInterval {
+		st.interval = p.cfg.MinInterval
+	} else if st.interval > p.cfg.MaxInterval {
+		st.interval = p.cfg.MaxInterval
+	}
+	st.nextPollAt = p.cfg.Clock().Add(st.interval)
+
+	if err != nil {
+		return added, fmt.Errorf("feed %s: %w", st.link.URL, err)
+	}
+	return added, nil
+}
+
+// fetchItems retrieves a feed and adds its unseen items to the link graph.
+// It returns the number of added links and the TTL declared by the feed.
+func (p *FeedPoller) fetchItems(ctx context.Context, st *feedState) (int, time.Duration, error) {
+	u, err := url.Parse(st.link.URL)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Feeds that we may not retrieve are retried at the max interval in
+	// case the robots.txt rules change.
+	if isPrivate, err := p.netDetector.IsPrivate(u.Hostname()); err != nil || isPrivate {
+		return 0, p.cfg.MaxInterval, err
+	}
+	if p.robots != nil {
+		if allowed, err := p.robots.IsAllowed(ctx, u); err != nil || !allowed {
+			return 0, p.cfg.MaxInterval, err
+		}
+	}
+
+	reqCtx, cancelFn := context.WithTimeout(ctx, p.limits.timeout)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, st.link.URL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Accept", feedAcceptHeader)
+	if st.link.ETag != "" {
+		req.Header.Set("If-None-Match", st.link.ETag)
+	}
+	if st.link.LastModified != "" {
+		req.Header.Set("If-Modified-Since", st.link.LastModified)
+	}
+
+	res, err := p.urlGetter.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var body bytes.Buffer
+	_, err = readBody(&body, res, p.limits)
+	_ = res.Body.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading body: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotModified {
+		return 0, 0, p.touchFeed(st, res.Header)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0, 0, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+
+	f, err := feed.Parse(&body, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Item URLs are relative to the URL the feed was served from.
+	base := u
+	if res.Request != nil && res.Request.URL != nil {
+		base = res.Request.URL
+	}
+
+	var added int
+	for _, item := range f.Items {
+		if err := ctx.Err(); err != nil {
+			return added, f.TTL, err
+		}
+		if st.seen[item.ID] {
+			continue
+		}
+
+		// Items are only marked as seen once their link has been stored so
+		// that they are retried on the next poll if the upsert fails.
+		link := p.itemLink(base, item.URL, graph.ChildDepth(st.link.Depth))
+		if link == nil {
+			st.markSeen(item.ID, p.cfg.MaxSeenItems)
+			continue
+		}
+		if err = p.graph.UpsertLink(link); err != nil {
+			return added, f.TTL, err
+		}
+		st.markSeen(item.ID, p.cfg.MaxSeenItems)
+		added++
+
+		if p.hinter == nil {
+			continue
+		}
+		err = p.hinter.Hint(link, frontier.Hint{LastModified: item.Published, Priority: p.cfg.ItemPriority})
+		if err != nil {
+			return added, f.TTL, err
+		}
+	}
+
+	return added, f.TTL, p.touchFeed(st, res.Header)
+}
+
+// itemLink returns the link for an item URL or nil if the URL should not be
+// added to the link graph. Items are filtered like the links extracted from
+// pages.
+func (p *FeedPoller) itemLink(base *url.URL, itemURL string, distance int) *graph.Link {
+	u := resolveURL(base, itemURL)
+	if u == nil {
+		return nil
+	}
+	u, err := p.normalizer.Normalize(u)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	if exclusionRegex.MatchString(u.String()) {
+		return nil
+	}
+	if p.scope != nil && !p.scope.InScope(u, distance) {
+		return nil
+	}
+	// Only rules that have already been retrieved are consulted; the
+	// fetcher performs the authoritative check.
+	if p.robots != nil {
+		if allowed, known := p.robots.IsAllowedCached(u); known && !allowed {
+			return nil
+		}
+	}
+	if !strings.EqualFold(u.Hostname(), base.Hostname()) {
+		if isPrivate, err := p.netDetector.IsPrivate(u.Host); err != nil || isPrivate {
+			return nil
+		}
+	}
+	if p.traps != nil && p.traps.Admit(u) != traps.ReasonNone {
+		return nil
+	}
+	return &graph.Link{URL: u.String(), Depth: distance}
+}
+
+// touchFeed records the retrieval time and validators of a feed in the link
+// graph so that conditional requests survive restarts.
+func (p *FeedPoller) touchFeed(st *feedState, header http.Header) error {
+	link := st.link
+	link.RetrievedAt = p.cfg.Clock()
+	if etag := header.Get("ETag"); etag != "" {
+		link.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		link.LastModified = lastModified
+	}
+	link.Kind = graph.LinkKindFeed
+	if err := p.graph.UpsertLink(&link); err != nil {
+		return err
+	}
+
+	st.link = link
+	return nil
+}
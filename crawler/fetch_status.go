@@ -0,0 +1,143 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultFailureBackoff    = time.Hour
+	defaultMaxFailureBackoff = 7 * 24 * time.Hour
+	defaultMaxFailures       = 5
+)
+
+// The error classes recorded in graph.FetchStatus.
+const (
+	errClassTimeout      = "timeout"
+	errClassDNS          = "dns"
+	errClassConnection   = "connection"
+	errClassBodyTooLarge = "body-too-large"
+	errClassHTTPStatus   = "http-status"
+	errClassOther        = "other"
+)
+
+// fetchStatusTracker persists the fetch status of the crawled links. Links
+// that keep failing are backed off exponentially and, once they have failed
+// too many times in a row, marked as dead and removed from the index.
+type fetchStatusTracker struct {
+	graph       Graph
+	indexer     Indexer
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxFailures int
+}
+
+func newFetchStatusTracker(cfg Config) *fetchStatusTracker {
+	t := &fetchStatusTracker{
+		graph:       cfg.Graph,
+		indexer:     cfg.Indexer,
+		backoff:     cfg.FailureBackoff,
+		maxBackoff:  cfg.MaxFailureBackoff,
+		maxFailures: cfg.MaxFailures,
+	}
+	if t.backoff <= 0 {
+		t.backoff = defaultFailureBackoff
+	}
+	if t.maxBackoff <= 0 {
+		t.maxBackoff = defaultMaxFailureBackoff
+	}
+	if t.maxFailures <= 0 {
+		t.maxFailures = defaultMaxFailures
+	}
+	return t
+}
+
+// record updates the fetch status of linkID (kept in payload.FetchStatus)
+// according to the outcome of the fetch and persists it. Outcomes that did
+// not involve a request are not recorded. It is a no-op on a nil tracker.
+func (t *fetchStatusTracker) record(linkID uuid.UUID, payload *crawlerPayload, outcome FetchOutcome, fetchErr error) error {
+	if t == nil {
+		return nil
+	}
+
+	var failed bool
+	switch outcome {
+	case OutcomeFetched, OutcomeNotModified, OutcomeNonHTML:
+	case OutcomeNon2xx, OutcomeFetchError:
+		failed = true
+	default:
+		return nil
+	}
+
+	status := &payload.FetchStatus
+	status.CheckedAt = payload.FetchedAt
+	status.StatusCode = payload.StatusCode
+	// 304 responses usually omit the content type.
+	if contentType := payload.Header.Get("Content-Type"); contentType != "" {
+		status.ContentType = contentType
+	}
+
+	if !failed {
+		status.ErrorClass = ""
+		status.Failures = 0
+		status.RetryAfter = time.Time{}
+		status.Dead = false
+		return t.graph.UpdateFetchStatus(linkID, status)
+	}
+
+	wasDead := status.Dead
+	status.ErrorClass = classifyFetchError(outcome, fetchErr)
+	status.Failures++
+	status.RetryAfter = status.CheckedAt.Add(t.backoffFor(status.Failures))
+	status.Dead = status.Failures >= t.maxFailures
+	if err := t.graph.UpdateFetchStatus(linkID, status); err != nil {
+		return err
+	}
+
+	if status.Dead && !wasDead {
+		return t.indexer.Delete(linkID)
+	}
+	return nil
+}
+
+// backoffFor returns the backoff after the specified number of consecutive
+// failures.
+func (t *fetchStatusTracker) backoffFor(failures int) time.Duration {
+	backoff := t.backoff
+	for i := 1; i < failures && backoff < t.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > t.maxBackoff {
+		backoff = t.maxBackoff
+	}
+	return backoff
+}
+
+// classifyFetchError returns the error class for a failed fetch.
+func classifyFetchError(outcome FetchOutcome, err error) string {
+	if outcome == OutcomeNon2xx {
+		return errClassHTTPStatus
+	}
+
+	var (
+		dnsErr *net.DNSError
+		opErr  *net.OpError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		return errClassTimeout
+	case errors.As(err, &dnsErr):
+		return errClassDNS
+	case errors.Is(err, errBodyTooLarge):
+		return errClassBodyTooLarge
+	case errors.As(err, &opErr):
+		return errClassConnection
+	default:
+		return errClassOther
+	}
+}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/crawler/simhash"
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+const (
+	// defaultNearDuplicateDistance is the max Hamming distance between the
+	// fingerprints of two pages that are considered near-duplicates.
+	defaultNearDuplicateDistance = 3
+
+	// minFingerprintFeatures is the min number of shingles a page needs to
+	// have to take part in near-duplicate detection. Pages with very little
+	// text would otherwise be flagged as duplicates of each other.
+	minFingerprintFeatures = 8
+)
+
+type fingerprinter struct {
+	store       FingerprintStore
+	indexer     Indexer
+	maxDistance int
+}
+
+func newFingerprinter(store FingerprintStore, indexer Indexer, maxDistance int) *fingerprinter {
+	if maxDistance <= 0 {
+		maxDistance = defaultNearDuplicateDistance
+	}
+
+	return &fingerprinter{
+		store:       store,
+		indexer:     indexer,
+		maxDistance: maxDistance,
+	}
+}
+
+func (f *fingerprinter) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
+
+	fp, features := simhash.Fingerprint(payload.TextContent)
+	if features < minFingerprintFeatures {
+		return payload, nil
+	}
+	payload.Fingerprint = fp
+
+	dupOf, found, err := f.store.FindNearDuplicate(payload.LinkID, fp, f.maxDistance)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		payload.DuplicateOf = dupOf
+	}
+
+	prev, known, err := f.store.Lookup(payload.LinkID)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.store.Upsert(simhash.Entry{
+		LinkID:      payload.LinkID,
+		Fingerprint: fp,
+		DuplicateOf: payload.DuplicateOf,
+	}); err != nil {
+		return nil, err
+	}
+
+	// A page that turned into a duplicate since it was last fetched may
+	// still be in the index; the text indexer skips duplicates so it has
+	// to be removed here.
+	if found && (!known || prev.DuplicateOf == uuid.Nil) {
+		if err = f.indexer.Delete(payload.LinkID); err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,472 @@
+// Package frontier implements a crawl frontier that schedules recrawls of
+// known links based on how often their content changes, their PageRank
+// score and recent fetch failures.
+package frontier
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+)
+
+const (
+	defaultMinInterval     = time.Hour
+	defaultMaxInterval     = 30 * 24 * time.Hour
+	defaultInitialInterval = 24 * time.Hour
+	defaultRetryBackoff    = time.Hour
+	defaultLeaseDuration   = time.Hour
+
+	// changedFactor and unchangedFactor scale the recrawl interval of a
+	// link after a fetch that observed changed or unchanged content.
+	changedFactor   = 0.5
+	unchangedFactor = 1.5
+
+	defaultPriority = 0.5
+)
+
+// OutcomeKind describes the result of fetching a link.
+type OutcomeKind uint8
+
+const (
+	// OutcomeChanged indicates that the content of the link has changed
+	// since it was last retrieved (or was retrieved for the first time).
+	OutcomeChanged OutcomeKind = iota
+
+	// OutcomeUnchanged indicates that the content has not changed.
+	OutcomeUnchanged
+
+	// OutcomeFailed indicates that the link could not be retrieved.
+	OutcomeFailed
+
+	// OutcomeSkipped indicates that the link was not retrieved, e.g.
+	// because of robots.txt rules or the crawl scope.
+	OutcomeSkipped
+)
+
+// Outcome describes the result of fetching a link yielded by the frontier.
+type Outcome struct {
+	// The fetched link including any updated validators and its fetch
+	// status.
+	Link graph.Link
+
+	Kind      OutcomeKind
+	FetchedAt time.Time
+}
+
+// Entry holds the scheduling state of a link.
+type Entry struct {
+	Link graph.Link
+
+	// NextCrawlAt is the time the link is due to be crawled; links that
+	// have never been crawled are due immediately.
+	NextCrawlAt time.Time
+
+	// Interval is the current estimate of the time between content
+	// changes.
+	Interval time.Duration
+
+	// LastCrawledAt is the time of the last successful fetch and
+	// LastChangedAt the last time a content change was observed.
+	LastCrawledAt time.Time
+	LastChangedAt time.Time
+
+	// Failures is the number of consecutive failed fetches.
+	Failures int
+
+	// Score is the PageRank score of the link.
+	Score float64
+
+	// Priority is the relative priority of the link in [0, 1] as declared
+	// by the site (e.g. in a sitemap). Defaults to 0.5.
+	Priority float64
+}
+
+// Hint carries scheduling information that a site declares about one of its
+// links, e.g. in a sitemap.
+type Hint struct {
+	// The time the content of the link was last modified.
+	LastModified time.Time
+
+	// The expected time between content changes.
+	ChangeInterval time.Duration
+
+	// The relative priority of the link in [0, 1].
+	Priority float64
+}
+
+// Store is implemented by objects that can persist frontier entries.
+type Store interface {
+	// Upsert creates or replaces the entry for e.Link.ID.
+	Upsert(e *Entry) error
+
+	// Lookup returns a copy of the entry for linkID.
+	Lookup(linkID uuid.UUID) (*Entry, bool, error)
+
+	// Due returns copies of all entries whose NextCrawlAt is not after t.
+	Due(t time.Time) ([]*Entry, error)
+
+	// MaxScore returns the highest score of any entry.
+	MaxScore() (float64, error)
+}
+
+// Config encapsulates the settings for a Frontier.
+type Config struct {
+	// The store for the frontier entries. Defaults to an InMemoryStore;
+	// use a GraphStore to keep schedules across restarts.
+	Store Store
+
+	// The bounds for the recrawl interval of a link. Default to 1 hour
+	// and 30 days.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+
+	// The recrawl interval for newly discovered links. Defaults to 24h.
+	InitialInterval time.Duration
+
+	// The delay before retrying a failed link. It doubles with every
+	// consecutive failure up to MaxInterval. Defaults to 1 hour.
+	RetryBackoff time.Duration
+
+	// Links yielded by Due are not yielded again until their outcome is
+	// recorded or the lease expires. Defaults to 1 hour.
+	LeaseDuration time.Duration
+
+	// ScoreWeight controls how much PageRank shortens the recrawl
+	// interval. The interval of the highest-ranked link is divided by
+	// 1+ScoreWeight. Defaults to 1; set to a negative value to disable.
+	ScoreWeight float64
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Store == nil {
+		cfg.Store = NewInMemoryStore()
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = defaultMinInterval
+	}
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = defaultMaxInterval
+	}
+	if cfg.MinInterval > cfg.MaxInterval {
+		return fmt.Errorf("frontier: min interval %s exceeds max interval %s", cfg.MinInterval, cfg.MaxInterval)
+	}
+	if cfg.InitialInterval <= 0 {
+		cfg.InitialInterval = defaultInitialInterval
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = defaultLeaseDuration
+	}
+	if cfg.ScoreWeight == 0 {
+		cfg.ScoreWeight = 1
+	} else if cfg.ScoreWeight < 0 {
+		cfg.ScoreWeight = 0
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// Frontier schedules the recrawls of known links. Links whose content
+// changes frequently are crawled more often while the interval of static
+// links grows up to the configured max. It is safe for concurrent use.
+type Frontier struct {
+	cfg Config
+
+	// mu serializes read-modify-write cycles on store entries.
+	mu sync.Mutex
+}
+
+// New returns a new Frontier using the provided config.
+func New(cfg Config) (*Frontier, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Frontier{cfg: cfg}, nil
+}
+
+// Sync adds the links returned by it to the frontier and refreshes the
+// stored details (e.g. validators) of known links. Newly added links are
+// due immediately.
+func (f *Frontier) Sync(it graph.LinkIterator) error {
+	for it.Next() {
+		if err := f.Add(it.Link()); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return fmt.Errorf("frontier sync: %w", err)
+	}
+	return nil
+}
+
+// Add adds link to the frontier or refreshes its details if it is already
+// known.
+func (f *Frontier) Add(link *graph.Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, found, err := f.cfg.Store.Lookup(link.ID)
+	if err != nil {
+		return fmt.Errorf("frontier add: %w", err)
+	}
+	if !found {
+		e = f.newEntry()
+	}
+	e.Link = *link
+
+	if err = f.cfg.Store.Upsert(e); err != nil {
+		return fmt.Errorf("frontier add: %w", err)
+	}
+	return nil
+}
+
+// Due returns an iterator for up to limit links that are due to be crawled,
+// highest priority first. Links are prioritized by their PageRank score and
+// by how long they are overdue. The returned links are leased so they are
+// not returned by subsequent calls until the lease expires.
+func (f *Frontier) Due(limit int) (graph.LinkIterator, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.cfg.Clock()
+	entries, err := f.cfg.Store.Due(now)
+	if err != nil {
+		return nil, fmt.Errorf("frontier due: %w", err)
+	}
+	maxScore, err := f.cfg.Store.MaxScore()
+	if err != nil {
+		return nil, fmt.Errorf("frontier due: %w", err)
+	}
+
+	priorities := make(map[uuid.UUID]float64, len(entries))
+	for _, e := range entries {
+		priorities[e.Link.ID] = f.priority(e, now, maxScore)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return priorities[entries[i].Link.ID] > priorities[entries[j].Link.ID]
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	links := make([]*graph.Link, 0, len(entries))
+	for _, e := range entries {
+		e.NextCrawlAt = now.Add(f.cfg.LeaseDuration)
+		if err = f.cfg.Store.Upsert(e); err != nil {
+			return nil, fmt.Errorf("frontier due: %w", err)
+		}
+
+		link := e.Link
+		links = append(links, &link)
+	}
+
+	return &linkIterator{links: links}, nil
+}
+
+// Record updates the schedule of a link based on the outcome of fetching it.
+func (f *Frontier) Record(o Outcome) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, found, err := f.cfg.Store.Lookup(o.Link.ID)
+	if err != nil {
+		return fmt.Errorf("frontier record: %w", err)
+	}
+	if !found {
+		e = f.newEntry()
+	}
+	e.Link = o.Link
+
+	maxScore, err := f.cfg.Store.MaxScore()
+	if err != nil {
+		return fmt.Errorf("frontier record: %w", err)
+	}
+
+	fetchedAt := o.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = f.cfg.Clock()
+	}
+
+	switch o.Kind {
+	case OutcomeChanged:
+		// The first retrieval of a link tells us nothing about how often
+		// it changes.
+		if !e.LastCrawledAt.IsZero() {
+			e.Interval = f.clamp(time.Duration(float64(e.Interval) * changedFactor))
+		}
+		e.LastCrawledAt, e.LastChangedAt, e.Failures = fetchedAt, fetchedAt, 0
+		e.NextCrawlAt = fetchedAt.Add(f.delay(e, maxScore))
+	case OutcomeUnchanged:
+		e.Interval = f.clamp(time.Duration(float64(e.Interval) * unchangedFactor))
+		e.LastCrawledAt, e.Failures = fetchedAt, 0
+		e.NextCrawlAt = fetchedAt.Add(f.delay(e, maxScore))
+	case OutcomeFailed:
+		e.Failures++
+		backoff := float64(f.cfg.RetryBackoff) * math.Pow(2, float64(e.Failures-1))
+		if backoff > float64(f.cfg.MaxInterval) {
+			backoff = float64(f.cfg.MaxInterval)
+		}
+		e.NextCrawlAt = fetchedAt.Add(time.Duration(backoff))
+	default:
+		e.NextCrawlAt = fetchedAt.Add(f.delay(e, maxScore))
+	}
+
+	if err = f.cfg.Store.Upsert(e); err != nil {
+		return fmt.Errorf("frontier record: %w", err)
+	}
+	return nil
+}
+
+// Hint adds link to the frontier (if not already known) and applies the
+// scheduling information declared by its site. The declared change interval
+// seeds the interval estimate of links whose changes have not been observed
+// yet and links modified after their last crawl become due immediately.
+func (f *Frontier) Hint(link *graph.Link, h Hint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, found, err := f.cfg.Store.Lookup(link.ID)
+	if err != nil {
+		return fmt.Errorf("frontier hint: %w", err)
+	}
+	if !found {
+		e = f.newEntry()
+		e.Link = *link
+	}
+
+	if h.ChangeInterval > 0 && e.LastCrawledAt.IsZero() {
+		e.Interval = f.clamp(h.ChangeInterval)
+	}
+	if !e.LastCrawledAt.IsZero() && h.LastModified.After(e.LastCrawledAt) {
+		if now := f.cfg.Clock(); e.NextCrawlAt.After(now) {
+			e.NextCrawlAt = now
+		}
+	}
+	e.Priority = math.Max(0, math.Min(1, h.Priority))
+
+	if err = f.cfg.Store.Upsert(e); err != nil {
+		return fmt.Errorf("frontier hint: %w", err)
+	}
+	return nil
+}
+
+// ScoreUpdater is implemented by objects that receive the PageRank scores of
+// links. index.Indexer implementations satisfy this interface.
+type ScoreUpdater interface {
+	UpdateScore(linkID uuid.UUID, score float64) error
+}
+
+// ScoreTee returns a ScoreUpdater that applies each score to next and then
+// to f. It is meant to be passed to the PageRank calculator in place of the
+// text indexer so that the frontier schedules links using the same scores
+// that rank search results.
+func (f *Frontier) ScoreTee(next ScoreUpdater) ScoreUpdater {
+	return scoreTee{next: next, f: f}
+}
+
+type scoreTee struct {
+	next ScoreUpdater
+	f    *Frontier
+}
+
+func (t scoreTee) UpdateScore(linkID uuid.UUID, score float64) error {
+	if err := t.next.UpdateScore(linkID, score); err != nil {
+		return err
+	}
+	return t.f.UpdateScore(linkID, score)
+}
+
+// UpdateScore sets the PageRank score of a known link. Unknown links are
+// ignored.
+func (f *Frontier) UpdateScore(linkID uuid.UUID, score float64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, found, err := f.cfg.Store.Lookup(linkID)
+	if err != nil {
+		return fmt.Errorf("frontier update score: %w", err)
+	} else if !found {
+		return nil
+	}
+
+	e.Score = score
+	if err = f.cfg.Store.Upsert(e); err != nil {
+		return fmt.Errorf("frontier update score: %w", err)
+	}
+	return nil
+}
+
+func (f *Frontier) newEntry() *Entry {
+	return &Entry{
+		Interval: f.cfg.InitialInterval,
+		Priority: defaultPriority,
+	}
+}
+
+// delay returns the time until the next crawl of e: its change interval
+// shortened according to its relative PageRank score.
+func (f *Frontier) delay(e *Entry, maxScore float64) time.Duration {
+	return f.clamp(time.Duration(float64(e.Interval) / (1 + f.cfg.ScoreWeight*relativeScore(e.Score, maxScore))))
+}
+
+// priority returns the crawl priority of a due entry. Entries are ranked by
+// the number of intervals they are overdue, boosted by their score and
+// scaled by their declared priority.
+func (f *Frontier) priority(e *Entry, now time.Time, maxScore float64) float64 {
+	overdue := float64(now.Sub(e.NextCrawlAt)) / float64(e.Interval)
+	if e.NextCrawlAt.IsZero() {
+		// Never crawled; rank them as overdue by one interval so that
+		// high-scoring ones are still preferred.
+		overdue = 1
+	}
+	return (1 + overdue) * (1 + f.cfg.ScoreWeight*relativeScore(e.Score, maxScore)) * (0.5 + e.Priority)
+}
+
+func (f *Frontier) clamp(d time.Duration) time.Duration {
+	if d < f.cfg.MinInterval {
+		return f.cfg.MinInterval
+	} else if d > f.cfg.MaxInterval {
+		return f.cfg.MaxInterval
+	}
+	return d
+}
+
+func relativeScore(score, maxScore float64) float64 {
+	if maxScore <= 0 || score <= 0 {
+		return 0
+	}
+	return score / maxScore
+}
+
+// linkIterator is a graph.LinkIterator for a slice of links.
+type linkIterator struct {
+	links   []*graph.Link
+	curIdx  int
+	current *graph.Link
+}
+
+func (i *linkIterator) Next() bool {
+	if i.curIdx >= len(i.links) {
+		return false
+	}
+	i.current = i.links[i.curIdx]
+	i.curIdx++
+	return true
+}
+
+func (i *linkIterator) Error() error      { return nil }
+func (i *linkIterator) Close() error      { return nil }
+func (i *linkIterator) Link() *graph.Link { return i.current }
@@ -0,0 +1,338 @@
+package frontier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+)
+
+var testEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestDueLeasesLinks(t *testing.T) {
+	clk := &testClock{now: testEpoch}
+	f := newTestFrontier(t, Config{Clock: clk.Now})
+	a, b := addLink(t, f), addLink(t, f)
+
+	if got := dueIDs(t, f, 0); len(got) != 2 || !containsID(got, a.ID) || !containsID(got, b.ID) {
+		t.Fatalf("expected both new links to be due; got %v", got)
+	}
+	if got := dueIDs(t, f, 0); len(got) != 0 {
+		t.Errorf("expected leased links not to be due; got %v", got)
+	}
+
+	clk.Advance(defaultLeaseDuration)
+	if got := dueIDs(t, f, 1); len(got) != 1 {
+		t.Errorf("expected the limit to be applied once the leases expired; got %v", got)
+	}
+}
+
+func TestAddRefreshesLinkDetails(t *testing.T) {
+	f := newTestFrontier(t, Config{Clock: func() time.Time { return testEpoch }})
+	link := addLink(t, f)
+	mustRecord(t, f, Outcome{Link: *link, Kind: OutcomeChanged, FetchedAt: testEpoch})
+
+	updated := *link
+	updated.ETag = `"v2"`
+	if err := f.Add(&updated); err != nil {
+		t.Fatal(err)
+	}
+
+	e := lookup(t, f, link.ID)
+	if e.Link.ETag != `"v2"` {
+		t.Errorf("expected the link details to be refreshed; got %+v", e.Link)
+	}
+	if !e.NextCrawlAt.Equal(testEpoch.Add(defaultInitialInterval)) {
+		t.Errorf("expected the schedule to be kept; got %s", e.NextCrawlAt)
+	}
+}
+
+func TestRecordAdaptsInterval(t *testing.T) {
+	f := newTestFrontier(t, Config{MinInterval: 10 * time.Hour, MaxInterval: 40 * time.Hour})
+	link := addLink(t, f)
+
+	specs := []struct {
+		descr string
+		kind  OutcomeKind
+		exp   time.Duration
+	}{
+		{"first fetch", OutcomeChanged, 24 * time.Hour},
+		{"changed", OutcomeChanged, 12 * time.Hour},
+		{"changed below min", OutcomeChanged, 10 * time.Hour},
+		{"unchanged", OutcomeUnchanged, 15 * time.Hour},
+		{"unchanged again", OutcomeUnchanged, 22*time.Hour + 30*time.Minute},
+		{"unchanged above max", OutcomeUnchanged, 33*time.Hour + 45*time.Minute},
+		{"unchanged capped", OutcomeUnchanged, 40 * time.Hour},
+		{"skipped", OutcomeSkipped, 40 * time.Hour},
+	}
+
+	fetchedAt := testEpoch
+	for _, spec := range specs {
+		fetchedAt = fetchedAt.Add(time.Hour)
+		mustRecord(t, f, Outcome{Link: *link, Kind: spec.kind, FetchedAt: fetchedAt})
+
+		e := lookup(t, f, link.ID)
+		if e.Interval != spec.exp {
+			t.Errorf("%s: expected interval %s; got %s", spec.descr, spec.exp, e.Interval)
+		}
+		if exp := fetchedAt.Add(spec.exp); !e.NextCrawlAt.Equal(exp) {
+			t.Errorf("%s: expected next crawl at %s; got %s", spec.descr, exp, e.NextCrawlAt)
+		}
+	}
+
+	if e := lookup(t, f, link.ID); !e.LastChangedAt.Equal(testEpoch.Add(3*time.Hour)) || !e.LastCrawledAt.Equal(testEpoch.Add(7*time.Hour)) {
+		t.Errorf("unexpected crawl times %s, %s", e.LastChangedAt, e.LastCrawledAt)
+	}
+}
+
+func TestRecordBacksOffFailures(t *testing.T) {
+	f := newTestFrontier(t, Config{RetryBackoff: time.Hour, MaxInterval: 6 * time.Hour})
+	link := addLink(t, f)
+	mustRecord(t, f, Outcome{Link: *link, Kind: OutcomeChanged, FetchedAt: testEpoch})
+
+	for i, exp := range []time.Duration{time.Hour, 2 * time.Hour, 4 * time.Hour, 6 * time.Hour} {
+		mustRecord(t, f, Outcome{Link: *link, Kind: OutcomeFailed, FetchedAt: testEpoch})
+		e := lookup(t, f, link.ID)
+		if got := e.NextCrawlAt.Sub(testEpoch); got != exp {
+			t.Errorf("failure %d: expected a backoff of %s; got %s", i+1, exp, got)
+		}
+		if e.Interval != defaultInitialInterval {
+			t.Errorf("failure %d: expected the interval to be kept; got %s", i+1, e.Interval)
+		}
+	}
+
+	// A successful fetch resets the failure count.
+	mustRecord(t, f, Outcome{Link: *link, Kind: OutcomeUnchanged, FetchedAt: testEpoch})
+	if e := lookup(t, f, link.ID); e.Failures != 0 {
+		t.Errorf("expected the failures to be reset; got %d", e.Failures)
+	}
+}
+
+func TestRecordUsesScore(t *testing.T) {
+	f := newTestFrontier(t, Config{})
+	top, other := addLink(t, f), addLink(t, f)
+	mustUpdateScore(t, f, top.ID, 0.8)
+	mustUpdateScore(t, f, other.ID, 0.4)
+
+	mustRecord(t, f, Outcome{Link: *top, Kind: OutcomeChanged, FetchedAt: testEpoch})
+	mustRecord(t, f, Outcome{Link: *other, Kind: OutcomeChanged, FetchedAt: testEpoch})
+
+	if got := lookup(t, f, top.ID).NextCrawlAt.Sub(testEpoch); got != 12*time.Hour {
+		t.Errorf("expected the top-ranked link to be recrawled after 12h; got %s", got)
+	}
+	if got := lookup(t, f, other.ID).NextCrawlAt.Sub(testEpoch); got != 16*time.Hour {
+		t.Errorf("expected the other link to be recrawled after 16h; got %s", got)
+	}
+}
+
+func TestDuePriorities(t *testing.T) {
+	clk := &testClock{now: testEpoch}
+	f := newTestFrontier(t, Config{Clock: clk.Now})
+
+	var (
+		stale   = addLink(t, f)
+		overdue = addLink(t, f)
+		scored  = addLink(t, f)
+		fresh   = addLink(t, f)
+	)
+	mustRecord(t, f, Outcome{Link: *stale, Kind: OutcomeChanged, FetchedAt: testEpoch.Add(-120 * time.Hour)})
+	mustRecord(t, f, Outcome{Link: *overdue, Kind: OutcomeChanged, FetchedAt: testEpoch.Add(-36 * time.Hour)})
+	mustUpdateScore(t, f, scored.ID, 1)
+	if err := f.Hint(fresh, Hint{Priority: 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []uuid.UUID{stale.ID, scored.ID, overdue.ID, fresh.ID}
+	got := dueIDs(t, f, 0)
+	if len(got) != len(exp) {
+		t.Fatalf("expected %d due links; got %d", len(exp), len(got))
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("position %d: expected %s; got %s", i, exp[i], got[i])
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	clk := &testClock{now: testEpoch}
+	f := newTestFrontier(t, Config{Clock: clk.Now})
+	link := &graph.Link{ID: uuid.New(), URL: "https://example.com/"}
+
+	if err := f.Hint(link, Hint{ChangeInterval: 2 * time.Hour, Priority: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	e := lookup(t, f, link.ID)
+	if e.Link.URL != link.URL || e.Interval != 2*time.Hour || e.Priority != 1 {
+		t.Errorf("unexpected entry for a hinted link %+v", e)
+	}
+
+	mustRecord(t, f, Outcome{Link: *link, Kind: OutcomeChanged, FetchedAt: testEpoch})
+	clk.Advance(time.Minute)
+
+	// Once crawled, declared intervals no longer override observations
+	// and only modifications after the last crawl make the link due.
+	if err := f.Hint(link, Hint{ChangeInterval: 48 * time.Hour, LastModified: testEpoch.Add(-time.Hour), Priority: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if e = lookup(t, f, link.ID); e.Interval != 2*time.Hour || !e.NextCrawlAt.Equal(testEpoch.Add(2*time.Hour)) {
+		t.Errorf("expected the schedule to be kept; got %s, %s", e.Interval, e.NextCrawlAt)
+	}
+
+	if err := f.Hint(link, Hint{LastModified: testEpoch.Add(time.Second), Priority: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if e = lookup(t, f, link.ID); !e.NextCrawlAt.Equal(clk.Now()) {
+		t.Errorf("expected the modified link to be due now; got %s", e.NextCrawlAt)
+	}
+}
+
+func TestScoreTee(t *testing.T) {
+	f := newTestFrontier(t, Config{})
+	link := addLink(t, f)
+	next := new(recordingUpdater)
+	tee := f.ScoreTee(next)
+
+	if err := tee.UpdateScore(link.ID, 0.3); err != nil {
+		t.Fatal(err)
+	}
+	if err := tee.UpdateScore(uuid.New(), 0.7); err != nil {
+		t.Fatalf("expected unknown links to be ignored; got %v", err)
+	}
+	if len(next.scores) != 2 || next.scores[link.ID] != 0.3 {
+		t.Errorf("expected all scores to be forwarded; got %v", next.scores)
+	}
+	if e := lookup(t, f, link.ID); e.Score != 0.3 {
+		t.Errorf("expected score 0.3; got %v", e.Score)
+	}
+
+	next.err = errors.New("index unavailable")
+	if err := tee.UpdateScore(link.ID, 0.9); !errors.Is(err, next.err) {
+		t.Errorf("expected the forwarding error; got %v", err)
+	}
+	if e := lookup(t, f, link.ID); e.Score != 0.3 {
+		t.Errorf("expected the score not to be updated after an error; got %v", e.Score)
+	}
+}
+
+func TestInMemoryStoreMaxScore(t *testing.T) {
+	s := NewInMemoryStore()
+	a := &Entry{Link: graph.Link{ID: uuid.New()}, Score: 0.9}
+	b := &Entry{Link: graph.Link{ID: uuid.New()}, Score: 0.5}
+	for _, e := range []*Entry{a, b} {
+		if err := s.Upsert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, _ := s.MaxScore(); got != 0.9 {
+		t.Errorf("expected max score 0.9; got %v", got)
+	}
+
+	a.Score = 0.1
+	if err := s.Upsert(a); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.MaxScore(); got != 0.5 {
+		t.Errorf("expected the max score to be recalculated as 0.5; got %v", got)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	if _, err := New(Config{MinInterval: 2 * time.Hour, MaxInterval: time.Hour}); err == nil {
+		t.Error("expected an error when the min interval exceeds the max interval")
+	}
+}
+
+func newTestFrontier(t *testing.T, cfg Config) *Frontier {
+	if cfg.Clock == nil {
+		cfg.Clock = func() time.Time { return testEpoch }
+	}
+	f, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func addLink(t *testing.T, f *Frontier) *graph.Link {
+	t.Helper()
+	link := &graph.Link{ID: uuid.New(), URL: "https://example.com/" + uuid.New().String()}
+	if err := f.Add(link); err != nil {
+		t.Fatal(err)
+	}
+	return link
+}
+
+func mustRecord(t *testing.T, f *Frontier, o Outcome) {
+	t.Helper()
+	if err := f.Record(o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustUpdateScore(t *testing.T, f *Frontier, linkID uuid.UUID, score float64) {
+	t.Helper()
+	if err := f.UpdateScore(linkID, score); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lookup(t *testing.T, f *Frontier, linkID uuid.UUID) *Entry {
+	t.Helper()
+	e, found, err := f.cfg.Store.Lookup(linkID)
+	if err != nil {
+		t.Fatal(err)
+	} else if !found {
+		t.Fatalf("no entry for %s", linkID)
+	}
+	return e
+}
+
+func dueIDs(t *testing.T, f *Frontier, limit int) []uuid.UUID {
+	t.Helper()
+	it, err := f.Due(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []uuid.UUID
+	for it.Next() {
+		ids = append(ids, it.Link().ID)
+	}
+	return ids
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+type recordingUpdater struct {
+	scores map[uuid.UUID]float64
+	err    error
+}
+
+func (u *recordingUpdater) UpdateScore(linkID uuid.UUID, score float64) error {
+	if u.err != nil {
+		return u.err
+	}
+	if u.scores == nil {
+		u.scores = make(map[uuid.UUID]float64)
+	}
+	u.scores[linkID] = score
+	return nil
+}
@@ -0,0 +1,88 @@
+package frontier
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+)
+
+// ScheduleGraph is implemented by link graphs that can persist the crawl
+// schedules of their links. *db.DbGraph satisfies this interface.
+type ScheduleGraph interface {
+	UpdateCrawlSchedule(id uuid.UUID, schedule *graph.CrawlSchedule) error
+	FindScheduledLink(id uuid.UUID) (*graph.ScheduledLink, error)
+	DueLinks(dueBy time.Time) ([]*graph.ScheduledLink, error)
+	MaxScore() (float64, error)
+}
+
+// GraphStore is a frontier store that keeps the entries alongside the links
+// of a link graph so that crawl schedules survive restarts. The link details
+// of the returned entries always reflect the link graph. GraphStore is safe
+// for concurrent use if the underlying graph is.
+type GraphStore struct {
+	graph ScheduleGraph
+}
+
+// NewGraphStore returns a GraphStore backed by g.
+func NewGraphStore(g ScheduleGraph) *GraphStore {
+	return &GraphStore{graph: g}
+}
+
+// Upsert creates or replaces the entry for e.Link.ID. The link must exist in
+// the link graph.
+func (s *GraphStore) Upsert(e *Entry) error {
+	return s.graph.UpdateCrawlSchedule(e.Link.ID, &graph.CrawlSchedule{
+		NextCrawlAt:   e.NextCrawlAt,
+		Interval:      e.Interval,
+		LastCrawledAt: e.LastCrawledAt,
+		LastChangedAt: e.LastChangedAt,
+		Failures:      e.Failures,
+		Score:         e.Score,
+		Priority:      e.Priority,
+	})
+}
+
+// Lookup returns the entry for linkID.
+func (s *GraphStore) Lookup(linkID uuid.UUID) (*Entry, bool, error) {
+	sl, err := s.graph.FindScheduledLink(linkID)
+	if errors.Is(err, graph.ErrNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return entryFromScheduledLink(sl), true, nil
+}
+
+// Due returns all entries whose NextCrawlAt is not after t.
+func (s *GraphStore) Due(t time.Time) ([]*Entry, error) {
+	due, err := s.graph.DueLinks(t)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, len(due))
+	for i, sl := range due {
+		entries[i] = entryFromScheduledLink(sl)
+	}
+	return entries, nil
+}
+
+// MaxScore returns the highest score of any entry.
+func (s *GraphStore) MaxScore() (float64, error) {
+	return s.graph.MaxScore()
+}
+
+func entryFromScheduledLink(sl *graph.ScheduledLink) *Entry {
+	return &Entry{
+		Link:          sl.Link,
+		NextCrawlAt:   sl.Schedule.NextCrawlAt,
+		Interval:      sl.Schedule.Interval,
+		LastCrawledAt: sl.Schedule.LastCrawledAt,
+		LastChangedAt: sl.Schedule.LastChangedAt,
+		Failures:      sl.Schedule.Failures,
+		Score:         sl.Schedule.Score,
+		Priority:      sl.Schedule.Priority,
+	}
+}
@@ -0,0 +1,88 @@
+package frontier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InMemoryStore is an in-memory frontier store. It is safe for concurrent
+// use.
+type InMemoryStore struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]*Entry
+
+	// maxScore caches the highest entry score; it is recalculated when
+	// the entry holding it is downgraded.
+	maxScore      float64
+	maxScoreStale bool
+}
+
+// NewInMemoryStore returns a new, empty InMemoryStore.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		entries: make(map[uuid.UUID]*Entry),
+	}
+}
+
+// Upsert creates or replaces the entry for e.Link.ID.
+func (s *InMemoryStore) Upsert(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, exists := s.entries[e.Link.ID]; exists && old.Score == s.maxScore && e.Score < old.Score {
+		s.maxScoreStale = true
+	}
+	if e.Score > s.maxScore {
+		s.maxScore = e.Score
+	}
+
+	eCopy := *e
+	s.entries[e.Link.ID] = &eCopy
+	return nil
+}
+
+// Lookup returns a copy of the entry for linkID.
+func (s *InMemoryStore) Lookup(linkID uuid.UUID) (*Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, found := s.entries[linkID]
+	if !found {
+		return nil, false, nil
+	}
+	eCopy := *e
+	return &eCopy, true, nil
+}
+
+// Due returns copies of all entries whose NextCrawlAt is not after t.
+func (s *InMemoryStore) Due(t time.Time) ([]*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []*Entry
+	for _, e := range s.entries {
+		if !e.NextCrawlAt.After(t) {
+			eCopy := *e
+			due = append(due, &eCopy)
+		}
+	}
+	return due, nil
+}
+
+// MaxScore returns the highest score of any entry.
+func (s *InMemoryStore) MaxScore() (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxScoreStale {
+		s.maxScore, s.maxScoreStale = 0, false
+		for _, e := range s.entries {
+			if e.Score > s.maxScore {
+				s.maxScore = e.Score
+			}
+		}
+	}
+	return s.maxScore, nil
+}
@@ -2,19 +2,27 @@ package crawler
 
 import (
 	"context"
+	"errors"
 	"time"
+	"unicode/utf8"
 
 	"github.com/iamleson98/go-search/linkgraph/graph"
 	"github.com/iamleson98/go-search/pipeline"
 )
 
+// maxAnchorTextLen is the max number of characters of anchor text that is
+// recorded for each edge.
+const maxAnchorTextLen = 256
+
 type graphUpdater struct {
 	updater Graph
+	feeds   FeedRegistry
 }
 
-func newGraphUpdater(updater Graph) *graphUpdater {
+func newGraphUpdater(updater Graph, feeds FeedRegistry) *graphUpdater {
 	return &graphUpdater{
 		updater: updater,
+		feeds:   feeds,
 	}
 }
 
@@ -22,30 +30,70 @@ func (u *graphUpdater) Process(ctx context.Context, p pipeline.Payload) (pipelin
 	payload := p.(*crawlerPayload)
 
 	src := &graph.Link{
-		ID:          payload.LinkID,
-		URL:         payload.URL,
-		RetrievedAt: time.Now(),
+		ID:           payload.LinkID,
+		URL:          payload.URL,
+		RetrievedAt:  time.Now(),
+		ETag:         payload.ETag,
+		LastModified: payload.LastModified,
+		ContentHash:  payload.ContentHash,
+		Depth:        payload.Depth,
 	}
 	if err := u.updater.UpsertLink(src); err != nil {
 		return nil, err
 	}
 
+	// Unchanged pages only need their retrieval time refreshed; their
+	// outgoing edges are still current.
+	if payload.NotModified {
+		return p, nil
+	}
+
 	for _, dstLink := range payload.NoFollowLinks {
-		dst := &graph.Link{URL: dstLink}
+		dst := &graph.Link{URL: dstLink, Depth: graph.ChildDepth(payload.Depth)}
 		if err := u.updater.UpsertLink(dst); err != nil {
 			return nil, err
 		}
 	}
 
+	for _, feedLink := range payload.FeedLinks {
+		feed := &graph.Link{URL: feedLink, Depth: graph.ChildDepth(payload.Depth), Kind: graph.LinkKindFeed}
+		if err := u.updater.UpsertLink(feed); err != nil {
+			return nil, err
+		}
+		if u.feeds != nil {
+			u.feeds.AddFeed(feed)
+		}
+	}
+
+	anchorText := make(map[string]string, len(payload.LinkInfo))
+	for _, info := range payload.LinkInfo {
+		anchorText[info.URL] = truncateText(info.Text, maxAnchorTextLen)
+	}
+
 	removeEdgesOlderThan := time.Now()
 	for _, dstLink := range payload.Links {
-		dst := &graph.Link{URL: dstLink}
+		dst := &graph.Link{URL: dstLink, Depth: graph.ChildDepth(payload.Depth)}
 
 		if err := u.updater.UpsertLink(dst); err != nil {
 			return nil, err
 		}
 
-		if err := u.updater.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID}); err != nil {
+		if err := u.updater.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID, AnchorText: anchorText[dstLink]}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Links over the URL budget of their host only keep the edges to
+	// links that the graph already holds.
+	for _, dstLink := range payload.KnownOnlyLinks {
+		dst, err := u.updater.FindLinkByURL(dstLink)
+		if errors.Is(err, graph.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := u.updater.UpsertEdge(&graph.Edge{Src: src.ID, Dst: dst.ID, AnchorText: anchorText[dstLink]}); err != nil {
 			return nil, err
 		}
 	}
@@ -56,3 +104,11 @@ func (u *graphUpdater) Process(ctx context.Context, p pipeline.Payload) (pipelin
 
 	return p, nil
 }
+
+// truncateText returns the first maxLen characters of text.
+func truncateText(text string, maxLen int) string {
+	if utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+	return string([]rune(text)[:maxLen])
+}
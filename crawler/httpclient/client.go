@@ -0,0 +1,395 @@
+// Package httpclient provides a configurable HTTP client that satisfies the
+// crawler.URLGetter interface.
+package httpclient
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	defaultAccept              = "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1"
+	defaultAcceptEncoding      = "gzip, deflate, br"
+	defaultMaxIdleConns        = 512
+	defaultMaxIdleConnsPerHost = 4
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 10 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultMaxRedirects        = 10
+	defaultMaxRetries          = 2
+	defaultRetryBackoff        = 250 * time.Millisecond
+)
+
+// ErrPrivateTarget is returned when a request to a private network is
+// refused.
+var ErrPrivateTarget = errors.New("request to non-public address refused")
+
+// PrivateNetworkDetector is implemented by objects that can detect whether
+// a host belongs to a private network. *privnet.Detector satisfies this
+// interface.
+type PrivateNetworkDetector interface {
+	IsPrivate(host string) (bool, error)
+}
+
+// DialContextFunc establishes network connections. Its signature matches
+// the DialContext field of http.Transport.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// RedirectCheckFunc is invoked with the request for a redirect target
+// before the redirect is followed. Returning an error stops the client at
+// the redirect response and makes Do return the error.
+type RedirectCheckFunc func(req *http.Request) error
+
+type redirectCheckKey struct{}
+
+// WithRedirectCheck returns a copy of ctx that makes Client invoke check
+// for each redirect followed by requests that carry the returned context.
+func WithRedirectCheck(ctx context.Context, check RedirectCheckFunc) context.Context {
+	return context.WithValue(ctx, redirectCheckKey{}, check)
+}
+
+// Config encapsulates the settings for a Client.
+type Config struct {
+	// The product token and version that identify the crawler in the
+	// User-Agent header (e.g. "GoSearchBot/1.0"). Required.
+	UserAgent string
+
+	// A URL where site owners can find information about the crawler.
+	// If specified, it is appended to the User-Agent as "(+ContactURL)".
+	ContactURL string
+
+	// The value of the Accept header. Defaults to a value that prefers
+	// HTML documents.
+	Accept string
+
+	// An optional Accept-Language header value.
+	AcceptLanguage string
+
+	// Connection pooling limits. Zero values select sensible defaults
+	// except for MaxConnsPerHost where zero means no limit.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+
+	// Dial and TLS handshake timeouts. Default to 10s.
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+
+	// An optional dialer for establishing connections. If specified,
+	// DialTimeout is ignored. Use the dialer returned by
+	// privnet.Detector.Dialer to prevent connections to private networks,
+	// including ones reached via redirects or DNS rebinding. When a proxy
+	// is used, the dialer only sees the proxy address; see NetDetector.
+	DialContext DialContextFunc
+
+	// An optional detector for refusing requests to private networks
+	// when a proxy is used. The target host of every request, including
+	// the ones issued for redirects, is checked before it is sent.
+	NetDetector PrivateNetworkDetector
+
+	// An optional proxy URL for all requests. If not specified and
+	// ProxyFromEnvironment is set, the HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY environment variables are used.
+	ProxyURL             *url.URL
+	ProxyFromEnvironment bool
+
+	// An optional TLS configuration. If not specified, the system roots
+	// are used and TLS 1.2 is required.
+	TLSConfig *tls.Config
+
+	// EnableCookies enables an in-memory cookie jar that is shared by
+	// all requests issued by the client.
+	EnableCookies bool
+
+	// The max number of redirects to follow. Defaults to 10.
+	MaxRedirects int
+
+	// The max number of times a request is retried when the connection
+	// is reset or closed before a response is received. Defaults to 2;
+	// set to a negative value to disable retries.
+	MaxRetries int
+
+	// The delay before the first retry; it doubles after each attempt.
+	// Defaults to 250ms.
+	RetryBackoff time.Duration
+}
+
+func (cfg *Config) validate() error {
+	if cfg.UserAgent == "" {
+		return fmt.Errorf("httpclient: user agent not specified")
+	}
+	if cfg.Accept == "" {
+		cfg.Accept = defaultAccept
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = defaultMaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = defaultIdleConnTimeout
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	return nil
+}
+
+// Client is an HTTP client tuned for crawling. It sends a descriptive
+// User-Agent, prefers HTML content, transparently decodes gzip, deflate
+// and brotli responses and retries requests whose connection was reset.
+type Client struct {
+	cfg         Config
+	userAgent   string
+	client      *http.Client
+	checkTarget func(req *http.Request) error
+}
+
+// New returns a new Client using the provided config.
+func New(cfg Config) (*Client, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	dialContext := cfg.DialContext
+	if dialContext == nil {
+		dialContext = (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	transport := &http.Transport{
+		DialContext:         dialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		ForceAttemptHTTP2:   true,
+		// We advertise and decode the supported encodings ourselves.
+		DisableCompression: true,
+	}
+	switch {
+	case cfg.ProxyURL != nil:
+		transport.Proxy = http.ProxyURL(cfg.ProxyURL)
+	case cfg.ProxyFromEnvironment:
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+
+	checkTarget := func(*http.Request) error { return nil }
+	if transport.Proxy != nil && cfg.NetDetector != nil {
+		checkTarget = func(req *http.Request) error {
+			isPrivate, err := cfg.NetDetector.IsPrivate(req.URL.Hostname())
+			if err != nil {
+				return err
+			} else if isPrivate {
+				return ErrPrivateTarget
+			}
+			return nil
+		}
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			if err := checkTarget(req); err != nil {
+				return err
+			}
+			if check, ok := req.Context().Value(redirectCheckKey{}).(RedirectCheckFunc); ok {
+				return check(req)
+			}
+			return nil
+		},
+	}
+
+	if cfg.EnableCookies {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: %w", err)
+		}
+		client.Jar = jar
+	}
+
+	userAgent := cfg.UserAgent
+	if cfg.ContactURL != "" {
+		userAgent += " (+" + cfg.ContactURL + ")"
+	}
+
+	return &Client{
+		cfg:         cfg,
+		userAgent:   userAgent,
+		client:      client,
+		checkTarget: checkTarget,
+	}, nil
+}
+
+// Get issues a GET request for the specified URL.
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Do sends an HTTP request and returns an HTTP response with a decoded
+// body. Headers that are already present on req are not overwritten.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	setDefaultHeader(req.Header, "User-Agent", c.userAgent)
+	setDefaultHeader(req.Header, "Accept", c.cfg.Accept)
+	setDefaultHeader(req.Header, "Accept-Encoding", defaultAcceptEncoding)
+	if c.cfg.AcceptLanguage != "" {
+		setDefaultHeader(req.Header, "Accept-Language", c.cfg.AcceptLanguage)
+	}
+	if err := c.checkTarget(req); err != nil {
+		return nil, err
+	}
+
+	res, err := c.doWithRetries(req)
+	if err != nil {
+		return nil, err
+	}
+
+	decodeBody(res)
+	return res, nil
+}
+
+// CloseIdleConnections closes any idle pooled connections.
+func (c *Client) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+func (c *Client) doWithRetries(req *http.Request) (*http.Response, error) {
+	backoff := c.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.client.Do(req)
+		if err == nil {
+			return res, nil
+		}
+
+		// Requests with a body cannot be safely replayed.
+		if attempt >= c.cfg.MaxRetries || !isRetryable(err) || (req.Body != nil && req.Body != http.NoBody) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-req.Context().Done():
+			return nil, err
+		}
+	}
+}
+
+// isRetryable returns true for errors caused by the connection being reset
+// or closed before a response was received.
+func isRetryable(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func setDefaultHeader(h http.Header, key, value string) {
+	if h.Get(key) == "" {
+		h.Set(key, value)
+	}
+}
+
+// decodeBody replaces the body of res with a reader that decodes its
+// Content-Encoding and removes the encoding-related headers.
+func decodeBody(res *http.Response) {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+
+	var (
+		body    = res.Body
+		decoded io.Reader
+	)
+	switch encoding {
+	case "", "identity":
+		return
+	case "gzip", "x-gzip":
+		decoded = &lazyReader{init: func() (io.Reader, error) { return gzip.NewReader(body) }}
+	case "deflate":
+		decoded = &lazyReader{init: func() (io.Reader, error) { return zlib.NewReader(body) }}
+	case "br":
+		decoded = brotli.NewReader(body)
+	default:
+		// Leave unknown encodings for the caller to deal with.
+		return
+	}
+
+	res.Body = &decodedBody{Reader: decoded, closer: body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+// lazyReader defers the creation of a decoder until the first Read call so
+// that empty bodies (e.g. for 304 responses) do not cause errors.
+type lazyReader struct {
+	init func() (io.Reader, error)
+	r    io.Reader
+	err  error
+}
+
+func (lr *lazyReader) Read(p []byte) (int, error) {
+	if lr.r == nil && lr.err == nil {
+		lr.r, lr.err = lr.init()
+	}
+	if lr.err != nil {
+		return 0, lr.err
+	}
+	return lr.r.Read(p)
+}
+
+type decodedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	return b.closer.Close()
+}
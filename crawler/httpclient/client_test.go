@@ -0,0 +1,265 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestClientHeaders(t *testing.T) {
+	var got http.Header
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	})
+
+	c := mustNewClient(t, Config{
+		UserAgent:      "GoSearchBot/1.0",
+		ContactURL:     "https://example.com/bot",
+		AcceptLanguage: "en",
+	})
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "application/rss+xml")
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	exp := map[string]string{
+		"User-Agent":      "GoSearchBot/1.0 (+https://example.com/bot)",
+		"Accept":          "application/rss+xml",
+		"Accept-Encoding": defaultAcceptEncoding,
+		"Accept-Language": "en",
+	}
+	for key, val := range exp {
+		if got.Get(key) != val {
+			t.Errorf("expected %s header %q; got %q", key, val, got.Get(key))
+		}
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("expected the caller's request to be left untouched")
+	}
+}
+
+func TestClientDecodesBodies(t *testing.T) {
+	const content = "<html><body>hello</body></html>"
+
+	specs := []struct {
+		encoding string
+		encode   func(w io.Writer) io.WriteCloser
+	}{
+		{"", nil},
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+		{"br", func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }},
+	}
+
+	for _, spec := range specs {
+		spec := spec
+		srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if spec.encode == nil {
+				fmt.Fprint(w, content)
+				return
+			}
+			w.Header().Set("Content-Encoding", spec.encoding)
+			enc := spec.encode(w)
+			fmt.Fprint(enc, content)
+			_ = enc.Close()
+		})
+
+		res, err := mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0"}).Get(srv.URL)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", spec.encoding, err)
+			continue
+		}
+		body, err := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", spec.encoding, err)
+		} else if string(body) != content {
+			t.Errorf("%q: expected body %q; got %q", spec.encoding, content, body)
+		}
+		if res.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%q: expected the Content-Encoding header to be removed", spec.encoding)
+		}
+	}
+}
+
+func TestClientEmptyEncodedBody(t *testing.T) {
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	res, err := mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0"}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected status %d; got %d", http.StatusNotModified, res.StatusCode)
+	}
+}
+
+func TestClientRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		_, _ = fmt.Sscanf(r.URL.Path, "/%d", &n)
+		if n < 5 {
+			http.Redirect(w, r, fmt.Sprintf("%s/%d", srv.URL, n+1), http.StatusFound)
+		}
+	})
+
+	res, err := mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0"}).Get(srv.URL + "/0")
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Request.URL.Path != "/5" {
+		t.Errorf("expected to end up at /5; got %s", res.Request.URL.Path)
+	}
+	_ = res.Body.Close()
+
+	if _, err = mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0", MaxRedirects: 2}).Get(srv.URL + "/0"); err == nil {
+		t.Error("expected an error after too many redirects")
+	}
+
+	// Redirect checks attached to the request context can stop redirects.
+	errStop := errors.New("stop")
+	var checked []string
+	ctx := WithRedirectCheck(context.Background(), func(req *http.Request) error {
+		checked = append(checked, req.URL.Path)
+		if req.URL.Path == "/3" {
+			return errStop
+		}
+		return nil
+	})
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/1", nil)
+	if _, err = mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0"}).Do(req); !errors.Is(err, errStop) {
+		t.Errorf("expected the redirect check error; got %v", err)
+	}
+	if strings.Join(checked, ",") != "/2,/3" {
+		t.Errorf("unexpected redirect checks %v", checked)
+	}
+}
+
+func TestClientRetriesResetConnections(t *testing.T) {
+	var attempts int32
+	srv := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// Drop the connection without sending a response.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+
+	c := mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0", RetryBackoff: time.Millisecond})
+	res, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("expected 2 attempts; got %d", got)
+	}
+
+	atomic.StoreInt32(&attempts, 0)
+	c = mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0", MaxRetries: -1})
+	if _, err = c.Get(srv.URL); err == nil {
+		t.Error("expected an error with retries disabled")
+	}
+
+	// Requests with a body are not replayed.
+	atomic.StoreInt32(&attempts, 0)
+	c = mustNewClient(t, Config{UserAgent: "GoSearchBot/1.0", RetryBackoff: time.Millisecond})
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, bytes.NewReader([]byte("data")))
+	if _, err = c.Do(req); err == nil {
+		t.Error("expected an error for a request with a body")
+	}
+}
+
+func TestClientChecksTargetsBehindProxy(t *testing.T) {
+	var proxied int32
+	proxy := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&proxied, 1)
+		if r.URL.Host == "public.example" {
+			http.Redirect(w, r, "http://private.example/", http.StatusFound)
+		}
+	})
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	c := mustNewClient(t, Config{
+		UserAgent:   "GoSearchBot/1.0",
+		ProxyURL:    proxyURL,
+		NetDetector: fakeDetector{"private.example": true},
+	})
+
+	if _, err := c.Get("http://private.example/"); !errors.Is(err, ErrPrivateTarget) {
+		t.Errorf("expected ErrPrivateTarget; got %v", err)
+	}
+	if _, err := c.Get("http://public.example/"); !errors.Is(err, ErrPrivateTarget) {
+		t.Errorf("expected ErrPrivateTarget for the redirect; got %v", err)
+	}
+	if got := atomic.LoadInt32(&proxied); got != 1 {
+		t.Errorf("expected only the public request to reach the proxy; got %d", got)
+	}
+}
+
+func TestClientCustomDialer(t *testing.T) {
+	errDial := errors.New("dial refused")
+	c := mustNewClient(t, Config{
+		UserAgent: "GoSearchBot/1.0",
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errDial
+		},
+	})
+
+	if _, err := c.Get("http://example.com/"); !errors.Is(err, errDial) {
+		t.Errorf("expected the dialer error; got %v", err)
+	}
+}
+
+func TestNewRequiresUserAgent(t *testing.T) {
+	if _, err := New(Config{}); err == nil {
+		t.Error("expected an error for a missing user agent")
+	}
+}
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func mustNewClient(t *testing.T, cfg Config) *Client {
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.CloseIdleConnections)
+	return c
+}
+
+// fakeDetector maps host names to whether they are private.
+type fakeDetector map[string]bool
+
+func (d fakeDetector) IsPrivate(host string) (bool, error) {
+	return d[host], nil
+}
@@ -0,0 +1,189 @@
+// Package langid identifies the natural language of a text using character
+// n-gram profiles (Cavnar & Trenkle, 1994). The profiles are built from the
+// embedded sample texts so no external service or data files are required.
+package langid
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// profileSize is the number of top-ranked n-grams kept per profile.
+	profileSize = 400
+
+	// maxNGram is the length of the longest n-gram in a profile.
+	maxNGram = 3
+
+	// maxSampleRunes bounds the amount of text inspected by Detect.
+	maxSampleRunes = 4096
+
+	// minLetters is the min number of letters a text needs to contain for
+	// its language to be detected.
+	minLetters = 20
+)
+
+// profiles maps a language code to its n-gram ranks.
+var profiles = buildProfiles(samples)
+
+// scriptLanguages maps scripts that are (for our purposes) used by a single
+// language to that language.
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Arabic, "ar"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// Detect returns the ISO 639-1 code of the language text is most likely
+// written in, or an empty string if the language cannot be determined.
+func Detect(text string) string {
+	var (
+		counts  = make(map[string]int)
+		letters int
+		kana    int
+		han     int
+		sample  strings.Builder
+		runes   int
+	)
+
+	for _, r := range text {
+		if runes == maxSampleRunes {
+			break
+		}
+		runes++
+
+		if !unicode.IsLetter(r) {
+			sample.WriteRune(' ')
+			continue
+		}
+		letters++
+		sample.WriteRune(unicode.ToLower(r))
+
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		default:
+			for _, sl := range scriptLanguages {
+				if unicode.Is(sl.table, r) {
+					counts[sl.lang]++
+					break
+				}
+			}
+		}
+	}
+
+	if letters < minLetters {
+		return ""
+	}
+
+	// Japanese mixes kana with Han ideographs; text with Han but no kana
+	// is assumed to be Chinese.
+	if kana > 0 && (kana+han)*2 > letters {
+		return "ja"
+	} else if han*2 > letters {
+		return "zh"
+	}
+	for lang, n := range counts {
+		if n*2 > letters {
+			return lang
+		}
+	}
+
+	return closestProfile(makeProfile(sample.String(), profileSize))
+}
+
+// closestProfile returns the language whose profile has the smallest
+// out-of-place distance to the provided document profile.
+func closestProfile(docProfile map[string]int) string {
+	if len(docProfile) == 0 {
+		return ""
+	}
+
+	var (
+		bestLang string
+		bestDist = -1
+	)
+	for lang, langProfile := range profiles {
+		var dist int
+		for gram, rank := range docProfile {
+			langRank, found := langProfile[gram]
+			if !found {
+				dist += profileSize
+				continue
+			}
+			if rank > langRank {
+				dist += rank - langRank
+			} else {
+				dist += langRank - rank
+			}
+		}
+
+		// Ties are broken by language code to keep the results stable.
+		if bestDist == -1 || dist < bestDist || (dist == bestDist && lang < bestLang) {
+			bestLang, bestDist = lang, dist
+		}
+	}
+
+	return bestLang
+}
+
+// makeProfile returns the ranks of the size most frequent 1 to maxNGram
+// character n-grams in text. Words are padded with a single space on each
+// side so that n-grams at word boundaries are also captured.
+func makeProfile(text string, size int) map[string]int {
+	freq := make(map[string]int)
+	for _, word := range strings.Fields(text) {
+		padded := []rune(" " + word + " ")
+		for n := 1; n <= maxNGram; n++ {
+			for i := 0; i+n <= len(padded); i++ {
+				gram := string(padded[i : i+n])
+				if gram == " " {
+					continue
+				}
+				freq[gram]++
+			}
+		}
+	}
+
+	grams := make([]string, 0, len(freq))
+	for gram := range freq {
+		grams = append(grams, gram)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if freq[grams[i]] != freq[grams[j]] {
+			return freq[grams[i]] > freq[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > size {
+		grams = grams[:size]
+	}
+
+	ranks := make(map[string]int, len(grams))
+	for rank, gram := range grams {
+		ranks[gram] = rank
+	}
+	return ranks
+}
+
+func buildProfiles(samples map[string]string) map[string]map[string]int {
+	out := make(map[string]map[string]int, len(samples))
+	for lang, text := range samples {
+		out[lang] = makeProfile(strings.Map(func(r rune) rune {
+			if !unicode.IsLetter(r) {
+				return ' '
+			}
+			return unicode.ToLower(r)
+		}, text), profileSize)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package langid
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	specs := []struct {
+		text string
+		exp  string
+	}{
+		{"Yesterday we walked through the old market and bought fresh bread, cheese and some apples for the weekend.", "en"},
+		{"Gestern sind wir über den alten Markt gegangen und haben frisches Brot, Käse und einige Äpfel für das Wochenende gekauft.", "de"},
+		{"Hier nous avons traversé le vieux marché et acheté du pain frais, du fromage et quelques pommes pour le week-end.", "fr"},
+		{"Ayer caminamos por el viejo mercado y compramos pan fresco, queso y algunas manzanas para el fin de semana.", "es"},
+		{"Ieri abbiamo attraversato il vecchio mercato e comprato pane fresco, formaggio e alcune mele per il fine settimana.", "it"},
+		{"Вчера мы прошли через старый рынок и купили свежий хлеб, сыр и несколько яблок на выходные.", "ru"},
+		{"어제 우리는 오래된 시장을 걸으며 주말을 위해 신선한 빵과 치즈와 사과를 샀습니다.", "ko"},
+		{"Χθες περπατήσαμε στην παλιά αγορά και αγοράσαμε φρέσκο ψωμί, τυρί και μερικά μήλα.", "el"},
+		{"昨日私たちは古い市場を歩いて、週末のために新鮮なパンとチーズとりんごを買いました。", "ja"},
+		{"昨天我们走过老市场，为周末买了新鲜的面包、奶酪和一些苹果，还喝了一杯咖啡。", "zh"},
+		{"Too short", ""},
+		{"12345 67890 !!! ??? --- 12345 67890 !!! ??? ---", ""},
+	}
+
+	for _, spec := range specs {
+		if got := Detect(spec.text); got != spec.exp {
+			t.Errorf("Detect(%q): expected %q; got %q", spec.text, spec.exp, got)
+		}
+	}
+}
+
+func TestProfilesCoverSamples(t *testing.T) {
+	for lang, text := range samples {
+		if got := Detect(text); got != lang {
+			t.Errorf("expected the %q sample to be detected as %q; got %q", lang, lang, got)
+		}
+	}
+}
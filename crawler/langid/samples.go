@@ -0,0 +1,197 @@
+package langid
+
+// samples contains the training texts for the languages written in the
+// Latin and Cyrillic scripts, which cannot be told apart by script alone.
+var samples = map[string]string{
+	"en": `The city is located on the banks of the river and has a population of
+about two hundred thousand people. It was founded in the twelfth century
+and quickly became an important centre of trade between the north and the
+south of the country. Today the town is known for its university, its
+historic buildings and the many festivals that take place during the
+summer. Visitors who want to learn more about the history of the region
+should not miss the museum, which is open every day of the week except
+Monday. The government announced that the new bridge will be finished by
+the end of next year, and that it would help to reduce the traffic in the
+old town. Most of the people who live here work in the service industry,
+while others are employed by the companies that have their headquarters in
+the business district. There are also several parks where families can
+spend their free time and children can play safely. What do you think
+about this? We would like to hear from you, so please share your thoughts
+with us and with the other readers of this page.`,
+
+	"de": `Die Stadt liegt am Ufer des Flusses und hat etwa zweihunderttausend
+Einwohner. Sie wurde im zwölften Jahrhundert gegründet und entwickelte sich
+schnell zu einem wichtigen Handelszentrum zwischen dem Norden und dem Süden
+des Landes. Heute ist die Stadt für ihre Universität, ihre historischen
+Gebäude und die vielen Feste bekannt, die im Sommer stattfinden. Besucher,
+die mehr über die Geschichte der Region erfahren möchten, sollten das
+Museum nicht verpassen, das außer montags jeden Tag geöffnet ist. Die
+Regierung hat angekündigt, dass die neue Brücke bis zum Ende des nächsten
+Jahres fertig sein wird und dass sie dazu beitragen soll, den Verkehr in
+der Altstadt zu verringern. Die meisten Menschen, die hier leben, arbeiten
+im Dienstleistungssektor, während andere bei den Unternehmen beschäftigt
+sind, die ihren Sitz im Geschäftsviertel haben. Es gibt auch mehrere Parks,
+in denen Familien ihre Freizeit verbringen und Kinder sicher spielen
+können. Was denken Sie darüber? Wir würden uns freuen, von Ihnen zu hören.`,
+
+	"fr": `La ville est située sur les rives du fleuve et compte environ deux cent
+mille habitants. Elle a été fondée au douzième siècle et est rapidement
+devenue un centre important du commerce entre le nord et le sud du pays.
+Aujourd'hui, la ville est connue pour son université, ses bâtiments
+historiques et les nombreux festivals qui ont lieu pendant l'été. Les
+visiteurs qui veulent en savoir plus sur l'histoire de la région ne
+doivent pas manquer le musée, qui est ouvert tous les jours de la semaine
+sauf le lundi. Le gouvernement a annoncé que le nouveau pont sera terminé
+d'ici la fin de l'année prochaine et qu'il devrait permettre de réduire la
+circulation dans la vieille ville. La plupart des gens qui vivent ici
+travaillent dans le secteur des services, tandis que d'autres sont
+employés par les entreprises qui ont leur siège dans le quartier des
+affaires. Il y a aussi plusieurs parcs où les familles peuvent passer leur
+temps libre. Qu'en pensez-vous? Nous aimerions avoir votre avis.`,
+
+	"es": `La ciudad está situada a orillas del río y tiene una población de unos
+doscientos mil habitantes. Fue fundada en el siglo doce y se convirtió
+rápidamente en un importante centro de comercio entre el norte y el sur
+del país. Hoy en día la ciudad es conocida por su universidad, sus
+edificios históricos y los numerosos festivales que se celebran durante el
+verano. Los visitantes que quieran saber más sobre la historia de la
+región no deben perderse el museo, que está abierto todos los días de la
+semana excepto los lunes. El gobierno anunció que el nuevo puente estará
+terminado a finales del próximo año y que ayudará a reducir el tráfico en
+el casco antiguo. La mayoría de las personas que viven aquí trabajan en el
+sector de los servicios, mientras que otras están empleadas por las
+empresas que tienen su sede en el distrito financiero. También hay varios
+parques donde las familias pueden pasar su tiempo libre y los niños pueden
+jugar con seguridad. ¿Qué opina usted? Nos gustaría conocer su opinión.`,
+
+	"it": `La città si trova sulle rive del fiume e ha una popolazione di circa
+duecentomila abitanti. Fu fondata nel dodicesimo secolo e divenne presto un
+importante centro di commercio tra il nord e il sud del paese. Oggi la
+città è conosciuta per la sua università, i suoi edifici storici e i molti
+festival che si svolgono durante l'estate. I visitatori che vogliono
+saperne di più sulla storia della regione non dovrebbero perdere il museo,
+che è aperto tutti i giorni della settimana tranne il lunedì. Il governo
+ha annunciato che il nuovo ponte sarà completato entro la fine del
+prossimo anno e che contribuirà a ridurre il traffico nel centro storico.
+La maggior parte delle persone che vivono qui lavora nel settore dei
+servizi, mentre altre sono impiegate dalle aziende che hanno la loro sede
+nel quartiere degli affari. Ci sono anche diversi parchi dove le famiglie
+possono trascorrere il loro tempo libero e i bambini possono giocare in
+sicurezza. Cosa ne pensate? Ci piacerebbe conoscere la vostra opinione.`,
+
+	"pt": `A cidade está localizada nas margens do rio e tem uma população de cerca
+de duzentos mil habitantes. Foi fundada no século doze e rapidamente se
+tornou um importante centro de comércio entre o norte e o sul do país.
+Hoje a cidade é conhecida pela sua universidade, pelos seus edifícios
+históricos e pelos muitos festivais que acontecem durante o verão. Os
+visitantes que querem saber mais sobre a história da região não devem
+perder o museu, que está aberto todos os dias da semana, exceto às
+segundas-feiras. O governo anunciou que a nova ponte ficará pronta até ao
+final do próximo ano e que irá ajudar a reduzir o trânsito na cidade
+velha. A maioria das pessoas que vivem aqui trabalha no setor de serviços,
+enquanto outras são empregadas pelas empresas que têm a sua sede no
+bairro comercial. Há também vários parques onde as famílias podem passar o
+seu tempo livre e as crianças podem brincar em segurança. O que você acha
+disso? Gostaríamos muito de saber a sua opinião.`,
+
+	"nl": `De stad ligt aan de oever van de rivier en heeft ongeveer tweehonderdduizend
+inwoners. Ze werd in de twaalfde eeuw gesticht en werd al snel een
+belangrijk handelscentrum tussen het noorden en het zuiden van het land.
+Tegenwoordig staat de stad bekend om haar universiteit, haar historische
+gebouwen en de vele festivals die in de zomer plaatsvinden. Bezoekers die
+meer willen weten over de geschiedenis van de regio mogen het museum niet
+missen, dat elke dag van de week open is behalve op maandag. De regering
+heeft aangekondigd dat de nieuwe brug tegen het einde van volgend jaar
+klaar zal zijn en dat die moet helpen om het verkeer in de oude stad te
+verminderen. De meeste mensen die hier wonen werken in de dienstensector,
+terwijl anderen werken bij de bedrijven die hun hoofdkantoor in het
+zakendistrict hebben. Er zijn ook verschillende parken waar gezinnen hun
+vrije tijd kunnen doorbrengen en kinderen veilig kunnen spelen. Wat vindt
+u daarvan? We horen graag van u.`,
+
+	"sv": `Staden ligger vid flodens strand och har omkring tvåhundratusen invånare.
+Den grundades på tolvhundratalet och blev snabbt ett viktigt centrum för
+handeln mellan norra och södra delen av landet. I dag är staden känd för
+sitt universitet, sina historiska byggnader och de många festivaler som
+äger rum under sommaren. Besökare som vill veta mer om regionens historia
+bör inte missa museet, som är öppet alla dagar i veckan utom måndagar.
+Regeringen meddelade att den nya bron kommer att vara klar i slutet av
+nästa år och att den ska bidra till att minska trafiken i gamla stan. De
+flesta människor som bor här arbetar inom tjänstesektorn, medan andra är
+anställda av de företag som har sitt huvudkontor i affärsdistriktet. Det
+finns också flera parker där familjer kan tillbringa sin fritid och där
+barn kan leka tryggt. Vad tycker du om det? Vi vill gärna höra från dig,
+så dela gärna dina tankar med oss och med andra läsare av denna sida.`,
+
+	"pl": `Miasto położone jest nad brzegiem rzeki i liczy około dwustu tysięcy
+mieszkańców. Zostało założone w dwunastym wieku i szybko stało się ważnym
+ośrodkiem handlu między północą a południem kraju. Dziś miasto znane jest
+ze swojego uniwersytetu, zabytkowych budynków oraz licznych festiwali,
+które odbywają się latem. Turyści, którzy chcą dowiedzieć się więcej o
+historii regionu, nie powinni pominąć muzeum, które jest otwarte codziennie
+z wyjątkiem poniedziałku. Rząd ogłosił, że nowy most zostanie ukończony do
+końca przyszłego roku i że pomoże zmniejszyć ruch na starym mieście.
+Większość ludzi, którzy tu mieszkają, pracuje w sektorze usług, podczas
+gdy inni są zatrudnieni przez firmy mające siedzibę w dzielnicy
+biznesowej. Jest tu także kilka parków, w których rodziny mogą spędzać
+wolny czas, a dzieci mogą się bezpiecznie bawić. Co o tym myślisz? Chętnie
+poznamy twoją opinię, więc podziel się nią z nami i innymi czytelnikami.`,
+
+	"tr": `Şehir nehrin kıyısında yer almaktadır ve yaklaşık iki yüz bin nüfusa
+sahiptir. On ikinci yüzyılda kurulmuş ve kısa sürede ülkenin kuzeyi ile
+güneyi arasında önemli bir ticaret merkezi haline gelmiştir. Bugün şehir
+üniversitesi, tarihi binaları ve yaz boyunca düzenlenen birçok festivali
+ile tanınmaktadır. Bölgenin tarihi hakkında daha fazla bilgi edinmek
+isteyen ziyaretçiler, pazartesi günleri hariç her gün açık olan müzeyi
+kaçırmamalıdır. Hükümet, yeni köprünün gelecek yılın sonuna kadar
+tamamlanacağını ve eski şehirdeki trafiği azaltmaya yardımcı olacağını
+açıkladı. Burada yaşayan insanların çoğu hizmet sektöründe çalışırken,
+diğerleri merkezi iş bölgesinde bulunan şirketlerde çalışmaktadır. Ayrıca
+ailelerin boş zamanlarını geçirebileceği ve çocukların güvenle oynayabileceği
+birkaç park bulunmaktadır. Bu konuda ne düşünüyorsunuz? Görüşlerinizi
+bizimle ve bu sayfanın diğer okuyucularıyla paylaşmanızı isteriz.`,
+
+	"id": `Kota ini terletak di tepi sungai dan memiliki jumlah penduduk sekitar dua
+ratus ribu orang. Kota ini didirikan pada abad kedua belas dan dengan cepat
+menjadi pusat perdagangan yang penting antara bagian utara dan selatan
+negara. Saat ini kota tersebut dikenal karena universitasnya, bangunan
+bersejarahnya, dan banyak festival yang diadakan selama musim panas.
+Pengunjung yang ingin mengetahui lebih banyak tentang sejarah daerah ini
+tidak boleh melewatkan museum, yang buka setiap hari kecuali hari Senin.
+Pemerintah mengumumkan bahwa jembatan baru akan selesai pada akhir tahun
+depan dan akan membantu mengurangi kemacetan di kota tua. Sebagian besar
+orang yang tinggal di sini bekerja di sektor jasa, sementara yang lain
+bekerja di perusahaan yang berkantor pusat di kawasan bisnis. Ada juga
+beberapa taman tempat keluarga dapat menghabiskan waktu luang dan
+anak-anak dapat bermain dengan aman. Bagaimana pendapat Anda? Kami ingin
+mendengar dari Anda, jadi silakan bagikan pemikiran Anda dengan kami.`,
+
+	"ru": `Город расположен на берегу реки, и его население составляет около двухсот
+тысяч человек. Он был основан в двенадцатом веке и быстро стал важным
+центром торговли между севером и югом страны. Сегодня город известен своим
+университетом, историческими зданиями и многочисленными фестивалями,
+которые проходят летом. Посетителям, которые хотят узнать больше об
+истории региона, не стоит пропускать музей, который открыт каждый день,
+кроме понедельника. Правительство объявило, что новый мост будет построен
+к концу следующего года и что он поможет уменьшить движение в старом
+городе. Большинство людей, которые живут здесь, работают в сфере услуг,
+в то время как другие заняты в компаниях, штаб-квартиры которых
+находятся в деловом районе. Здесь также есть несколько парков, где семьи
+могут проводить свободное время, а дети могут безопасно играть. Что вы
+об этом думаете? Мы будем рады узнать ваше мнение, поэтому поделитесь
+своими мыслями с нами и с другими читателями этой страницы.`,
+
+	"uk": `Місто розташоване на березі річки, і його населення становить близько
+двохсот тисяч осіб. Воно було засноване у дванадцятому столітті і швидко
+стало важливим центром торгівлі між північчю та півднем країни. Сьогодні
+місто відоме своїм університетом, історичними будівлями та численними
+фестивалями, які відбуваються влітку. Відвідувачам, які хочуть дізнатися
+більше про історію регіону, не варто пропускати музей, який відкритий
+щодня, крім понеділка. Уряд оголосив, що новий міст буде збудовано до
+кінця наступного року і що він допоможе зменшити рух у старому місті.
+Більшість людей, які тут живуть, працюють у сфері послуг, тоді як інші
+працюють у компаніях, що мають свої головні офіси в діловому районі. Тут
+також є кілька парків, де родини можуть проводити вільний час, а діти
+можуть безпечно гратися. Що ви про це думаєте? Ми будемо раді дізнатися
+вашу думку, тож поділіться своїми думками з нами та іншими читачами.`,
+}
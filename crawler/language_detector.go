@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"context"
+
+	"github.com/iamleson98/go-search/crawler/langid"
+	"github.com/iamleson98/go-search/pipeline"
+	"github.com/iamleson98/go-search/textindexer/index"
+)
+
+// languageDetector identifies the language of a page's text content. The
+// language declared by the page (via <html lang> or the Content-Language
+// header) is honored; detection only kicks in for pages without one.
+type languageDetector struct{}
+
+func newLanguageDetector() *languageDetector {
+	return &languageDetector{}
+}
+
+func (ld *languageDetector) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
+
+	if payload.Language = index.NormalizeLanguage(payload.DeclaredLanguage); payload.Language == "" {
+		payload.Language = index.NormalizeLanguage(payload.Header.Get("Content-Language"))
+	}
+	if payload.Language == "" {
+		payload.Language = langid.Detect(payload.Title + " " + payload.TextContent)
+	}
+
+	return payload, nil
+}
@@ -1,79 +1,161 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
 	"net/url"
 	"regexp"
+	"strings"
 
+	"github.com/iamleson98/go-search/crawler/feed"
+	"github.com/iamleson98/go-search/crawler/traps"
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/linkgraph/graph"
 	"github.com/iamleson98/go-search/pipeline"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 var (
 	exclusionRegex = regexp.MustCompile(`(?i)\.(?:jpg|jpeg|png|gif|ico|css|js)$`)
-	baseHrefRegex  = regexp.MustCompile(`(?i)<base.*?href\s*?=\s*?"(.*?)\s*?"`)
-	findLinkRegex  = regexp.MustCompile(`(?i)<a.*?href\s*?=\s*?"\s*?(.*?)\s*?".*?>`)
-	nofollowRegex  = regexp.MustCompile(`(?i)rel\s*?=\s*?"?nofollow"?`)
 )
 
 type linkExtractor struct {
 	netDetector PrivateNetworkDetector
+	robots      RobotsPolicy
+	scope       ScopePolicy
+	traps       TrapDetector
+	normalizer  *urlnorm.Normalizer
+
+	// exclude matches links to resources that cannot contain HTML.
+	exclude *regexp.Regexp
 }
 
-func newLinkExtractor(netDetector PrivateNetworkDetector) *linkExtractor {
+func newLinkExtractor(netDetector PrivateNetworkDetector, robots RobotsPolicy, scope ScopePolicy, trapDetector TrapDetector, normalizer *urlnorm.Normalizer, exclude *regexp.Regexp) *linkExtractor {
 	return &linkExtractor{
 		netDetector: netDetector,
+		robots:      robots,
+		scope:       scope,
+		traps:       trapDetector,
+		normalizer:  normalizer,
+		exclude:     exclude,
 	}
 }
 
+// rawLink is a link as it appears in the document, before resolution.
+type rawLink struct {
+	href string
+	text string
+	rel  string
+}
+
 func (le *linkExtractor) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
 
 	relTo, err := url.Parse(payload.URL)
 	if err != nil {
 		return nil, err
 	}
 
-	content := payload.RawContent.String()
-
-	if baseMatch := baseHrefRegex.FindStringSubmatch(content); len(baseMatch) == 2 {
-		if base := resolveURL(relTo, ensureHasTrailingSlash(baseMatch[1])); base != nil {
+	doc := scanLinks(payload.RawContent.Bytes())
+	if doc.baseHref != "" {
+		if base := resolveURL(relTo, ensureHasTrailingSlash(doc.baseHref)); base != nil {
 			relTo = base
 		}
 	}
 
-	seenMap := make(map[string]struct{})
-	for _, match := range findLinkRegex.FindAllStringSubmatch(content, -1) {
-		link := resolveURL(relTo, match[1])
-		if !le.retainLink(relTo.Hostname(), link) {
+	if doc.canonical != "" {
+		if canonical := le.normalize(resolveURL(relTo, doc.canonical)); canonical != nil {
+			payload.CanonicalURL = canonical.String()
+		}
+	}
+
+	seenMap := make(map[string]int)
+	for _, raw := range doc.links {
+		link := le.normalize(resolveURL(relTo, raw.href))
+		if !le.retainLink(relTo.Hostname(), link, graph.ChildDepth(payload.Depth)) {
 			continue
 		}
 
-		link.Fragment = ""
 		linkStr := link.String()
-		if _, seen := seenMap[linkStr]; seen {
+		if idx, seen := seenMap[linkStr]; seen {
+			// Keep the first non-empty anchor text for duplicate links.
+			if info := &payload.LinkInfo[idx]; info.Text == "" {
+				info.Text = raw.text
+			}
 			continue
 		}
 
-		if exclusionRegex.MatchString(linkStr) {
+		if le.exclude.MatchString(linkStr) {
 			continue
 		}
 
-		if exclusionRegex.MatchString(linkStr) {
+		// Links are charged against the URL budget of their host once
+		// the trap heuristics have passed them. Followed links over the
+		// budget are kept for links that are already in the graph so
+		// that their existing edges are not removed.
+		nofollow := hasRelToken(raw.rel, "nofollow")
+		reason := traps.ReasonNone
+		if le.traps != nil {
+			reason = le.traps.Admit(link)
+		}
+		if reason != traps.ReasonNone && (reason != traps.ReasonHostBudget || nofollow) {
 			continue
 		}
 
-		seenMap[linkStr] = struct{}{}
-		if nofollowRegex.MatchString(match[0]) {
+		seenMap[linkStr] = len(payload.LinkInfo)
+		payload.LinkInfo = append(payload.LinkInfo, linkInfo{URL: linkStr, Text: raw.text, Rel: raw.rel})
+		if reason == traps.ReasonHostBudget {
+			payload.KnownOnlyLinks = append(payload.KnownOnlyLinks, linkStr)
+		} else if nofollow {
 			payload.NoFollowLinks = append(payload.NoFollowLinks, linkStr)
 		} else {
 			payload.Links = append(payload.Links, linkStr)
 		}
 	}
 
+	seenFeeds := make(map[string]bool)
+	for _, href := range doc.feeds {
+		link := le.normalize(resolveURL(relTo, href))
+		if !le.retainLink(relTo.Hostname(), link, graph.ChildDepth(payload.Depth)) {
+			continue
+		}
+
+		linkStr := link.String()
+		if seenFeeds[linkStr] {
+			continue
+		}
+		if le.traps != nil && le.traps.Admit(link) != traps.ReasonNone {
+			continue
+		}
+
+		seenFeeds[linkStr] = true
+		payload.FeedLinks = append(payload.FeedLinks, linkStr)
+	}
+
 	return payload, nil
 }
 
-func (le *linkExtractor) retainLink(srcHost string, link *url.URL) bool {
+// normalize returns the normalized form of link or nil if link is nil or
+// cannot be normalized.
+func (le *linkExtractor) normalize(link *url.URL) *url.URL {
+	if link == nil {
+		return nil
+	}
+
+	normalized, err := le.normalizer.Normalize(link)
+	if err != nil {
+		return nil
+	}
+	return normalized
+}
+
+// retainLink returns true if link, which is distance links away from the
+// crawl seeds, should be added to the link graph.
+func (le *linkExtractor) retainLink(srcHost string, link *url.URL, distance int) bool {
 	if link == nil {
 		return false
 	}
@@ -82,7 +164,20 @@ func (le *linkExtractor) retainLink(srcHost string, link *url.URL) bool {
 		return false
 	}
 
-	if link.Hostname() == srcHost {
+	if le.scope != nil && !le.scope.InScope(link, distance) {
+		return false
+	}
+
+	// Only consult rules that have already been retrieved; fetching
+	// robots.txt files for every discovered host would stall extraction.
+	// The fetcher performs the authoritative check.
+	if le.robots != nil {
+		if allowed, known := le.robots.IsAllowedCached(link); known && !allowed {
+			return false
+		}
+	}
+
+	if strings.EqualFold(link.Hostname(), srcHost) {
 		return true
 	}
 
@@ -93,6 +188,147 @@ func (le *linkExtractor) retainLink(srcHost string, link *url.URL) bool {
 	return true
 }
 
+// scannedLinks contains the link-related information extracted from a
+// document by scanLinks.
+type scannedLinks struct {
+	baseHref  string
+	canonical string
+	links     []rawLink
+	feeds     []string
+}
+
+// scanLinks tokenizes an HTML document and collects the targets of <a>,
+// <area>, <iframe> and <link rel=canonical|alternate> elements as well as
+// the first <base href>. Alternate links to RSS and Atom feeds are collected
+// separately as feeds are polled rather than crawled. Comments and the
+// contents of <script> and <style> elements are skipped by the tokenizer.
+func scanLinks(content []byte) scannedLinks {
+	var (
+		res      scannedLinks
+		z        = html.NewTokenizer(bytes.NewReader(content))
+		anchor   *rawLink
+		textBuf  strings.Builder
+		altBuf   strings.Builder
+		flushTag = func() {
+			if anchor == nil {
+				return
+			}
+			anchor.text = collapseSpace(textBuf.String())
+			if anchor.text == "" {
+				anchor.text = collapseSpace(altBuf.String())
+			}
+			res.links = append(res.links, *anchor)
+			anchor = nil
+			textBuf.Reset()
+			altBuf.Reset()
+		}
+	)
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// io.EOF or a malformed document; either way we are done.
+			flushTag()
+			return res
+		case html.TextToken:
+			if anchor != nil {
+				textBuf.Write(z.Text())
+				textBuf.WriteByte(' ')
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := atom.Lookup(name)
+			if tag == atom.A && tt == html.StartTagToken {
+				// Anchors cannot be nested; an unclosed anchor ends here.
+				flushTag()
+			}
+			if !hasAttr {
+				continue
+			}
+
+			attrs := readAttrs(z)
+			switch tag {
+			case atom.A:
+				if href, ok := attrs["href"]; ok {
+					anchor = &rawLink{href: href, rel: attrs["rel"]}
+					if tt == html.SelfClosingTagToken {
+						flushTag()
+					}
+				}
+			case atom.Area:
+				if href, ok := attrs["href"]; ok {
+					res.links = append(res.links, rawLink{href: href, text: collapseSpace(attrs["alt"]), rel: attrs["rel"]})
+				}
+			case atom.Iframe:
+				if src, ok := attrs["src"]; ok {
+					res.links = append(res.links, rawLink{href: src})
+				}
+			case atom.Link:
+				rel := attrs["rel"]
+				switch {
+				case hasRelToken(rel, "canonical"):
+					if res.canonical == "" {
+						res.canonical = attrs["href"]
+					}
+				case hasRelToken(rel, "alternate") && feed.IsFeedType(attrs["type"]):
+					if href, ok := attrs["href"]; ok {
+						res.feeds = append(res.feeds, href)
+					}
+				case hasRelToken(rel, "alternate"):
+					if href, ok := attrs["href"]; ok {
+						res.links = append(res.links, rawLink{href: href, text: collapseSpace(attrs["title"]), rel: rel})
+					}
+				}
+			case atom.Base:
+				if res.baseHref == "" {
+					res.baseHref = attrs["href"]
+				}
+			case atom.Img:
+				if anchor != nil {
+					altBuf.WriteString(attrs["alt"])
+					altBuf.WriteByte(' ')
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); atom.Lookup(name) == atom.A {
+				flushTag()
+			}
+		}
+	}
+}
+
+// readAttrs returns the attributes of the current tag with lower-cased keys
+// and trimmed values. Only the first occurrence of each attribute is kept.
+func readAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, val, more := z.TagAttr()
+		k := strings.ToLower(string(key))
+		if _, exists := attrs[k]; !exists {
+			attrs[k] = strings.TrimSpace(string(val))
+		}
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// hasRelToken returns true if the space-separated rel attribute value
+// contains token (case-insensitive).
+func hasRelToken(rel, token string) bool {
+	for _, f := range strings.Fields(rel) {
+		if strings.EqualFold(f, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
 func ensureHasTrailingSlash(s string) string {
 	if s[len(s)-1] != '/' {
 		return s + "/"
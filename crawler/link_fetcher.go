@@ -1,65 +1,415 @@
 package crawler
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/iamleson98/go-search/crawler/blobstore"
+	"github.com/iamleson98/go-search/crawler/frontier"
+	"github.com/iamleson98/go-search/crawler/httpclient"
+	"github.com/iamleson98/go-search/crawler/traps"
+	"github.com/iamleson98/go-search/crawler/warc"
+	"github.com/iamleson98/go-search/linkgraph/graph"
 	"github.com/iamleson98/go-search/pipeline"
 )
 
+const (
+	defaultFetchTimeout = 30 * time.Second
+	defaultMaxBodySize  = 8 << 20
+)
+
+// errBodyTooLarge is returned by readBody when a response body exceeds the
+// configured size limit and truncation is disabled.
+var errBodyTooLarge = errors.New("response body exceeds size limit")
+
+// fetchLimits bounds the resources spent on each request.
+type fetchLimits struct {
+	timeout     time.Duration
+	maxBodySize int64
+	truncate    bool
+}
+
+func makeFetchLimits(cfg Config) fetchLimits {
+	limits := fetchLimits{
+		timeout:     cfg.FetchTimeout,
+		maxBodySize: cfg.MaxBodySize,
+		truncate:    cfg.TruncateOversizedBodies,
+	}
+	if limits.timeout <= 0 {
+		limits.timeout = defaultFetchTimeout
+	}
+	if limits.maxBodySize <= 0 {
+		limits.maxBodySize = defaultMaxBodySize
+	}
+	return limits
+}
+
 type linkFetcher struct {
 	urlGetter   URLGetter
 	netDetector PrivateNetworkDetector
+	robots      RobotsPolicy
+	scope       ScopePolicy
+	traps       TrapDetector
+	frontier    FrontierRecorder
+	archive     ArchiveWriter
+	status      *fetchStatusTracker // nil if fetch statuses are not tracked
+	limits      fetchLimits
+
+	// If reprocess is set, no conditional requests are issued and pages
+	// are never flagged as unchanged.
+	reprocess bool
 }
 
-func newLinkFetcher(urlGetter URLGetter, netDetector PrivateNetworkDetector) *linkFetcher {
+func newLinkFetcher(urlGetter URLGetter, netDetector PrivateNetworkDetector, robots RobotsPolicy, scope ScopePolicy, trapDetector TrapDetector, frontier FrontierRecorder, archive ArchiveWriter, status *fetchStatusTracker, limits fetchLimits, reprocess bool) *linkFetcher {
 	return &linkFetcher{
 		urlGetter:   urlGetter,
 		netDetector: netDetector,
+		robots:      robots,
+		scope:       scope,
+		traps:       trapDetector,
+		frontier:    frontier,
+		archive:     archive,
+		status:      status,
+		limits:      limits,
+		reprocess:   reprocess,
 	}
 }
 
 func (lf *linkFetcher) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
 
-	// skip URLs that point to files that cannot contains html content.
-	if exclusionRegex.MatchString(payload.URL) {
-		return nil, nil
+	// The outcome is recorded for the link we were asked to fetch, even
+	// if the page was retrieved via redirects.
+	linkID, linkURL := payload.LinkID, payload.LinkURL
+
+	fetched, outcome, err := lf.fetchPayload(ctx, payload)
+
+	// Only abort the pipeline if it is shutting down; request failures
+	// and timeouts just cause the link to be skipped. Nothing is recorded
+	// for links interrupted by cancellation so that it does not count
+	// against them.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// Every exchange that produced a response is archived, including the
+	// ones whose payload is dropped below.
+	if lf.archive != nil && payload.StatusCode != 0 {
+		if err := archiveExchange(lf.archive, payload); err != nil {
+			return nil, err
+		}
 	}
+	reportCollectorFrom(ctx).recordFetch(linkURL, outcome, int64(payload.RawContent.Len()), payload.FetchDuration, err)
 
-	if isPrivate, err := lf.isPrivate(payload.URL); err != nil || isPrivate {
+	if err = lf.status.record(linkID, payload, outcome, err); err != nil {
+		return nil, err
+	}
+
+	if lf.frontier != nil {
+		err = lf.frontier.Record(frontier.Outcome{
+			Link: graph.Link{
+				ID:           linkID,
+				URL:          linkURL,
+				RetrievedAt:  payload.RetrievedAt,
+				ETag:         payload.ETag,
+				LastModified: payload.LastModified,
+				ContentHash:  payload.ContentHash,
+				Depth:        payload.Depth,
+				Kind:         payload.Kind,
+				Status:       payload.FetchStatus,
+			},
+			Kind:      outcome.frontierKind(),
+			FetchedAt: payload.FetchedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Avoid returning a typed nil pointer as a non-nil pipeline.Payload.
+	if fetched == nil {
 		return nil, nil
 	}
+	return fetched, nil
+}
 
-	res, err := lf.urlGetter.Get(payload.URL)
+// fetchPayload retrieves the content of the payload URL. Besides the payload
+// to pass on (nil if the link should be skipped) it returns the outcome of
+// the fetch and, for links that were dropped due to an error, its cause.
+func (lf *linkFetcher) fetchPayload(ctx context.Context, payload *crawlerPayload) (*crawlerPayload, FetchOutcome, error) {
+	if payload.Kind == graph.LinkKindFeed {
+		return nil, OutcomeFeed, nil
+	}
+
+	// The frontier, if any, is in charge of scheduling the retries of
+	// failed links.
+	if lf.status != nil && lf.frontier == nil && payload.FetchStatus.RetryAfter.After(time.Now()) {
+		return nil, OutcomeBackedOff, nil
+	}
+
+	// skip URLs that point to files that cannot contains html content.
+	if exclusionRegex.MatchString(payload.URL) {
+		return nil, OutcomeExcluded, nil
+	}
+
+	u, err := url.Parse(payload.URL)
 	if err != nil {
-		return nil, nil
+		return nil, OutcomeExcluded, err
+	}
+
+	// Links may have been added to the graph before the scope was
+	// narrowed so the scope is enforced here as well.
+	if lf.scope != nil && !lf.scope.InScope(u, payload.Depth) {
+		return nil, OutcomeOutOfScope, nil
+	}
+
+	// Trap URLs may have entered the graph before trap detection was
+	// enabled.
+	if lf.traps != nil {
+		if lf.traps.Check(u) != traps.ReasonNone {
+			return nil, OutcomeTrapped, nil
+		}
+	}
+
+	// Failures from here on are recorded against the link, so the attempt
+	// needs a timestamp even if no request is issued. fetch updates it
+	// with the time the request was sent.
+	payload.FetchedAt = time.Now()
+
+	// Hosts that cannot be resolved are fetch failures rather than
+	// private.
+	if isPrivate, err := lf.netDetector.IsPrivate(u.Hostname()); err != nil {
+		return nil, OutcomeFetchError, err
+	} else if isPrivate {
+		return nil, OutcomePrivate, nil
+	}
+
+	if lf.robots != nil {
+		if allowed, err := lf.robots.IsAllowed(ctx, u); err != nil || !allowed {
+			return nil, OutcomeRobotsDisallowed, err
+		}
+	}
+
+	// The timeout covers the whole exchange, including reading the body.
+	reqCtx, cancelFn := context.WithTimeout(ctx, lf.limits.timeout)
+	defer cancelFn()
+
+	// Redirect targets are checked before they are requested when the
+	// URLGetter supports it (see httpclient.WithRedirectCheck) and once
+	// the response headers arrive otherwise.
+	reqCtx = httpclient.WithRedirectCheck(reqCtx, func(req *http.Request) error {
+		return lf.checkRedirect(req.Context(), req.URL, payload.Depth)
+	})
+
+	res, err := lf.fetch(reqCtx, payload)
+	if rErr := (*redirectError)(nil); errors.As(err, &rErr) {
+		return nil, rErr.outcome, rErr.err
+	} else if errors.Is(err, warc.ErrNotArchived) || errors.Is(err, blobstore.ErrNotFound) {
+		return nil, OutcomeNotArchived, nil
+	} else if err != nil {
+		return nil, OutcomeFetchError, err
+	}
+
+	// Content is indexed under the URL we were redirected to.
+	if payload.RedirectChain = redirectChain(res); len(payload.RedirectChain) != 0 {
+		payload.URL = res.Request.URL.String()
+		if err = lf.checkRedirect(reqCtx, res.Request.URL, payload.Depth); err != nil {
+			_ = res.Body.Close()
+			payload.Truncated = true // the body is not read
+			rErr := err.(*redirectError)
+			return nil, rErr.outcome, rErr.err
+		}
 	}
 
-	_, err = io.Copy(&payload.RawContent, res.Body)
+	payload.Truncated, err = readBody(&payload.RawContent, res, lf.limits)
 	_ = res.Body.Close()
+	payload.FetchDuration = time.Since(payload.FetchedAt)
 	if err != nil {
-		return nil, err
+		payload.Truncated = true // only part of the body, if any, was read
+		return nil, OutcomeFetchError, fmt.Errorf("reading body: %w", err)
+	}
+
+	// RawContent always holds the decoded body.
+	payload.Header.Del("Content-Encoding")
+
+	if res.StatusCode == http.StatusNotModified {
+		updateValidators(payload, res.Header)
+		payload.NotModified = true
+		return payload, OutcomeNotModified, nil
 	}
 
 	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, nil
+		return nil, OutcomeNon2xx, fmt.Errorf("unexpected status code %d", res.StatusCode)
 	}
 
 	if contentType := res.Header.Get("Content-Type"); !strings.Contains(contentType, "html") {
-		return nil, nil
+		return nil, OutcomeNonHTML, nil
+	}
+
+	// Servers that do not support validators still let us detect
+	// unchanged content by comparing content hashes.
+	updateValidators(payload, res.Header)
+	contentHash := hashContent(payload.RawContent.Bytes())
+	payload.NotModified = payload.ContentHash == contentHash && lf.conditional(payload)
+	payload.ContentHash = contentHash
+
+	if payload.NotModified {
+		return payload, OutcomeNotModified, nil
+	}
+	return payload, OutcomeFetched, nil
+}
+
+// conditional returns true if the payload may be flagged as unchanged. Dead
+// links have been removed from the index so they are refetched in full.
+func (lf *linkFetcher) conditional(payload *crawlerPayload) bool {
+	return !lf.reprocess && !payload.FetchStatus.Dead
+}
+
+// redirectError is returned by checkRedirect for redirect targets that must
+// not be fetched.
+type redirectError struct {
+	outcome FetchOutcome
+	err     error
+}
+
+func (e *redirectError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("redirect target %s: %v", e.outcome, e.err)
 	}
+	return fmt.Sprintf("redirect target %s", e.outcome)
+}
 
-	return payload, nil
+func (e *redirectError) Unwrap() error { return e.err }
+
+// checkRedirect applies the scope and robots.txt rules to a redirect
+// target.
+func (lf *linkFetcher) checkRedirect(ctx context.Context, target *url.URL, depth int) error {
+	if lf.scope != nil && !lf.scope.InScope(target, depth) {
+		return &redirectError{outcome: OutcomeOutOfScope}
+	}
+	if lf.robots != nil {
+		if allowed, err := lf.robots.IsAllowed(ctx, target); err != nil || !allowed {
+			return &redirectError{outcome: OutcomeRobotsDisallowed, err: err}
+		}
+	}
+	return nil
 }
 
-func (lf *linkFetcher) isPrivate(URL string) (bool, error) {
-	u, err := url.Parse(URL)
+// fetch issues a (conditional) GET request for the payload URL and records
+// the response metadata on the payload.
+func (lf *linkFetcher) fetch(ctx context.Context, payload *crawlerPayload) (*http.Response, error) {
+	payload.FetchedAt = time.Now()
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			payload.RemoteAddr = info.Conn.RemoteAddr().String()
+		},
+		GotFirstResponseByte: func() {
+			payload.TimeToFirstByte = time.Since(payload.FetchedAt)
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, payload.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if payload.ETag != "" && lf.conditional(payload) {
+		req.Header.Set("If-None-Match", payload.ETag)
+	}
+	if payload.LastModified != "" && lf.conditional(payload) {
+		req.Header.Set("If-Modified-Since", payload.LastModified)
+	}
+
+	res, err := lf.urlGetter.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	payload.StatusCode = res.StatusCode
+	payload.Proto = res.Proto
+	payload.Header = res.Header.Clone()
+	if res.Request != nil {
+		// The request that produced the final response, including any
+		// headers added by the URLGetter.
+		payload.RequestMethod = res.Request.Method
+		payload.RequestHeader = res.Request.Header.Clone()
+	}
+	return res, nil
+}
+
+// readBody copies the (decompressed) response body into dst, reading at most
+// limits.maxBodySize bytes. If the body exceeds the limit it is either
+// truncated or errBodyTooLarge is returned, depending on limits.truncate.
+// Bodies that still carry a gzip or deflate Content-Encoding are decoded
+// here and the limit applies to the decoded size.
+func readBody(dst *bytes.Buffer, res *http.Response, limits fetchLimits) (truncated bool, err error) {
+	var body io.Reader = io.LimitReader(res.Body, limits.maxBodySize+1)
+
+	switch encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gzr, err := gzip.NewReader(body)
+		if err == io.EOF {
+			return false, nil // empty body
+		} else if err != nil {
+			return false, err
+		}
+		defer func() { _ = gzr.Close() }()
+		body = gzr
+	case "deflate":
+		zr, err := zlib.NewReader(body)
+		if err == io.EOF {
+			return false, nil // empty body
+		} else if err != nil {
+			return false, err
+		}
+		defer func() { _ = zr.Close() }()
+		body = zr
+	default:
+		return false, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	n, err := io.Copy(dst, io.LimitReader(body, limits.maxBodySize+1))
 	if err != nil {
 		return false, err
 	}
 
-	return lf.netDetector.IsPrivate(u.Hostname())
+	if n > limits.maxBodySize {
+		if !limits.truncate {
+			dst.Reset()
+			return false, errBodyTooLarge
+		}
+		dst.Truncate(int(limits.maxBodySize))
+		truncated = true
+	}
+
+	return truncated, nil
+}
+
+// updateValidators copies the ETag and Last-Modified headers of a response
+// to the payload. A 304 response may omit them in which case the existing
+// validators are kept.
+func updateValidators(payload *crawlerPayload, header http.Header) {
+	if etag := header.Get("ETag"); etag != "" {
+		payload.ETag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		payload.LastModified = lastModified
+	}
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,190 @@
+package crawler
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxHeadings bounds the number of headings collected for a single page.
+const maxHeadings = 64
+
+// publishDateLayouts lists the date formats accepted for publish dates.
+var publishDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006/01/02",
+	"20060102",
+}
+
+// publishDateMetaNames lists the (lower-cased) name/property values of
+// <meta> elements that carry the publish date of a page.
+var publishDateMetaNames = map[string]bool{
+	"article:published_time": true,
+	"og:published_time":      true,
+	"datepublished":          true,
+	"date":                   true,
+	"pubdate":                true,
+	"publishdate":            true,
+	"publish_date":           true,
+	"dc.date":                true,
+	"dc.date.issued":         true,
+	"dcterms.created":        true,
+	"dcterms.issued":         true,
+}
+
+// pageMetadata describes the metadata that a page declares about itself.
+type pageMetadata struct {
+	Description string
+	Keywords    []string
+
+	// Headings contains the text of the page's h1, h2 and h3 elements in
+	// document order.
+	Headings []string
+
+	// The OpenGraph title, description and image; Twitter card values are
+	// used for any property the page does not declare via OpenGraph.
+	OGTitle       string
+	OGDescription string
+	OGImage       string
+
+	PublishedAt time.Time
+}
+
+// scanMetadata extracts the metadata of an HTML document.
+func scanMetadata(content []byte) pageMetadata {
+	var (
+		meta       pageMetadata
+		z          = html.NewTokenizer(bytes.NewReader(content))
+		heading    atom.Atom
+		headingBuf strings.Builder
+		twitter    = make(map[string]string)
+	)
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// io.EOF or a malformed document; either way we are done.
+			if meta.OGTitle == "" {
+				meta.OGTitle = twitter["twitter:title"]
+			}
+			if meta.OGDescription == "" {
+				meta.OGDescription = twitter["twitter:description"]
+			}
+			if meta.OGImage == "" {
+				if meta.OGImage = twitter["twitter:image"]; meta.OGImage == "" {
+					meta.OGImage = twitter["twitter:image:src"]
+				}
+			}
+			return meta
+		case html.TextToken:
+			if heading != 0 {
+				headingBuf.Write(z.Text())
+				headingBuf.WriteByte(' ')
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch tag := atom.Lookup(name); tag {
+			case atom.H1, atom.H2, atom.H3:
+				if tt == html.StartTagToken {
+					heading = tag
+					headingBuf.Reset()
+				}
+			case atom.Meta:
+				if hasAttr {
+					meta.applyMetaTag(readAttrs(z), twitter)
+				}
+			case atom.Time:
+				if !hasAttr || !meta.PublishedAt.IsZero() {
+					continue
+				}
+				attrs := readAttrs(z)
+				if _, isPubDate := attrs["pubdate"]; isPubDate || strings.EqualFold(attrs["itemprop"], "datePublished") {
+					meta.PublishedAt = parsePublishDate(attrs["datetime"])
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if heading == 0 || atom.Lookup(name) != heading {
+				continue
+			}
+			if text := collapseSpace(headingBuf.String()); text != "" && len(meta.Headings) < maxHeadings {
+				meta.Headings = append(meta.Headings, text)
+			}
+			heading = 0
+		}
+	}
+}
+
+// applyMetaTag updates the metadata with the contents of a <meta> element.
+// Twitter card values are collected separately as they act as fallbacks.
+func (meta *pageMetadata) applyMetaTag(attrs map[string]string, twitter map[string]string) {
+	content := collapseSpace(attrs["content"])
+	if content == "" {
+		return
+	}
+
+	name := strings.ToLower(attrs["property"])
+	if name == "" {
+		if name = strings.ToLower(attrs["name"]); name == "" {
+			name = strings.ToLower(attrs["itemprop"])
+		}
+	}
+
+	switch {
+	case name == "description":
+		if meta.Description == "" {
+			meta.Description = content
+		}
+	case name == "keywords":
+		if meta.Keywords == nil {
+			for _, kw := range strings.Split(content, ",") {
+				if kw = strings.TrimSpace(kw); kw != "" {
+					meta.Keywords = append(meta.Keywords, kw)
+				}
+			}
+		}
+	case name == "og:title":
+		if meta.OGTitle == "" {
+			meta.OGTitle = content
+		}
+	case name == "og:description":
+		if meta.OGDescription == "" {
+			meta.OGDescription = content
+		}
+	case name == "og:image" || name == "og:image:url" || name == "og:image:secure_url":
+		if meta.OGImage == "" {
+			meta.OGImage = content
+		}
+	case strings.HasPrefix(name, "twitter:"):
+		if _, exists := twitter[name]; !exists {
+			twitter[name] = content
+		}
+	case publishDateMetaNames[name]:
+		if meta.PublishedAt.IsZero() {
+			meta.PublishedAt = parsePublishDate(content)
+		}
+	}
+}
+
+// parsePublishDate parses a date in one of the publishDateLayouts formats. It
+// returns the zero time if the date cannot be parsed.
+func parsePublishDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range publishDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
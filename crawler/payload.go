@@ -3,11 +3,12 @@ package crawler
 import (
 	"bytes"
 	"fmt"
-	"io"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/iamleson98/go-search/linkgraph/graph"
 	"github.com/iamleson98/go-search/pipeline"
 )
 
@@ -27,23 +28,142 @@ type crawlerPayload struct {
 	RetrievedAt time.Time
 	RawContent  bytes.Buffer
 
+	// LinkURL is the URL of the link that was scheduled for crawling.
+	// Unlike URL, it is not updated when the fetcher follows redirects so
+	// it is used for attributing the page in the crawl report.
+	LinkURL string
+
+	// Depth is the link distance of the page from the crawl seeds.
+	Depth int
+
+	// Kind is the kind of the link as stored in the link graph.
+	Kind graph.LinkKind
+
+	// Validators for conditional requests. They are populated from the
+	// link graph and updated by the fetcher.
+	ETag         string
+	LastModified string
+	ContentHash  string
+
+	// FetchStatus is the fetch status of the link as stored in the link
+	// graph. It is updated by the fetcher.
+	FetchStatus graph.FetchStatus
+
+	// Response metadata populated by the fetcher. FetchedAt is the time
+	// the request was issued and RemoteAddr the address of the server
+	// that served the final response. Truncated is set if RawContent was
+	// cut short due to the body size limit.
+	StatusCode      int
+	Proto           string
+	Header          http.Header
+	RequestMethod   string
+	RequestHeader   http.Header
+	RemoteAddr      string
+	FetchedAt       time.Time
+	TimeToFirstByte time.Duration
+	FetchDuration   time.Duration
+	Truncated       bool
+
+	// Charset is the character encoding that RawContent was transcoded
+	// from; after transcoding RawContent is always UTF-8.
+	Charset string
+
+	// DeclaredLanguage is the value of the lang attribute of the <html>
+	// element while Language is the detected (ISO 639-1) page language.
+	DeclaredLanguage string
+	Language         string
+
+	// RedirectChain lists the redirects followed by the fetcher. If it is
+	// not empty, URL has been updated to the final URL.
+	RedirectChain []redirectHop
+
+	// NotModified is set by the fetcher when the page has not changed
+	// since it was last retrieved. Downstream processors skip such pages.
+	NotModified bool
+
 	NoFollowLinks []string
 	Links         []string
 	Title         string
 	TextContent   string
+
+	// KnownOnlyLinks lists the links that exceeded the URL budget of
+	// their host. They are linked to only if they are already in the
+	// link graph so that existing edges survive but no new links are
+	// added.
+	KnownOnlyLinks []string
+
+	// FeedLinks lists the feeds that the page advertises via
+	// <link rel=alternate> elements.
+	FeedLinks []string
+
+	// LinkInfo holds the anchor text and rel attribute for each entry in
+	// Links, NoFollowLinks and KnownOnlyLinks.
+	LinkInfo []linkInfo
+
+	// Fingerprint is the SimHash of TextContent and DuplicateOf the ID of
+	// the page this page is a near-duplicate of, if any.
+	Fingerprint uint64
+	DuplicateOf uuid.UUID
+
+	// CanonicalURL is the target of the <link rel=canonical> element, if
+	// the page specifies one.
+	CanonicalURL string
+
+	// Metadata holds the description, headings and other metadata that
+	// the page declares about itself.
+	Metadata pageMetadata
+}
+
+// linkInfo describes an outgoing link as it appeared in the source page.
+type linkInfo struct {
+	URL  string
+	Text string
+	Rel  string
 }
 
 func (p *crawlerPayload) Clone() pipeline.Payload {
 	newP := payloadPool.Get().(*crawlerPayload)
 	newP.LinkID = p.LinkID
 	newP.URL = p.URL
+	newP.LinkURL = p.LinkURL
 	newP.RetrievedAt = p.RetrievedAt
+	newP.Depth = p.Depth
+	newP.Kind = p.Kind
+	newP.ETag = p.ETag
+	newP.LastModified = p.LastModified
+	newP.ContentHash = p.ContentHash
+	newP.NotModified = p.NotModified
+	newP.FetchStatus = p.FetchStatus
+	newP.RedirectChain = append([]redirectHop(nil), p.RedirectChain...)
+	newP.StatusCode = p.StatusCode
+	newP.Header = p.Header.Clone()
+	newP.Proto = p.Proto
+	newP.RequestMethod = p.RequestMethod
+	newP.RequestHeader = p.RequestHeader.Clone()
+	newP.RemoteAddr = p.RemoteAddr
+	newP.FetchedAt = p.FetchedAt
+	newP.TimeToFirstByte = p.TimeToFirstByte
+	newP.FetchDuration = p.FetchDuration
+	newP.Truncated = p.Truncated
+	newP.Charset = p.Charset
+	newP.DeclaredLanguage = p.DeclaredLanguage
+	newP.Language = p.Language
 	newP.NoFollowLinks = append([]string(nil), p.NoFollowLinks...)
 	newP.Links = append([]string(nil), p.Links...)
+	newP.KnownOnlyLinks = append([]string(nil), p.KnownOnlyLinks...)
+	newP.FeedLinks = append([]string(nil), p.FeedLinks...)
 	newP.Title = p.Title
 	newP.TextContent = p.TextContent
+	newP.LinkInfo = append([]linkInfo(nil), p.LinkInfo...)
+	newP.CanonicalURL = p.CanonicalURL
+	newP.Metadata = p.Metadata
+	newP.Metadata.Keywords = append([]string(nil), p.Metadata.Keywords...)
+	newP.Metadata.Headings = append([]string(nil), p.Metadata.Headings...)
+	newP.Fingerprint = p.Fingerprint
+	newP.DuplicateOf = p.DuplicateOf
 
-	_, err := io.Copy(&newP.RawContent, &p.RawContent)
+	// Copy the contents without draining the buffer of the original.
+	_, err := newP.RawContent.Write(p.RawContent.Bytes())
 	if err != nil {
 		panic(fmt.Sprintf("[BUG] error cloning payload raw content: %v", err))
 	}
@@ -53,11 +173,49 @@ func (p *crawlerPayload) Clone() pipeline.Payload {
 
 func (p *crawlerPayload) MarkAsProcessed() {
 	p.URL = p.URL[:0]
+	p.LinkURL = p.LinkURL[:0]
 	p.RawContent.Reset()
+	p.Depth = 0
+	p.Kind = graph.LinkKindPage
+	p.ETag = p.ETag[:0]
+	p.LastModified = p.LastModified[:0]
+	p.ContentHash = p.ContentHash[:0]
+	p.NotModified = false
+	p.FetchStatus = graph.FetchStatus{}
+	p.RedirectChain = p.RedirectChain[:0]
+	p.StatusCode = 0
+	p.Header = nil
+	p.Proto = p.Proto[:0]
+	p.RequestMethod = p.RequestMethod[:0]
+	p.RequestHeader = nil
+	p.RemoteAddr = p.RemoteAddr[:0]
+	p.FetchedAt = time.Time{}
+	p.TimeToFirstByte = 0
+	p.FetchDuration = 0
+	p.Truncated = false
+	p.Charset = p.Charset[:0]
+	p.DeclaredLanguage = p.DeclaredLanguage[:0]
+	p.Language = p.Language[:0]
 	p.NoFollowLinks = p.NoFollowLinks[:0]
 	p.Links = p.Links[:0]
+	p.KnownOnlyLinks = p.KnownOnlyLinks[:0]
+	p.FeedLinks = p.FeedLinks[:0]
 	p.Title = p.Title[:0]
 	p.TextContent = p.TextContent[:0]
+	p.LinkInfo = p.LinkInfo[:0]
+	p.CanonicalURL = p.CanonicalURL[:0]
+	p.Metadata = pageMetadata{
+		Keywords: p.Metadata.Keywords[:0],
+		Headings: p.Metadata.Headings[:0],
+	}
+	p.Fingerprint = 0
+	p.DuplicateOf = uuid.Nil
 
 	payloadPool.Put(p)
 }
+
+// isDuplicate returns true if the payload was flagged as a near-duplicate
+// of another page.
+func (p *crawlerPayload) isDuplicate() bool {
+	return p.DuplicateOf != uuid.Nil
+}
@@ -0,0 +1,31 @@
+package politeness
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Clock is implemented by objects that can report the current time and
+// signal when a duration has elapsed. It allows tests to control time.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Resolver is implemented by objects that can resolve a host name into a
+// list of IP addresses. *net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time                         { return time.Now() }
+func (wallClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// WallClock returns a Clock backed by the system time.
+func WallClock() Clock { return wallClock{} }
+
+// DefaultResolver returns the resolver used by the net package.
+func DefaultResolver() Resolver { return net.DefaultResolver }
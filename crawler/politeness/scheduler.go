@@ -0,0 +1,444 @@
+package politeness
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+const defaultMaxDelay = time.Minute
+
+// Config encapsulates the settings for a Scheduler.
+type Config struct {
+	// The total number of payloads that can be processed concurrently.
+	Workers int
+
+	// The max number of concurrent requests to the same host. Defaults to 1.
+	MaxPerHost int
+
+	// The max number of concurrent requests to the same resolved IP
+	// address. A zero value disables the per-IP cap.
+	MaxPerIP int
+
+	// The min delay between the completion of a request to a host and the
+	// start of the next one.
+	MinDelay time.Duration
+
+	// An optional function that returns an additional host-specific delay
+	// (e.g. a robots.txt Crawl-delay). The larger of MinDelay and the
+	// returned value is enforced.
+	HostDelay func(target *url.URL) time.Duration
+
+	// The max delay between requests to the same host. Longer delays
+	// returned by HostDelay are capped to this value. Defaults to 1 minute
+	// (or MinDelay if higher).
+	MaxDelay time.Duration
+
+	// An optional function that is invoked once for each host before its
+	// first payload is dispatched (e.g. to retrieve the robots.txt file of
+	// the host so that HostDelay can report its Crawl-delay).
+	PrepareHost func(ctx context.Context, target *url.URL)
+
+	// Target extracts the URL from a payload. Payloads whose URL cannot be
+	// extracted are processed without any politeness constraints.
+	Target func(pipeline.Payload) (*url.URL, error)
+
+	// The max number of payloads that are buffered across all host queues
+	// before the scheduler stops reading its input. Defaults to
+	// 100 * Workers.
+	MaxQueued int
+
+	// The max number of payloads per host that count towards MaxQueued.
+	// Further payloads for a host are parked until its queue drains so
+	// that hosts with a long delay cannot fill the whole queue and starve
+	// the others. Defaults to 10 * MaxPerHost (or MaxQueued if lower).
+	MaxQueuedPerHost int
+
+	// The max number of parked payloads across all hosts. Once reached,
+	// the scheduler stops reading its input until a parked payload is
+	// dispatched. Defaults to MaxQueued.
+	MaxParked int
+
+	// The resolver for obtaining host IP addresses. Only used when
+	// MaxPerIP > 0. Defaults to net.DefaultResolver.
+	Resolver Resolver
+
+	// The clock for enforcing delays. Defaults to the system clock.
+	Clock Clock
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Workers <= 0 {
+		return fmt.Errorf("politeness: workers must be > 0")
+	}
+	if cfg.Target == nil {
+		return fmt.Errorf("politeness: target function not specified")
+	}
+	if cfg.MaxPerHost <= 0 {
+		cfg.MaxPerHost = 1
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	if cfg.MaxDelay < cfg.MinDelay {
+		cfg.MaxDelay = cfg.MinDelay
+	}
+	if cfg.MaxQueued <= 0 {
+		cfg.MaxQueued = 100 * cfg.Workers
+	}
+	if cfg.MaxQueuedPerHost <= 0 {
+		cfg.MaxQueuedPerHost = 10 * cfg.MaxPerHost
+	}
+	if cfg.MaxQueuedPerHost > cfg.MaxQueued {
+		cfg.MaxQueuedPerHost = cfg.MaxQueued
+	}
+	if cfg.MaxParked <= 0 {
+		cfg.MaxParked = cfg.MaxQueued
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = DefaultResolver()
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = WallClock()
+	}
+	return nil
+}
+
+// Scheduler is a pipeline.StageRunner that processes payloads using a pool
+// of workers while enforcing per-host and per-IP concurrency caps and a min
+// delay between requests to the same host. Hosts are served in round-robin
+// order so that a single host cannot monopolize the workers.
+type Scheduler struct {
+	proc pipeline.Processor
+	cfg  Config
+}
+
+// NewScheduler returns a Scheduler that processes payloads with proc.
+func NewScheduler(proc pipeline.Processor, cfg Config) (*Scheduler, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{proc: proc, cfg: cfg}, nil
+}
+
+type hostQueue struct {
+	key     string
+	target  *url.URL
+	pending []pipeline.Payload
+	active  int
+	nextAt  time.Time
+
+	// ipKey is populated once the host has been resolved. Hosts are also
+	// considered resolved once PrepareHost has returned.
+	resolved bool
+	ipKey    string
+}
+
+// runState holds the scheduler state for a single Run invocation.
+type runState struct {
+	mu        sync.Mutex
+	hosts     map[string]*hostQueue
+	ring      []*hostQueue
+	cursor    int
+	activeIPs map[string]int
+	active    int
+	inputDone bool
+
+	// queued is the number of payloads in all host queues and parked the
+	// number of those that exceed the per-host limit.
+	queued int
+	parked int
+
+	// wakeCh signals the dispatch loop and spaceCh the input reader.
+	wakeCh  chan struct{}
+	spaceCh chan struct{}
+}
+
+func (st *runState) wake() {
+	select {
+	case st.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (st *runState) signalSpace() {
+	select {
+	case st.spaceCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run implements pipeline.StageRunner.
+func (s *Scheduler) Run(ctx context.Context, params pipeline.StageParams) {
+	var (
+		wg sync.WaitGroup
+		st = &runState{
+			hosts:     make(map[string]*hostQueue),
+			activeIPs: make(map[string]int),
+			wakeCh:    make(chan struct{}, 1),
+			spaceCh:   make(chan struct{}, 1),
+		}
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.readInput(ctx, params, st, &wg)
+	}()
+
+	s.dispatchLoop(ctx, params, st, &wg)
+	wg.Wait()
+}
+
+// hasSpace returns true if another payload can be buffered.
+func (s *Scheduler) hasSpace(st *runState) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.queued-st.parked < s.cfg.MaxQueued && st.parked < s.cfg.MaxParked
+}
+
+// readInput moves payloads from the stage input into the per-host queues.
+func (s *Scheduler) readInput(ctx context.Context, params pipeline.StageParams, st *runState, wg *sync.WaitGroup) {
+	defer func() {
+		st.mu.Lock()
+		st.inputDone = true
+		st.mu.Unlock()
+		st.wake()
+	}()
+
+	for {
+		// Apply back-pressure once too many payloads are buffered.
+		for !s.hasSpace(st) {
+			select {
+			case <-st.spaceCh:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		var (
+			payload pipeline.Payload
+			ok      bool
+		)
+		select {
+		case payload, ok = <-params.Input():
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		var (
+			key    string
+			target *url.URL
+		)
+		if u, err := s.cfg.Target(payload); err == nil {
+			key, target = u.Host, u
+		}
+
+		st.mu.Lock()
+		hq, exists := st.hosts[key]
+		if !exists {
+			hq = &hostQueue{key: key, target: target}
+			st.hosts[key] = hq
+			st.ring = append(st.ring, hq)
+
+			if (s.cfg.MaxPerIP > 0 || s.cfg.PrepareHost != nil) && target != nil {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.resolve(ctx, st, hq)
+				}()
+			} else {
+				hq.resolved = true
+			}
+		}
+		if len(hq.pending) >= s.cfg.MaxQueuedPerHost {
+			st.parked++
+		}
+		hq.pending = append(hq.pending, payload)
+		st.queued++
+		st.mu.Unlock()
+		st.wake()
+	}
+}
+
+// resolve prepares a newly seen host and looks up its IP address if the
+// per-IP cap is enabled.
+func (s *Scheduler) resolve(ctx context.Context, st *runState, hq *hostQueue) {
+	if s.cfg.PrepareHost != nil {
+		s.cfg.PrepareHost(ctx, hq.target)
+	}
+
+	var ipKey string
+	if s.cfg.MaxPerIP > 0 {
+		ipKey = s.lookupIP(ctx, hq.target)
+	}
+
+	st.mu.Lock()
+	hq.ipKey = ipKey
+	hq.resolved = true
+	st.mu.Unlock()
+	st.wake()
+}
+
+func (s *Scheduler) lookupIP(ctx context.Context, target *url.URL) string {
+	if addrs, err := s.cfg.Resolver.LookupHost(ctx, target.Hostname()); err == nil && len(addrs) != 0 {
+		return addrs[0]
+	}
+	if ip := net.ParseIP(target.Hostname()); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func (s *Scheduler) dispatchLoop(ctx context.Context, params pipeline.StageParams, st *runState, wg *sync.WaitGroup) {
+	for {
+		st.mu.Lock()
+		now := s.cfg.Clock.Now()
+		hq, waitUntil := s.pickLocked(st, now)
+		if hq != nil {
+			// The oldest parked payload of the host, if any, now counts
+			// towards the queue limit.
+			if len(hq.pending) > s.cfg.MaxQueuedPerHost {
+				st.parked--
+			}
+			payload := hq.pending[0]
+			hq.pending[0] = nil
+			hq.pending = hq.pending[1:]
+			hq.active++
+			// Space out concurrent requests to the host; the delay is
+			// measured again from completion below.
+			hq.nextAt = now.Add(s.hostDelay(hq))
+			st.activeIPs[hq.ipKey]++
+			st.queued--
+			st.active++
+			st.mu.Unlock()
+			st.signalSpace()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.process(ctx, params, payload)
+
+				st.mu.Lock()
+				if nextAt := s.cfg.Clock.Now().Add(s.hostDelay(hq)); nextAt.After(hq.nextAt) {
+					hq.nextAt = nextAt
+				}
+				hq.active--
+				st.active--
+				if st.activeIPs[hq.ipKey]--; st.activeIPs[hq.ipKey] <= 0 {
+					delete(st.activeIPs, hq.ipKey)
+				}
+				st.mu.Unlock()
+				st.wake()
+			}()
+			continue
+		}
+
+		done := st.inputDone && st.queued == 0 && st.active == 0
+		st.mu.Unlock()
+		if done {
+			return
+		}
+
+		var timerCh <-chan time.Time
+		if !waitUntil.IsZero() {
+			timerCh = s.cfg.Clock.After(waitUntil.Sub(now))
+		}
+
+		select {
+		case <-st.wakeCh:
+		case <-timerCh:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pickLocked returns the next host queue that can be served in round-robin
+// order. If no host can be served right now, it returns the earliest time
+// at which a host that is only blocked by its delay becomes eligible.
+func (s *Scheduler) pickLocked(st *runState, now time.Time) (*hostQueue, time.Time) {
+	if st.active >= s.cfg.Workers {
+		return nil, time.Time{}
+	}
+
+	var waitUntil time.Time
+	for scanned, ringLen := 0, len(st.ring); scanned < ringLen && len(st.ring) != 0; scanned++ {
+		if st.cursor >= len(st.ring) {
+			st.cursor = 0
+		}
+		hq := st.ring[st.cursor]
+
+		// Drop idle hosts whose delay has elapsed to keep the ring small.
+		if len(hq.pending) == 0 {
+			if hq.active == 0 && !now.Before(hq.nextAt) && hq.resolved {
+				delete(st.hosts, hq.key)
+				st.ring = append(st.ring[:st.cursor], st.ring[st.cursor+1:]...)
+				continue
+			}
+			st.cursor++
+			continue
+		}
+
+		st.cursor++
+		switch {
+		case !hq.resolved || hq.active >= s.cfg.MaxPerHost:
+			continue
+		case hq.key != "" && now.Before(hq.nextAt):
+			if waitUntil.IsZero() || hq.nextAt.Before(waitUntil) {
+				waitUntil = hq.nextAt
+			}
+			continue
+		case s.cfg.MaxPerIP > 0 && hq.ipKey != "" && st.activeIPs[hq.ipKey] >= s.cfg.MaxPerIP:
+			continue
+		}
+
+		return hq, time.Time{}
+	}
+
+	return nil, waitUntil
+}
+
+func (s *Scheduler) hostDelay(hq *hostQueue) time.Duration {
+	delay := s.cfg.MinDelay
+	if s.cfg.HostDelay != nil && hq.target != nil {
+		if hostDelay := s.cfg.HostDelay(hq.target); hostDelay > delay {
+			delay = hostDelay
+		}
+	}
+	if delay > s.cfg.MaxDelay {
+		delay = s.cfg.MaxDelay
+	}
+	return delay
+}
+
+func (s *Scheduler) process(ctx context.Context, params pipeline.StageParams, payloadIn pipeline.Payload) {
+	payloadOut, err := s.proc.Process(ctx, payloadIn)
+	if err != nil {
+		wrappedErr := fmt.Errorf("pipeline stage %d: %w", params.StageIndex(), err)
+		select {
+		case params.Error() <- wrappedErr:
+		default:
+		}
+		return
+	}
+
+	if payloadOut == nil {
+		payloadIn.MarkAsProcessed()
+		return
+	}
+
+	select {
+	case params.Output() <- payloadOut:
+	case <-ctx.Done():
+	}
+}
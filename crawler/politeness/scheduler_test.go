@@ -0,0 +1,483 @@
+package politeness
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+func TestSchedulerEnforcesMinDelay(t *testing.T) {
+	farm := newServerFarm(t, 1)
+	clock := newFakeClock()
+
+	proc := farm.processor()
+	s := mustScheduler(t, proc, Config{
+		Workers:  4,
+		MinDelay: 10 * time.Second,
+		Clock:    clock,
+	})
+
+	run := startRun(s, farm.payloads(0, 3))
+	proc.waitFor(t, 1)
+
+	// The remaining requests are held back until the delay elapses.
+	for i := 2; i <= 3; i++ {
+		clock.waitForTimers(t)
+		clock.Advance(9 * time.Second)
+		proc.expectCount(t, i-1)
+		clock.Advance(time.Second)
+		proc.waitFor(t, i)
+	}
+	run.wait(t)
+}
+
+func TestSchedulerCapsHostDelay(t *testing.T) {
+	farm := newServerFarm(t, 1)
+	clock := newFakeClock()
+
+	proc := farm.processor()
+	s := mustScheduler(t, proc, Config{
+		Workers:   1,
+		HostDelay: func(*url.URL) time.Duration { return 24 * time.Hour },
+		MaxDelay:  time.Minute,
+		Clock:     clock,
+	})
+
+	run := startRun(s, farm.payloads(0, 2))
+	proc.waitFor(t, 1)
+	clock.waitForTimers(t)
+	clock.Advance(time.Minute)
+	proc.waitFor(t, 2)
+	run.wait(t)
+}
+
+func TestSchedulerRoundRobin(t *testing.T) {
+	farm := newServerFarm(t, 3)
+	clock := newFakeClock()
+
+	// A single worker serves the hosts in turn even though the payloads
+	// of the first host arrive first.
+	proc := farm.processor()
+	proc.block = make(chan struct{})
+	s := mustScheduler(t, proc, Config{
+		Workers: 1,
+		Clock:   clock,
+	})
+
+	var payloads []pipeline.Payload
+	for host := 0; host < 3; host++ {
+		payloads = append(payloads, farm.payloads(host, 3)...)
+	}
+	run := startRun(s, payloads)
+
+	// Let the first request through once all payloads are queued.
+	proc.waitForStarted(t, 1)
+	time.Sleep(50 * time.Millisecond)
+	close(proc.block)
+	run.wait(t)
+
+	hosts := proc.hosts()
+	if len(hosts) != 9 {
+		t.Fatalf("expected 9 requests; got %d", len(hosts))
+	}
+	for i := 1; i < 4; i++ {
+		if hosts[i] == hosts[i-1] {
+			t.Fatalf("expected hosts to alternate; got %v", hosts)
+		}
+	}
+}
+
+func TestSchedulerMaxPerHost(t *testing.T) {
+	farm := newServerFarm(t, 1)
+
+	proc := farm.processor()
+	s := mustScheduler(t, proc, Config{
+		Workers:    8,
+		MaxPerHost: 2,
+	})
+
+	startRun(s, farm.payloads(0, 10)).wait(t)
+	if got := farm.maxConcurrent(0); got > 2 {
+		t.Fatalf("expected at most 2 concurrent requests; got %d", got)
+	}
+	proc.expectCount(t, 10)
+}
+
+func TestSchedulerParksSlowHosts(t *testing.T) {
+	farm := newServerFarm(t, 2)
+	clock := newFakeClock()
+
+	proc := farm.processor()
+	s := mustScheduler(t, proc, Config{
+		Workers:          2,
+		MinDelay:         time.Hour,
+		MaxDelay:         time.Hour,
+		MaxQueued:        4,
+		MaxQueuedPerHost: 2,
+		MaxParked:        10,
+		Clock:            clock,
+	})
+
+	// The payloads of the slow host exceed the global queue limit; the
+	// payload of the other host must still be served right away.
+	payloads := append(farm.payloads(0, 8), farm.payloads(1, 1)...)
+	run := startRun(s, payloads)
+	proc.waitFor(t, 2)
+	if got := farm.requests(1); got != 1 {
+		t.Fatalf("expected the second host to be served; got %d requests", got)
+	}
+
+	for i := 3; i <= 9; i++ {
+		clock.waitForTimers(t)
+		clock.Advance(time.Hour)
+		proc.waitFor(t, i)
+	}
+	run.wait(t)
+}
+
+func TestSchedulerUnknownTargets(t *testing.T) {
+	proc := &recordingProc{}
+	s := mustScheduler(t, proc, Config{
+		Workers:  2,
+		MinDelay: time.Hour,
+		Target: func(pipeline.Payload) (*url.URL, error) {
+			return nil, context.Canceled
+		},
+	})
+
+	// Payloads without a target are not subject to any delay.
+	startRun(s, []pipeline.Payload{&testPayload{}, &testPayload{}, &testPayload{}}).wait(t)
+	proc.expectCount(t, 3)
+}
+
+func TestConfigValidation(t *testing.T) {
+	target := func(pipeline.Payload) (*url.URL, error) { return nil, nil }
+
+	specs := []struct {
+		descr string
+		cfg   Config
+		valid bool
+	}{
+		{descr: "no workers", cfg: Config{Target: target}},
+		{descr: "no target", cfg: Config{Workers: 1}},
+		{descr: "valid", cfg: Config{Workers: 1, Target: target}, valid: true},
+	}
+
+	for _, spec := range specs {
+		_, err := NewScheduler(&recordingProc{}, spec.cfg)
+		if (err == nil) != spec.valid {
+			t.Errorf("%s: unexpected error %v", spec.descr, err)
+		}
+	}
+
+	cfg := Config{Workers: 2, Target: target, MinDelay: 2 * time.Minute, MaxQueued: 5}
+	if err := cfg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxDelay != cfg.MinDelay {
+		t.Errorf("expected max delay to be raised to the min delay; got %s", cfg.MaxDelay)
+	}
+	if cfg.MaxQueuedPerHost != 5 {
+		t.Errorf("expected per-host queue limit to be capped to 5; got %d", cfg.MaxQueuedPerHost)
+	}
+	if cfg.MaxParked != 5 {
+		t.Errorf("expected parked payload limit to default to 5; got %d", cfg.MaxParked)
+	}
+}
+
+func mustScheduler(t *testing.T, proc pipeline.Processor, cfg Config) *Scheduler {
+	t.Helper()
+	if cfg.Target == nil {
+		cfg.Target = func(p pipeline.Payload) (*url.URL, error) {
+			return url.Parse(p.(*testPayload).url)
+		}
+	}
+
+	s, err := NewScheduler(proc, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// serverFarm is a set of local HTTP servers, each one acting as a separate
+// host.
+type serverFarm struct {
+	servers []*httptest.Server
+
+	mu       sync.Mutex
+	inFlight []int
+	maxConc  []int
+	reqCount []int
+}
+
+func newServerFarm(t *testing.T, n int) *serverFarm {
+	f := &serverFarm{
+		inFlight: make([]int, n),
+		maxConc:  make([]int, n),
+		reqCount: make([]int, n),
+	}
+	for i := 0; i < n; i++ {
+		idx := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f.mu.Lock()
+			f.reqCount[idx]++
+			if f.inFlight[idx]++; f.inFlight[idx] > f.maxConc[idx] {
+				f.maxConc[idx] = f.inFlight[idx]
+			}
+			f.mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			f.mu.Lock()
+			f.inFlight[idx]--
+			f.mu.Unlock()
+		}))
+		t.Cleanup(srv.Close)
+		f.servers = append(f.servers, srv)
+	}
+	return f
+}
+
+func (f *serverFarm) payloads(host, n int) []pipeline.Payload {
+	var payloads []pipeline.Payload
+	for i := 0; i < n; i++ {
+		payloads = append(payloads, &testPayload{url: f.servers[host].URL + "/"})
+	}
+	return payloads
+}
+
+func (f *serverFarm) requests(host int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reqCount[host]
+}
+
+func (f *serverFarm) maxConcurrent(host int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.maxConc[host]
+}
+
+func (f *serverFarm) processor() *recordingProc {
+	return &recordingProc{client: f.servers[0].Client()}
+}
+
+// recordingProc fetches the URL of each payload and records the order in
+// which hosts were requested. If block is not nil, requests wait for it to
+// be closed.
+type recordingProc struct {
+	client *http.Client
+	block  chan struct{}
+
+	mu      sync.Mutex
+	started int
+	done    []string
+}
+
+func (p *recordingProc) Process(ctx context.Context, payload pipeline.Payload) (pipeline.Payload, error) {
+	p.mu.Lock()
+	p.started++
+	p.mu.Unlock()
+
+	if p.block != nil {
+		<-p.block
+	}
+
+	tp := payload.(*testPayload)
+	var host string
+	if tp.url != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, tp.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := p.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		_ = res.Body.Close()
+		host = req.URL.Host
+	}
+
+	p.mu.Lock()
+	p.done = append(p.done, host)
+	p.mu.Unlock()
+	return payload, nil
+}
+
+func (p *recordingProc) hosts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.done...)
+}
+
+func (p *recordingProc) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.done)
+}
+
+func (p *recordingProc) waitFor(t *testing.T, n int) {
+	t.Helper()
+	waitUntil(t, func() bool { return p.count() >= n }, "%d processed payloads", n)
+	p.expectCount(t, n)
+}
+
+func (p *recordingProc) waitForStarted(t *testing.T, n int) {
+	t.Helper()
+	waitUntil(t, func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.started >= n
+	}, "%d started payloads", n)
+}
+
+func (p *recordingProc) expectCount(t *testing.T, n int) {
+	t.Helper()
+	// Give the scheduler a chance to dispatch payloads it should not.
+	time.Sleep(20 * time.Millisecond)
+	if got := p.count(); got != n {
+		t.Fatalf("expected %d processed payloads; got %d", n, got)
+	}
+}
+
+func waitUntil(t *testing.T, cond func() bool, format string, args ...interface{}) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for "+format, args...)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type testPayload struct {
+	url string
+}
+
+func (p *testPayload) Clone() pipeline.Payload { return &testPayload{url: p.url} }
+func (p *testPayload) MarkAsProcessed()        {}
+
+// testRun runs a scheduler against a fixed list of payloads.
+type testRun struct {
+	doneCh chan struct{}
+	errCh  chan error
+	cancel context.CancelFunc
+}
+
+func startRun(s *Scheduler, payloads []pipeline.Payload) *testRun {
+	ctx, cancel := context.WithCancel(context.Background())
+	params := &stageParams{
+		in:    make(chan pipeline.Payload),
+		out:   make(chan pipeline.Payload, len(payloads)),
+		errCh: make(chan error, len(payloads)),
+	}
+	run := &testRun{doneCh: make(chan struct{}), errCh: params.errCh, cancel: cancel}
+
+	go func() {
+		for _, p := range payloads {
+			params.in <- p
+		}
+		close(params.in)
+	}()
+	go func() {
+		s.Run(ctx, params)
+		close(run.doneCh)
+	}()
+	return run
+}
+
+func (r *testRun) wait(t *testing.T) {
+	t.Helper()
+	defer r.cancel()
+
+	select {
+	case <-r.doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the scheduler to return")
+	}
+	select {
+	case err := <-r.errCh:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+}
+
+type stageParams struct {
+	in    chan pipeline.Payload
+	out   chan pipeline.Payload
+	errCh chan error
+}
+
+func (p *stageParams) StageIndex() int                 { return 0 }
+func (p *stageParams) Input() <-chan pipeline.Payload  { return p.in }
+func (p *stageParams) Output() chan<- pipeline.Payload { return p.out }
+func (p *stageParams) Error() chan<- error             { return p.errCh }
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward and fires the timers that have expired.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+			continue
+		}
+		pending = append(pending, w)
+	}
+	c.waiters = pending
+}
+
+// waitForTimers waits until a timer has been registered.
+func (c *fakeClock) waitForTimers(t *testing.T) {
+	t.Helper()
+	waitUntil(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.waiters) != 0
+	}, "a timer")
+}
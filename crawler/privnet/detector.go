@@ -0,0 +1,248 @@
+// Package privnet detects hosts and addresses that belong to private,
+// loopback or otherwise non-public networks and provides a dialer that
+// refuses to connect to them.
+package privnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned by the dialer when a connection to a
+// non-public address is attempted.
+var ErrPrivateAddress = errors.New("connection to non-public address refused")
+
+// reservedCIDRs lists the networks that are never reachable from the
+// public internet.
+var reservedCIDRs = []string{
+	"0.0.0.0/8",          // "this" network
+	"10.0.0.0/8",         // RFC1918
+	"100.64.0.0/10",      // carrier-grade NAT (RFC6598)
+	"127.0.0.0/8",        // loopback
+	"169.254.0.0/16",     // link-local, including cloud metadata endpoints
+	"172.16.0.0/12",      // RFC1918
+	"192.0.0.0/24",       // IETF protocol assignments
+	"192.0.2.0/24",       // TEST-NET-1
+	"192.88.99.0/24",     // 6to4 relay anycast
+	"192.168.0.0/16",     // RFC1918
+	"198.18.0.0/15",      // benchmarking
+	"198.51.100.0/24",    // TEST-NET-2
+	"203.0.113.0/24",     // TEST-NET-3
+	"224.0.0.0/4",        // multicast
+	"240.0.0.0/4",        // reserved
+	"255.255.255.255/32", // broadcast
+	"::/128",             // unspecified
+	"::1/128",            // loopback
+	"64:ff9b:1::/48",     // local-use NAT64
+	"100::/64",           // discard-only
+	"2001::/32",          // Teredo tunneling
+	"2001:db8::/32",      // documentation
+	"fc00::/7",           // unique local addresses
+	"fe80::/10",          // link-local
+	"fec0::/10",          // deprecated site-local
+	"ff00::/8",           // multicast
+}
+
+// Resolver is implemented by objects that can resolve host names into IP
+// addresses. *net.Resolver satisfies this interface.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Config encapsulates the settings for a Detector.
+type Config struct {
+	// Additional networks (in CIDR notation) to treat as private.
+	Deny []string
+
+	// Networks (in CIDR notation) to treat as public even if they fall
+	// within a reserved range. Allow entries take precedence over both
+	// the reserved ranges and Deny.
+	Allow []string
+
+	// The resolver for looking up host names. Defaults to
+	// net.DefaultResolver.
+	Resolver Resolver
+
+	// The timeout for resolving host names. Defaults to 5 seconds.
+	LookupTimeout time.Duration
+}
+
+// Detector implements crawler.PrivateNetworkDetector. A host is considered
+// private if any of the addresses it resolves to is private.
+type Detector struct {
+	deny          []*net.IPNet
+	allow         []*net.IPNet
+	resolver      Resolver
+	lookupTimeout time.Duration
+}
+
+// NewDetector returns a new Detector using the provided config.
+func NewDetector(cfg Config) (*Detector, error) {
+	deny, err := parseCIDRs(append(append([]string(nil), reservedCIDRs...), cfg.Deny...))
+	if err != nil {
+		return nil, err
+	}
+
+	allow, err := parseCIDRs(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+	if cfg.LookupTimeout <= 0 {
+		cfg.LookupTimeout = 5 * time.Second
+	}
+
+	return &Detector{
+		deny:          deny,
+		allow:         allow,
+		resolver:      cfg.Resolver,
+		lookupTimeout: cfg.LookupTimeout,
+	}, nil
+}
+
+// IsPrivate returns true if host is an IP address in a private network or a
+// name that resolves to at least one such address. Hosts may optionally
+// include a port.
+func (d *Detector) IsPrivate(host string) (bool, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+		host = host[1 : len(host)-1]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return d.IsPrivateIP(ip), nil
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), d.lookupTimeout)
+	defer cancelFn()
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return false, err
+	}
+
+	for _, addr := range addrs {
+		if d.IsPrivateIP(addr.IP) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// IsPrivateIP returns true if ip belongs to a private network. IPv4-mapped
+// and IPv4-compatible IPv6 addresses as well as NAT64 and 6to4 addresses
+// are checked against the IPv4 address they embed.
+func (d *Detector) IsPrivateIP(ip net.IP) bool {
+	for _, candidate := range candidateIPs(ip) {
+		if containsIP(d.allow, candidate) {
+			return false
+		}
+		if containsIP(d.deny, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialControl returns a function suitable for net.Dialer.Control that
+// aborts connections to private addresses. Since it runs after name
+// resolution and for every connection (including the ones established
+// when following redirects), it is not vulnerable to DNS rebinding.
+func (d *Detector) DialControl() func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("unexpected non-IP address %q", address)
+		}
+
+		if d.IsPrivateIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+}
+
+// Dialer returns a net.Dialer that refuses to connect to private addresses.
+// Its DialContext method can be used as httpclient.Config.DialContext.
+func (d *Detector) Dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   d.DialControl(),
+	}
+}
+
+// candidateIPs returns ip along with any IPv4 address it embeds.
+func candidateIPs(ip net.IP) []net.IP {
+	candidates := []net.IP{ip}
+
+	ip16 := ip.To16()
+	if ip16 == nil || ip.To4() != nil {
+		// Plain IPv4 addresses (including IPv4-mapped ones which To4
+		// already unwraps).
+		if ip4 := ip.To4(); ip4 != nil {
+			candidates[0] = ip4
+		}
+		return candidates
+	}
+
+	switch {
+	case isZero(ip16[:12]):
+		// IPv4-compatible address (::a.b.c.d).
+		if !isZero(ip16[12:]) {
+			candidates = append(candidates, net.IP(ip16[12:16]))
+		}
+	case ip16[0] == 0x00 && ip16[1] == 0x64 && ip16[2] == 0xff && ip16[3] == 0x9b && isZero(ip16[4:12]):
+		// NAT64 well-known prefix (64:ff9b::/96).
+		candidates = append(candidates, net.IP(ip16[12:16]))
+	case ip16[0] == 0x20 && ip16[1] == 0x02:
+		// 6to4 (2002::/16) embeds the IPv4 address in bits 16-47.
+		candidates = append(candidates, net.IP(ip16[2:6]))
+	}
+
+	return candidates
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("privnet: %w", err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
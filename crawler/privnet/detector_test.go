@@ -0,0 +1,143 @@
+package privnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	d, err := NewDetector(Config{
+		Deny:  []string{"8.8.4.0/24"},
+		Allow: []string{"10.1.0.0/16"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []struct {
+		ip  string
+		exp bool
+	}{
+		{"8.8.8.8", false},
+		{"2606:4700::1111", false},
+		{"127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"2001:0:4136:e378::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::127.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::808:808", false},
+		{"2002:c0a8:101::1", true},
+		{"2002:808:808::1", false},
+		{"8.8.4.4", true},
+		{"10.1.2.3", false},
+		{"::ffff:10.1.2.3", false},
+	}
+
+	for _, spec := range specs {
+		if got := d.IsPrivateIP(net.ParseIP(spec.ip)); got != spec.exp {
+			t.Errorf("IsPrivateIP(%s): expected %t; got %t", spec.ip, spec.exp, got)
+		}
+	}
+}
+
+func TestIsPrivate(t *testing.T) {
+	d, err := NewDetector(Config{Resolver: fakeResolver{
+		"public.example":  {"93.184.216.34"},
+		"mixed.example":   {"93.184.216.34", "10.0.0.1"},
+		"private.example": {"192.168.0.10"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []struct {
+		host string
+		exp  bool
+	}{
+		{"public.example", false},
+		{"public.example:8080", false},
+		{"mixed.example", true},
+		{"private.example:443", true},
+		{"127.0.0.1:80", true},
+		{"[::1]", true},
+		{"[::1]:8080", true},
+		{"[2606:4700::1111]:443", false},
+	}
+	for _, spec := range specs {
+		got, err := d.IsPrivate(spec.host)
+		if err != nil {
+			t.Errorf("IsPrivate(%s): unexpected error %v", spec.host, err)
+		} else if got != spec.exp {
+			t.Errorf("IsPrivate(%s): expected %t; got %t", spec.host, spec.exp, got)
+		}
+	}
+
+	if _, err = d.IsPrivate("missing.example"); err == nil {
+		t.Error("expected a lookup error for an unknown host")
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	d, err := NewDetector(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := d.DialControl()
+
+	if err = control("tcp", "127.0.0.1:80", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected ErrPrivateAddress; got %v", err)
+	}
+	if err = control("tcp", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err = control("tcp", "example.com:443", nil); err == nil {
+		t.Error("expected an error for a non-IP address")
+	}
+
+	// The dialer refuses to connect to loopback listeners.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	if _, err = d.Dialer(0).Dial("tcp", l.Addr().String()); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected ErrPrivateAddress; got %v", err)
+	}
+}
+
+func TestNewDetectorRejectsInvalidCIDRs(t *testing.T) {
+	if _, err := NewDetector(Config{Deny: []string{"10.0.0.0"}}); err == nil {
+		t.Error("expected an error for an invalid deny entry")
+	}
+	if _, err := NewDetector(Config{Allow: []string{"not-a-cidr"}}); err == nil {
+		t.Error("expected an error for an invalid allow entry")
+	}
+}
+
+// fakeResolver maps host names to their addresses.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, found := r[host]
+	if !found {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+// redirectHop describes a single redirect response that was followed while
+// fetching a page.
+type redirectHop struct {
+	URL        string
+	StatusCode int
+}
+
+// redirectChain returns the redirects that were followed to obtain res,
+// in the order they were encountered.
+func redirectChain(res *http.Response) []redirectHop {
+	var chain []redirectHop
+	for req := res.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		prev := req.Response
+		if prev.Request == nil {
+			break
+		}
+		chain = append([]redirectHop{{URL: prev.Request.URL.String(), StatusCode: prev.StatusCode}}, chain...)
+	}
+	return chain
+}
+
+func isPermanentRedirect(statusCode int) bool {
+	return statusCode == http.StatusMovedPermanently || statusCode == http.StatusPermanentRedirect
+}
+
+// redirectRecorder records the redirect aliases for pages that were
+// retrieved via redirects and rebinds the payload to the link of the final
+// URL so that the page is indexed under it.
+type redirectRecorder struct {
+	updater    Graph
+	normalizer *urlnorm.Normalizer
+	reprocess  bool
+}
+
+func newRedirectRecorder(updater Graph, normalizer *urlnorm.Normalizer, reprocess bool) *redirectRecorder {
+	return &redirectRecorder{
+		updater:    updater,
+		normalizer: normalizer,
+		reprocess:  reprocess,
+	}
+}
+
+func (r *redirectRecorder) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
+	payload := p.(*crawlerPayload)
+	if len(payload.RedirectChain) == 0 {
+		return payload, nil
+	}
+
+	// Upserting with a zero retrieval time does not alter the stored
+	// validators which lets us check whether the content has changed
+	// since the final URL was last retrieved. The link extractor resolves
+	// relative links against payload.URL so only the link is normalized.
+	dst := &graph.Link{URL: r.normalize(payload.URL), Depth: payload.Depth}
+	if err := r.updater.UpsertLink(dst); err != nil {
+		return nil, err
+	}
+	if !payload.NotModified && !r.reprocess && dst.ContentHash != "" && dst.ContentHash == payload.ContentHash {
+		payload.NotModified = true
+	}
+
+	// A hop is a permanent alias of the final URL only if it and every
+	// hop after it are permanent redirects.
+	permanent := true
+	now := time.Now()
+	for i := len(payload.RedirectChain) - 1; i >= 0; i-- {
+		hop := payload.RedirectChain[i]
+		permanent = permanent && isPermanentRedirect(hop.StatusCode)
+		hopURL := r.normalize(hop.URL)
+		if hopURL == dst.URL {
+			continue
+		}
+
+		src := &graph.Link{URL: hopURL, RetrievedAt: now, Depth: payload.Depth}
+		if err := r.updater.UpsertLink(src); err != nil {
+			return nil, err
+		}
+
+		if err := r.updater.UpsertRedirect(&graph.Redirect{Src: src.ID, Dst: dst.ID, Permanent: permanent}); err != nil {
+			return nil, err
+		}
+
+		if permanent {
+			if err := r.updater.TransferInboundEdges(src.ID, dst.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	payload.LinkID = dst.ID
+	return payload, nil
+}
+
+// normalize returns the normalized form of rawURL, or rawURL itself if it
+// cannot be normalized, so that redirect targets map to the same links as
+// extracted links.
+func (r *redirectRecorder) normalize(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u, err = r.normalizer.Normalize(u); err != nil {
+		return rawURL
+	}
+	return u.String()
+}
@@ -0,0 +1,272 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/iamleson98/go-search/crawler/politeness"
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/pipeline"
+)
+
+// Names of the built-in crawler processors that can be referenced by a
+// pipeline.Spec.
+const (
+	ProcLinkFetcher   = "link_fetcher"
+	ProcRedirects     = "redirect_recorder"
+	ProcContentStore  = "content_store"
+	ProcCharset       = "charset_decoder"
+	ProcLinkExtractor = "link_extractor"
+	ProcTextExtractor = "text_extractor"
+	ProcLanguage      = "language_detector"
+	ProcFingerprinter = "fingerprinter"
+	ProcGraphUpdater  = "graph_updater"
+	ProcTextIndexer   = "text_indexer"
+)
+
+// Params accepted by the built-in processors. Processors reject any param
+// that is not listed here.
+const (
+	// link_fetcher: override Config.FetchTimeout, Config.MaxBodySize and
+	// Config.TruncateOversizedBodies.
+	ParamFetchTimeout      = "fetch_timeout"
+	ParamMaxBodySize       = "max_body_size"
+	ParamTruncateOversized = "truncate_oversized"
+
+	// link_extractor: a regular expression that replaces the default
+	// pattern for excluding links to non-HTML resources.
+	ParamExclude = "exclude"
+
+	// fingerprinter: overrides Config.NearDuplicateDistance.
+	ParamMaxDistance = "max_distance"
+)
+
+// StageHostScheduler is the name of the stage type that runs the fetcher
+// under the per-host politeness scheduler. It accepts the optional
+// max_per_host, max_per_ip and min_delay params which override the
+// corresponding Config.Politeness values.
+const StageHostScheduler = "host_scheduler"
+
+// NewProcessorRegistry returns a pipeline.Registry with the built-in crawler
+// processors registered. The processors are bound to the dependencies in cfg.
+func NewProcessorRegistry(cfg Config) *pipeline.Registry {
+	if cfg.URLNormalizer == nil {
+		cfg.URLNormalizer = urlnorm.New(urlnorm.Config{})
+	}
+
+	reg := pipeline.NewRegistry()
+
+	reg.RegisterProcessor(ProcLinkFetcher, func(params pipeline.Params) (pipeline.Processor, error) {
+		limits, err := fetchLimitsFromParams(cfg, params)
+		if err != nil {
+			return nil, err
+		}
+		// Replayed crawls do not reflect the current state of the links.
+		var status *fetchStatusTracker
+		if !cfg.ReprocessUnchanged {
+			status = newFetchStatusTracker(cfg)
+		}
+		return newLinkFetcher(cfg.URLGetter, cfg.PrivateNetworkDetector, cfg.Robots, cfg.Scope, cfg.Traps, cfg.Frontier, cfg.Archive, status, limits, cfg.ReprocessUnchanged), nil
+	})
+	reg.RegisterProcessor(ProcRedirects, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newRedirectRecorder(cfg.Graph, cfg.URLNormalizer, cfg.ReprocessUnchanged), nil
+	})
+	reg.RegisterProcessor(ProcContentStore, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		if cfg.ContentStore == nil {
+			return nil, fmt.Errorf("%s: no content store configured", ProcContentStore)
+		}
+		return newContentStoreWriter(cfg.ContentStore), nil
+	})
+	reg.RegisterProcessor(ProcCharset, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newCharsetDecoder(), nil
+	})
+	reg.RegisterProcessor(ProcLinkExtractor, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(ParamExclude); err != nil {
+			return nil, err
+		}
+		exclude := exclusionRegex
+		if pattern, err := params.String(ParamExclude, ""); err != nil {
+			return nil, err
+		} else if pattern != "" {
+			if exclude, err = regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("param %q: %w", ParamExclude, err)
+			}
+		}
+		return newLinkExtractor(cfg.PrivateNetworkDetector, cfg.Robots, cfg.Scope, cfg.Traps, cfg.URLNormalizer, exclude), nil
+	})
+	reg.RegisterProcessor(ProcTextExtractor, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newTextExtrator(), nil
+	})
+	reg.RegisterProcessor(ProcLanguage, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newLanguageDetector(), nil
+	})
+	reg.RegisterProcessor(ProcFingerprinter, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(ParamMaxDistance); err != nil {
+			return nil, err
+		}
+		if cfg.Fingerprints == nil {
+			return nil, fmt.Errorf("no fingerprint store configured")
+		}
+		maxDistance, err := params.Int(ParamMaxDistance, cfg.NearDuplicateDistance)
+		if err != nil {
+			return nil, err
+		}
+		return newFingerprinter(cfg.Fingerprints, cfg.Indexer, maxDistance), nil
+	})
+	reg.RegisterProcessor(ProcGraphUpdater, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newGraphUpdater(cfg.Graph, cfg.Feeds), nil
+	})
+	reg.RegisterProcessor(ProcTextIndexer, func(params pipeline.Params) (pipeline.Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return newTextIndexer(cfg.Indexer, cfg.Graph), nil
+	})
+
+	reg.RegisterStage(StageHostScheduler, func(spec pipeline.StageSpec, procs []pipeline.Processor) (pipeline.StageRunner, error) {
+		if len(procs) != 1 {
+			return nil, fmt.Errorf("%s stage expects exactly one processor", spec.Type)
+		}
+		return newHostScheduler(cfg, spec, procs[0])
+	})
+
+	return reg
+}
+
+// fetchLimitsFromParams returns the fetch limits in cfg overridden by the
+// link fetcher params.
+func fetchLimitsFromParams(cfg Config, params pipeline.Params) (fetchLimits, error) {
+	var err error
+	if err = params.CheckKnown(ParamFetchTimeout, ParamMaxBodySize, ParamTruncateOversized); err != nil {
+		return fetchLimits{}, err
+	}
+
+	if cfg.FetchTimeout, err = params.Duration(ParamFetchTimeout, cfg.FetchTimeout); err != nil {
+		return fetchLimits{}, err
+	}
+	maxBodySize, err := params.Int(ParamMaxBodySize, int(cfg.MaxBodySize))
+	if err != nil {
+		return fetchLimits{}, err
+	}
+	cfg.MaxBodySize = int64(maxBodySize)
+	if cfg.TruncateOversizedBodies, err = params.Bool(ParamTruncateOversized, cfg.TruncateOversizedBodies); err != nil {
+		return fetchLimits{}, err
+	}
+
+	return makeFetchLimits(cfg), nil
+}
+
+func newHostScheduler(cfg Config, spec pipeline.StageSpec, proc pipeline.Processor) (pipeline.StageRunner, error) {
+	var err error
+	if err = spec.Params.CheckKnown("max_per_host", "max_per_ip", "min_delay"); err != nil {
+		return nil, err
+	}
+	polCfg := cfg.Politeness
+	polCfg.Workers = spec.Workers
+	if polCfg.MaxPerHost, err = spec.Params.Int("max_per_host", polCfg.MaxPerHost); err != nil {
+		return nil, err
+	}
+	if polCfg.MaxPerIP, err = spec.Params.Int("max_per_ip", polCfg.MaxPerIP); err != nil {
+		return nil, err
+	}
+	if polCfg.MinDelay, err = spec.Params.Duration("min_delay", polCfg.MinDelay); err != nil {
+		return nil, err
+	}
+
+	polCfg.Target = func(p pipeline.Payload) (*url.URL, error) {
+		return url.Parse(p.(*crawlerPayload).URL)
+	}
+	if cfg.Robots != nil {
+		// Retrieve the robots.txt file of each host before its first
+		// request so that its Crawl-delay applies from the start. The
+		// fetcher performs the actual robots.txt check.
+		polCfg.PrepareHost = func(ctx context.Context, target *url.URL) {
+			_, _ = cfg.Robots.IsAllowed(ctx, target)
+		}
+		polCfg.HostDelay = cfg.Robots.CrawlDelay
+	}
+
+	return politeness.NewScheduler(proc, polCfg)
+}
+
+// DefaultPipelineSpec returns the spec for the default crawler pipeline
+// layout. It can be used as a starting point for custom layouts.
+func DefaultPipelineSpec(cfg Config) *pipeline.Spec {
+	spec := &pipeline.Spec{
+		Stages: []pipeline.StageSpec{
+			{
+				Type:       StageHostScheduler,
+				Workers:    cfg.FetchWorkers,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcLinkFetcher}},
+			},
+			{
+				Type:       pipeline.StageTypeFIFO,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcRedirects}},
+			},
+			{
+				Type:       pipeline.StageTypeFIFO,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcCharset}},
+			},
+			{
+				Type:       pipeline.StageTypeFIFO,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcLinkExtractor}},
+			},
+			{
+				Type:       pipeline.StageTypeFIFO,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcTextExtractor}},
+			},
+			{
+				Type:       pipeline.StageTypeFIFO,
+				Processors: []pipeline.ProcessorSpec{{Name: ProcLanguage}},
+			},
+		},
+	}
+
+	// The content is stored after the payload has been rebound to the link
+	// of the final URL and before it is transcoded.
+	if cfg.ContentStore != nil {
+		stages := append([]pipeline.StageSpec{}, spec.Stages[:2]...)
+		stages = append(stages, pipeline.StageSpec{
+			Type:       pipeline.StageTypeFIFO,
+			Processors: []pipeline.ProcessorSpec{{Name: ProcContentStore}},
+		})
+		spec.Stages = append(stages, spec.Stages[2:]...)
+	}
+
+	if cfg.Fingerprints != nil {
+		spec.Stages = append(spec.Stages, pipeline.StageSpec{
+			Type:       pipeline.StageTypeFIFO,
+			Processors: []pipeline.ProcessorSpec{{Name: ProcFingerprinter}},
+		})
+	}
+
+	spec.Stages = append(spec.Stages, pipeline.StageSpec{
+		Type: pipeline.StageTypeBroadcast,
+		Processors: []pipeline.ProcessorSpec{
+			{Name: ProcGraphUpdater},
+			{Name: ProcTextIndexer},
+		},
+	})
+
+	return spec
+}
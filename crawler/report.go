@@ -0,0 +1,282 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iamleson98/go-search/crawler/frontier"
+	"github.com/iamleson98/go-search/crawler/traps"
+)
+
+// maxErrorSamples is the max number of errors recorded in a CrawlReport.
+const maxErrorSamples = 50
+
+// FetchOutcome describes what happened when the crawler attempted to
+// fetch a link.
+type FetchOutcome int
+
+// The possible fetch outcomes.
+const (
+	// The page was fetched and its content has changed.
+	OutcomeFetched FetchOutcome = iota
+
+	// The page was fetched but its content has not changed.
+	OutcomeNotModified
+
+	// The URL is invalid or points to a file type that cannot contain
+	// HTML content.
+	OutcomeExcluded
+
+	// The URL is not in the crawl scope.
+	OutcomeOutOfScope
+
+	// The URL resolves to a private network address.
+	OutcomePrivate
+
+	// The URL is disallowed by the host's robots.txt.
+	OutcomeRobotsDisallowed
+
+	// The URL looks like a crawler trap.
+	OutcomeTrapped
+
+	// The link is not retried yet as its previous fetches failed.
+	OutcomeBackedOff
+
+	// The link points to a feed; feeds are polled by a FeedPoller instead.
+	OutcomeFeed
+
+	// The URL is missing from the archive that the crawl is replayed
+	// from.
+	OutcomeNotArchived
+
+	// The response does not contain HTML content.
+	OutcomeNonHTML
+
+	// The response has a non-2xx status code.
+	OutcomeNon2xx
+
+	// The request failed or the response body could not be read.
+	OutcomeFetchError
+)
+
+var fetchOutcomeNames = map[FetchOutcome]string{
+	OutcomeFetched:          "fetched",
+	OutcomeNotModified:      "not-modified",
+	OutcomeExcluded:         "skipped-excluded",
+	OutcomeOutOfScope:       "skipped-out-of-scope",
+	OutcomePrivate:          "skipped-private",
+	OutcomeRobotsDisallowed: "skipped-robots",
+	OutcomeTrapped:          "skipped-trap",
+	OutcomeBackedOff:        "skipped-backoff",
+	OutcomeFeed:             "skipped-feed",
+	OutcomeNotArchived:      "skipped-not-archived",
+	OutcomeNonHTML:          "non-html",
+	OutcomeNon2xx:           "non-2xx",
+	OutcomeFetchError:       "fetch-error",
+}
+
+func (o FetchOutcome) String() string {
+	if name, ok := fetchOutcomeNames[o]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// frontierKind maps o to the outcome kind reported to the frontier.
+func (o FetchOutcome) frontierKind() frontier.OutcomeKind {
+	switch o {
+	case OutcomeFetched:
+		return frontier.OutcomeChanged
+	case OutcomeNotModified:
+		return frontier.OutcomeUnchanged
+	case OutcomeNon2xx, OutcomeFetchError:
+		return frontier.OutcomeFailed
+	default:
+		return frontier.OutcomeSkipped
+	}
+}
+
+// CrawlReport summarizes the results of a Crawl call.
+type CrawlReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// The number of links read from the link iterator.
+	Links int
+
+	// The number of links per fetch outcome.
+	Outcomes map[FetchOutcome]int
+
+	// The number of pages sent to the text indexer.
+	Indexed int
+
+	// The number of (decoded) body bytes downloaded.
+	BytesDownloaded int64
+
+	// Percentiles of the time it took to fetch each page, measured over
+	// the links for which a response was received.
+	FetchDuration DurationStats
+
+	// Per-host counters, keyed by the host name of the crawled links.
+	Hosts map[string]*HostReport
+
+	// A sample of up to 50 errors that caused links to be dropped.
+	Errors []ErrorSample
+
+	// The hosts that were flagged as crawler traps or exhausted their URL
+	// budget during the crawl. Only populated if a TrapDetector is
+	// configured.
+	TrappedHosts []traps.HostReport
+}
+
+// Duration returns the wall-clock duration of the crawl.
+func (r *CrawlReport) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// HostReport holds the per-host counters of a CrawlReport.
+type HostReport struct {
+	Outcomes        map[FetchOutcome]int
+	Indexed         int
+	BytesDownloaded int64
+}
+
+// DurationStats summarizes a set of durations.
+type DurationStats struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// ErrorSample describes an error that caused a link to be dropped.
+type ErrorSample struct {
+	URL     string
+	Outcome FetchOutcome
+	Err     string
+}
+
+// reportCollector accumulates the CrawlReport of a Crawl call. It is passed
+// to the processors via the pipeline context so that each call gets its own
+// report. All methods are safe for concurrent use and are no-ops on a nil
+// collector, which allows the processors to run outside of Crawl.
+type reportCollector struct {
+	mu        sync.Mutex
+	report    CrawlReport
+	durations []time.Duration
+}
+
+type reportCtxKey struct{}
+
+func newReportCollector() *reportCollector {
+	return &reportCollector{
+		report: CrawlReport{
+			StartedAt: time.Now(),
+			Outcomes:  make(map[FetchOutcome]int),
+			Hosts:     make(map[string]*HostReport),
+		},
+	}
+}
+
+func withReportCollector(ctx context.Context, rc *reportCollector) context.Context {
+	return context.WithValue(ctx, reportCtxKey{}, rc)
+}
+
+func reportCollectorFrom(ctx context.Context) *reportCollector {
+	rc, _ := ctx.Value(reportCtxKey{}).(*reportCollector)
+	return rc
+}
+
+func (rc *reportCollector) recordLink() {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	rc.report.Links++
+	rc.mu.Unlock()
+}
+
+// recordFetch records the outcome of fetching linkURL. If err is not nil
+// it is added to the error samples.
+func (rc *reportCollector) recordFetch(linkURL string, outcome FetchOutcome, bytes int64, fetchDuration time.Duration, err error) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.report.Outcomes[outcome]++
+	rc.report.BytesDownloaded += bytes
+	if fetchDuration > 0 {
+		rc.durations = append(rc.durations, fetchDuration)
+	}
+
+	host := rc.host(linkURL)
+	host.Outcomes[outcome]++
+	host.BytesDownloaded += bytes
+
+	if err != nil && len(rc.report.Errors) < maxErrorSamples {
+		rc.report.Errors = append(rc.report.Errors, ErrorSample{
+			URL:     linkURL,
+			Outcome: outcome,
+			Err:     err.Error(),
+		})
+	}
+}
+
+// recordIndexed records that the page retrieved for linkURL was indexed.
+func (rc *reportCollector) recordIndexed(linkURL string) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	rc.report.Indexed++
+	rc.host(linkURL).Indexed++
+	rc.mu.Unlock()
+}
+
+// host returns the HostReport for the host of rawURL. It must be called
+// with the lock held.
+func (rc *reportCollector) host(rawURL string) *HostReport {
+	var hostname string
+	if u, err := url.Parse(rawURL); err == nil {
+		hostname = u.Hostname()
+	}
+
+	host, exists := rc.report.Hosts[hostname]
+	if !exists {
+		host = &HostReport{Outcomes: make(map[FetchOutcome]int)}
+		rc.report.Hosts[hostname] = host
+	}
+	return host
+}
+
+// finish completes and returns the report.
+func (rc *reportCollector) finish() *CrawlReport {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.report.FinishedAt = time.Now()
+	rc.report.FetchDuration = makeDurationStats(rc.durations)
+	return &rc.report
+}
+
+func makeDurationStats(durations []time.Duration) DurationStats {
+	if len(durations) == 0 {
+		return DurationStats{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	percentile := func(p int) time.Duration {
+		return durations[(len(durations)-1)*p/100]
+	}
+	return DurationStats{
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: durations[len(durations)-1],
+	}
+}
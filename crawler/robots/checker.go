@@ -0,0 +1,344 @@
+package robots
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL          = 24 * time.Hour
+	defaultErrorTTL     = 15 * time.Minute
+	defaultFetchTimeout = 30 * time.Second
+	defaultMaxEntries   = 100000
+	unreachableDeadline = 30 * 24 * time.Hour
+)
+
+// Getter is implemented by objects that can perform HTTP requests.
+// *http.Client satisfies this interface.
+type Getter interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config encapsulates the settings for a Checker.
+type Config struct {
+	// The product token that is matched against robots.txt user-agent
+	// lines (e.g. "GoSearchBot").
+	UserAgent string
+
+	// The Getter for retrieving robots.txt files.
+	Getter Getter
+
+	// TTL controls how long a successfully retrieved robots.txt file is
+	// cached. RFC 9309 recommends not exceeding 24 hours which is also
+	// the default value.
+	TTL time.Duration
+
+	// ErrorTTL controls how long a failed retrieval is cached before it is
+	// retried. Defaults to 15 minutes.
+	ErrorTTL time.Duration
+
+	// FetchTimeout bounds the time spent retrieving a robots.txt file.
+	// Defaults to 30 seconds.
+	FetchTimeout time.Duration
+
+	// MaxEntries caps the number of hosts that are cached. Defaults to 100k.
+	MaxEntries int
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	if cfg.UserAgent == "" {
+		return fmt.Errorf("robots: user agent not specified")
+	}
+	if cfg.Getter == nil {
+		return fmt.Errorf("robots: getter not specified")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.ErrorTTL <= 0 {
+		cfg.ErrorTTL = defaultErrorTTL
+	}
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = defaultFetchTimeout
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// Checker fetches, caches and evaluates robots.txt files. It is safe for
+// concurrent use.
+type Checker struct {
+	cfg Config
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	// ready is closed once the entry has been populated.
+	ready chan struct{}
+
+	rules     *Rules
+	expiresAt time.Time
+
+	// lastGood holds the most recent successfully fetched rules and
+	// unreachableSince the time when the host started failing; both are
+	// used to implement the RFC 9309 semantics for unreachable servers.
+	lastGood         *Rules
+	unreachableSince time.Time
+}
+
+// NewChecker returns a new Checker using the provided config.
+func NewChecker(cfg Config) (*Checker, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Checker{
+		cfg:     cfg,
+		entries: make(map[string]*cacheEntry),
+	}, nil
+}
+
+// IsAllowed returns true if the robots.txt rules for the host of u allow
+// the URL to be crawled. The robots.txt file is retrieved if it is not
+// already cached.
+func (c *Checker) IsAllowed(ctx context.Context, u *url.URL) (bool, error) {
+	rules, err := c.Rules(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	return rules.Allowed(u), nil
+}
+
+// IsAllowedCached is like IsAllowed but only consults the cache. The known
+// return value is false if no cached rules exist for the host of u.
+func (c *Checker) IsAllowedCached(u *url.URL) (allowed, known bool) {
+	rules := c.cachedRules(u)
+	if rules == nil {
+		return true, false
+	}
+	return rules.Allowed(u), true
+}
+
+// CrawlDelay returns the cached Crawl-delay value for the host of u or zero
+// if no rules for the host are cached.
+func (c *Checker) CrawlDelay(u *url.URL) time.Duration {
+	rules := c.cachedRules(u)
+	if rules == nil {
+		return 0
+	}
+	return rules.CrawlDelay()
+}
+
+// Rules returns the rules that apply to the host of u, retrieving the
+// robots.txt file if needed. The file is retrieved independently of ctx so
+// that a cancelled caller does not cause a failure to be cached for every
+// other caller; if ctx is done before the rules are available, ctx.Err() is
+// returned.
+func (c *Checker) Rules(ctx context.Context, u *url.URL) (*Rules, error) {
+	key, err := cacheKey(u)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.cfg.Clock()
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	if exists {
+		c.mu.Unlock()
+		if err = waitReady(ctx, entry); err != nil {
+			return nil, err
+		}
+		if now.Before(entry.expiresAt) {
+			return entry.rules, nil
+		}
+
+		c.mu.Lock()
+		// Another goroutine may have already replaced the expired entry.
+		if cur := c.entries[key]; cur != nil && cur != entry {
+			c.mu.Unlock()
+			if err = waitReady(ctx, cur); err != nil {
+				return nil, err
+			}
+			return cur.rules, nil
+		}
+	}
+
+	newEntry := &cacheEntry{ready: make(chan struct{})}
+	if exists {
+		newEntry.lastGood = entry.lastGood
+		newEntry.unreachableSince = entry.unreachableSince
+	}
+	c.evictLocked(now)
+	c.entries[key] = newEntry
+	c.mu.Unlock()
+
+	go c.populate(newEntry, key, now)
+	if err = waitReady(ctx, newEntry); err != nil {
+		return nil, err
+	}
+	return newEntry.rules, nil
+}
+
+// waitReady blocks until entry has been populated or ctx is done.
+func waitReady(ctx context.Context, entry *cacheEntry) error {
+	select {
+	case <-entry.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Checker) cachedRules(u *url.URL) *Rules {
+	key, err := cacheKey(u)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	entry, exists := c.entries[key]
+	c.mu.Unlock()
+	if !exists {
+		return nil
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.rules
+	default:
+		return nil
+	}
+}
+
+// populate fetches the robots.txt file for the entry and applies the
+// RFC 9309 failure semantics:
+//   - 2xx: the parsed rules are used.
+//   - 4xx: the file is unavailable and crawling is unrestricted.
+//   - 5xx or network errors: the file is unreachable and crawling is
+//     completely disallowed unless a previously cached copy exists. If the
+//     host stays unreachable for 30 days it is treated as unavailable.
+//
+// The file is retrieved under a context that is only bounded by the fetch
+// timeout as the entry is shared by all callers.
+func (c *Checker) populate(entry *cacheEntry, key string, now time.Time) {
+	defer close(entry.ready)
+
+	res, err := c.fetch(context.Background(), key+"/robots.txt")
+	if err == nil {
+		defer func() { _ = res.Body.Close() }()
+	}
+
+	switch {
+	case err == nil && res.StatusCode >= 200 && res.StatusCode <= 299:
+		rules, pErr := Parse(res.Body, c.cfg.UserAgent)
+		if pErr == nil {
+			entry.rules = rules
+			entry.lastGood = rules
+			entry.unreachableSince = time.Time{}
+			entry.expiresAt = now.Add(c.cfg.TTL)
+			return
+		}
+	case err == nil && res.StatusCode >= 400 && res.StatusCode <= 499:
+		entry.rules = AllowAll()
+		entry.lastGood = nil
+		entry.unreachableSince = time.Time{}
+		entry.expiresAt = now.Add(c.cfg.TTL)
+		return
+	}
+
+	// Treat anything else (network errors, 5xx, unexpected 1xx/3xx codes
+	// and unparsable bodies) as unreachable.
+	if entry.unreachableSince.IsZero() {
+		entry.unreachableSince = now
+	}
+
+	switch {
+	case now.Sub(entry.unreachableSince) >= unreachableDeadline:
+		entry.rules = AllowAll()
+	case entry.lastGood != nil:
+		entry.rules = entry.lastGood
+	default:
+		entry.rules = DisallowAll()
+	}
+	entry.expiresAt = now.Add(c.cfg.ErrorTTL)
+}
+
+func (c *Checker) fetch(ctx context.Context, robotsURL string) (*http.Response, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, c.cfg.FetchTimeout)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.cfg.Getter.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Buffer the body so it can be parsed after the request context has
+	// been cancelled.
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxRobotsSize))
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	return res, nil
+}
+
+// evictLocked makes room for a new entry by dropping expired entries and,
+// if that is not enough, arbitrary ones. It must be called with c.mu held.
+func (c *Checker) evictLocked(now time.Time) {
+	if len(c.entries) < c.cfg.MaxEntries {
+		return
+	}
+
+	for key, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+
+	for key := range c.entries {
+		if len(c.entries) < c.cfg.MaxEntries {
+			break
+		}
+		delete(c.entries, key)
+	}
+}
+
+// cacheKey returns the scheme and authority for u. RFC 9309 scopes
+// robots.txt files to a particular protocol, host and port.
+func cacheKey(u *url.URL) (string, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("robots: unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("robots: URL %q does not specify a host", u.String())
+	}
+
+	return u.Scheme + "://" + u.Host, nil
+}
@@ -0,0 +1,182 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckerStatusCodes(t *testing.T) {
+	specs := []struct {
+		descr  string
+		status int
+		body   string
+		exp    bool
+	}{
+		{"ok", http.StatusOK, "User-agent: *\nDisallow: /page\n", false},
+		{"not found", http.StatusNotFound, "", true},
+		{"server error", http.StatusInternalServerError, "", false},
+		{"redirect not followed", http.StatusFound, "", false},
+	}
+
+	for _, spec := range specs {
+		srv := newRobotsServer(t, func(w http.ResponseWriter) {
+			w.WriteHeader(spec.status)
+			fmt.Fprint(w, spec.body)
+		})
+		c := newTestChecker(t, srv.Client(), time.Now)
+
+		allowed, err := c.IsAllowed(context.Background(), mustParseURL(t, srv.URL+"/page"))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", spec.descr, err)
+		} else if allowed != spec.exp {
+			t.Errorf("%s: expected allowed to be %t; got %t", spec.descr, spec.exp, allowed)
+		}
+	}
+}
+
+func TestCheckerCachesRules(t *testing.T) {
+	var fetches int32
+	srv := newRobotsServer(t, func(w http.ResponseWriter) {
+		atomic.AddInt32(&fetches, 1)
+		fmt.Fprint(w, "User-agent: *\nDisallow: /page\nCrawl-delay: 3\n")
+	})
+	clk := &testClock{now: time.Now()}
+	c := newTestChecker(t, srv.Client(), clk.Now)
+	u := mustParseURL(t, srv.URL+"/page")
+
+	if _, known := c.IsAllowedCached(u); known {
+		t.Fatal("expected no rules to be cached yet")
+	}
+	if got := c.CrawlDelay(u); got != 0 {
+		t.Errorf("expected no crawl delay before the rules are cached; got %s", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.IsAllowed(context.Background(), u); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("expected concurrent callers to share a single fetch; got %d", got)
+	}
+	if allowed, known := c.IsAllowedCached(u); !known || allowed {
+		t.Errorf("expected cached rules to disallow the URL; got allowed=%t, known=%t", allowed, known)
+	}
+	if got := c.CrawlDelay(u); got != 3*time.Second {
+		t.Errorf("expected crawl delay 3s; got %s", got)
+	}
+
+	clk.Advance(defaultTTL)
+	if _, err := c.IsAllowed(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("expected the rules to be fetched again after the TTL expired; got %d fetches", got)
+	}
+}
+
+func TestCheckerKeepsLastGoodRules(t *testing.T) {
+	var failing int32
+	srv := newRobotsServer(t, func(w http.ResponseWriter) {
+		if atomic.LoadInt32(&failing) != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	})
+	clk := &testClock{now: time.Now()}
+	c := newTestChecker(t, srv.Client(), clk.Now)
+	public := mustParseURL(t, srv.URL+"/public")
+
+	if allowed, err := c.IsAllowed(context.Background(), public); err != nil || !allowed {
+		t.Fatalf("expected /public to be allowed; got %t, %v", allowed, err)
+	}
+
+	// While the host is unreachable the last good copy applies.
+	atomic.StoreInt32(&failing, 1)
+	clk.Advance(defaultTTL)
+	if allowed, err := c.IsAllowed(context.Background(), public); err != nil || !allowed {
+		t.Errorf("expected the last good rules to apply; got %t, %v", allowed, err)
+	}
+	if allowed, _ := c.IsAllowed(context.Background(), mustParseURL(t, srv.URL+"/private")); allowed {
+		t.Error("expected /private to stay disallowed")
+	}
+
+	// After 30 days of failures the host is treated as unavailable.
+	clk.Advance(unreachableDeadline)
+	if allowed, err := c.IsAllowed(context.Background(), mustParseURL(t, srv.URL+"/private")); err != nil || !allowed {
+		t.Errorf("expected everything to be allowed after the unreachable deadline; got %t, %v", allowed, err)
+	}
+}
+
+func TestCheckerRejectsUnsupportedURLs(t *testing.T) {
+	c := newTestChecker(t, new(http.Client), time.Now)
+
+	for _, rawURL := range []string{"ftp://example.com/file", "http:///path"} {
+		if _, err := c.IsAllowed(context.Background(), mustParseURL(t, rawURL)); err == nil {
+			t.Errorf("%s: expected an error", rawURL)
+		}
+	}
+}
+
+func TestCheckerConfigValidation(t *testing.T) {
+	if _, err := NewChecker(Config{Getter: http.DefaultClient}); err == nil {
+		t.Error("expected an error for a missing user agent")
+	}
+	if _, err := NewChecker(Config{UserAgent: "GoSearchBot"}); err == nil {
+		t.Error("expected an error for a missing getter")
+	}
+}
+
+func newRobotsServer(t *testing.T, handler func(w http.ResponseWriter)) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestChecker(t *testing.T, client *http.Client, clock func() time.Time) *Checker {
+	// Redirects are not followed so that 3xx responses reach the checker.
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	c, err := NewChecker(Config{UserAgent: "GoSearchBot", Getter: client, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
@@ -0,0 +1,234 @@
+package robots
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsSize is the max number of bytes that are parsed from a robots.txt
+// file. RFC 9309 requires crawlers to parse at least 500 KiB.
+const maxRobotsSize = 500 * 1024
+
+// Rules contains the robots.txt directives that apply to a particular user
+// agent.
+type Rules struct {
+	rules      []rule
+	crawlDelay time.Duration
+
+	// Sitemaps lists the sitemap URLs advertised by the robots.txt file.
+	// Sitemap directives are not tied to a particular group.
+	Sitemaps []string
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+var (
+	allowAll    = &Rules{}
+	disallowAll = &Rules{rules: []rule{{allow: false, pattern: "/"}}}
+)
+
+// AllowAll returns a Rules instance that allows access to every path.
+func AllowAll() *Rules { return allowAll }
+
+// DisallowAll returns a Rules instance that blocks access to every path.
+func DisallowAll() *Rules { return disallowAll }
+
+// Allowed returns true if the path (and query) of u may be crawled.
+func (r *Rules) Allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	// The robots.txt file itself is always accessible.
+	if path == "/robots.txt" {
+		return true
+	}
+
+	// Per RFC 9309 the most specific (longest) matching rule wins; if an
+	// allow and a disallow rule are equally specific, allow wins.
+	var (
+		bestLen = -1
+		allowed = true
+	)
+	for _, rl := range r.rules {
+		if !matchPattern(rl.pattern, path) {
+			continue
+		}
+
+		if pLen := len(rl.pattern); pLen > bestLen || (pLen == bestLen && rl.allow) {
+			bestLen = pLen
+			allowed = rl.allow
+		}
+	}
+
+	return allowed
+}
+
+// CrawlDelay returns the value of the (non-standard) Crawl-delay directive
+// or zero if none was specified.
+func (r *Rules) CrawlDelay() time.Duration {
+	return r.crawlDelay
+}
+
+// Parse reads a robots.txt file from r and returns the rules that apply to
+// userAgent. If no group matches userAgent, the rules for the "*" group are
+// returned instead.
+func Parse(r io.Reader, userAgent string) (*Rules, error) {
+	type group struct {
+		agents     []string
+		rules      []rule
+		crawlDelay time.Duration
+	}
+
+	var (
+		groups    []*group
+		cur       *group
+		lastWasUA bool
+		sitemaps  []string
+		scanner   = bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRobotsSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx != -1 {
+			line = line[:idx]
+		}
+
+		sep := strings.IndexByte(line, ':')
+		if sep == -1 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:sep]))
+		val := strings.TrimSpace(line[sep+1:])
+
+		switch key {
+		case "user-agent":
+			// Consecutive user-agent lines share the same group.
+			if cur == nil || !lastWasUA {
+				cur = new(group)
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+			lastWasUA = true
+			continue
+		case "allow", "disallow":
+			// An empty disallow value does not block anything.
+			if cur != nil && val != "" {
+				cur.rules = append(cur.rules, rule{allow: key == "allow", pattern: normalizePattern(val)})
+			}
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 && cur != nil {
+				cur.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			if val != "" {
+				sitemaps = append(sitemaps, val)
+			}
+		}
+		lastWasUA = false
+	}
+
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
+	}
+
+	// Merge all groups that match our product token; fall back to the
+	// groups that target "*" if none do.
+	var (
+		token    = strings.ToLower(userAgent)
+		matched  = &Rules{Sitemaps: sitemaps}
+		fallback = &Rules{Sitemaps: sitemaps}
+		found    bool
+	)
+	for _, g := range groups {
+		switch {
+		case containsAgent(g.agents, token):
+			found = true
+			matched.rules = append(matched.rules, g.rules...)
+			matched.crawlDelay = maxDuration(matched.crawlDelay, g.crawlDelay)
+		case containsAgent(g.agents, "*"):
+			fallback.rules = append(fallback.rules, g.rules...)
+			fallback.crawlDelay = maxDuration(fallback.crawlDelay, g.crawlDelay)
+		}
+	}
+
+	if found {
+		return matched, nil
+	}
+	return fallback, nil
+}
+
+// normalizePattern percent-encodes any characters in a pattern that would
+// be percent-encoded in a URL path so patterns and paths can be compared
+// byte by byte.
+func normalizePattern(pattern string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c >= 0x80 || c <= 0x20 {
+			sb.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// matchPattern reports whether path matches a robots.txt pattern. Patterns
+// may contain "*" wildcards and a trailing "$" end-of-path anchor.
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i := 1; i < len(parts); i++ {
+		// When anchored, the last part must match the end of the path.
+		if anchored && i == len(parts)-1 {
+			last := parts[i]
+			return len(path)-len(last) >= pos && strings.HasSuffix(path, last)
+		}
+
+		idx := strings.Index(path[pos:], parts[i])
+		if idx == -1 {
+			return false
+		}
+		pos += idx + len(parts[i])
+	}
+
+	return !anchored || pos == len(path)
+}
+
+func containsAgent(agents []string, token string) bool {
+	for _, agent := range agents {
+		if agent == token {
+			return true
+		}
+	}
+	return false
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package robots
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# Comments and blank lines are ignored.
+User-agent: *
+Disallow: /private
+Crawl-delay: 1
+
+User-agent: OtherBot
+User-agent: GoSearchBot
+Disallow: /
+Allow: /public
+Allow: /*.css$
+Disallow: /public/drafts
+Crawl-delay: 2.5
+
+User-agent: GoSearchBot
+Disallow: /tmp # trailing comment
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testRobots), "GoSearchBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	specs := []struct {
+		path string
+		exp  bool
+	}{
+		{"/", false},
+		{"/about", false},
+		{"/robots.txt", true},
+		{"/public", true},
+		{"/public/page.html", true},
+		{"/public/drafts/1", false},
+		{"/style.css", true},
+		{"/style.css?v=1", false},
+		{"/tmp", false},
+	}
+	for _, spec := range specs {
+		if got := rules.Allowed(mustParseURL(t, "https://example.com"+spec.path)); got != spec.exp {
+			t.Errorf("Allowed(%q): expected %t; got %t", spec.path, spec.exp, got)
+		}
+	}
+
+	if got := rules.CrawlDelay(); got != 2500*time.Millisecond {
+		t.Errorf("expected crawl delay 2.5s; got %s", got)
+	}
+	if len(rules.Sitemaps) != 1 || rules.Sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("unexpected sitemaps %v", rules.Sitemaps)
+	}
+}
+
+func TestParseFallsBackToWildcardGroup(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testRobots), "UnknownBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules.Allowed(mustParseURL(t, "https://example.com/private/1")) {
+		t.Error("expected /private/1 to be disallowed")
+	}
+	if !rules.Allowed(mustParseURL(t, "https://example.com/tmp")) {
+		t.Error("expected /tmp to be allowed")
+	}
+	if got := rules.CrawlDelay(); got != time.Second {
+		t.Errorf("expected crawl delay 1s; got %s", got)
+	}
+	if len(rules.Sitemaps) != 1 {
+		t.Errorf("expected sitemaps to be shared by all groups; got %v", rules.Sitemaps)
+	}
+}
+
+func TestParseIgnoresRulesOutsideGroups(t *testing.T) {
+	rules, err := Parse(strings.NewReader("Disallow: /\nUser-agent: *\nDisallow:\n"), "GoSearchBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rules.Allowed(mustParseURL(t, "https://example.com/page")) {
+		t.Error("expected everything to be allowed")
+	}
+}
+
+func TestAllowedPrefersAllowOnTies(t *testing.T) {
+	rules, err := Parse(strings.NewReader("User-agent: *\nDisallow: /page\nAllow: /page\n"), "GoSearchBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rules.Allowed(mustParseURL(t, "https://example.com/page")) {
+		t.Error("expected the allow rule to win")
+	}
+}
+
+func TestAllowedEncodesPatterns(t *testing.T) {
+	rules, err := Parse(strings.NewReader("User-agent: *\nDisallow: /café\n"), "GoSearchBot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rules.Allowed(mustParseURL(t, "https://example.com/caf%C3%A9/menu")) {
+		t.Error("expected the percent-encoded path to match the UTF-8 pattern")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	specs := []struct {
+		pattern string
+		path    string
+		exp     bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fish/", false},
+		{"/*.php", "/index.php", true},
+		{"/*.php", "/dir/index.php?x=1", true},
+		{"/*.php$", "/index.php?x=1", false},
+		{"/a*b*c", "/aXbYc", true},
+		{"/a*b*c", "/aXcYb", false},
+		{"/*ab$", "/ab", true},
+		{"/a*a$", "/a", false},
+	}
+
+	for _, spec := range specs {
+		if got := matchPattern(spec.pattern, spec.path); got != spec.exp {
+			t.Errorf("matchPattern(%q, %q): expected %t; got %t", spec.pattern, spec.path, spec.exp, got)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
@@ -0,0 +1,199 @@
+// Package scope implements crawl scope policies that restrict the set of
+// URLs the crawler retrieves, e.g. for focused crawls of a few sites.
+package scope
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Config encapsulates the settings for a Policy. The zero value describes a
+// policy that accepts every URL.
+type Config struct {
+	// The domains the crawl is restricted to. An entry of the form
+	// "*.example.com" matches example.com and all of its subdomains while
+	// "example.com" only matches the exact host. If empty, all domains
+	// are allowed.
+	AllowDomains []string
+
+	// The domains that are never crawled, using the same syntax as
+	// AllowDomains. Deny entries take precedence over allow entries.
+	DenyDomains []string
+
+	// Regular expressions matched against the full URL. If Include is not
+	// empty, a URL must match at least one of its entries. URLs matching
+	// any Exclude entry are rejected.
+	Include []string
+	Exclude []string
+
+	// The max number of path segments (e.g. 2 for "/docs/intro"). Zero
+	// means no limit.
+	MaxPathDepth int
+
+	// The max number of query parameters. Zero means no limit.
+	MaxQueryParams int
+
+	// The max number of links that may separate a URL from the crawl
+	// seeds (which have a distance of zero). Zero means no limit.
+	MaxLinkDistance int
+}
+
+// Policy decides whether URLs are within the scope of a crawl. It is safe
+// for concurrent use.
+type Policy struct {
+	allow          []domainPattern
+	deny           []domainPattern
+	include        []*regexp.Regexp
+	exclude        []*regexp.Regexp
+	maxPathDepth   int
+	maxQueryParams int
+	maxDistance    int
+}
+
+// New returns a new Policy using the provided config.
+func New(cfg Config) (*Policy, error) {
+	allow, err := parseDomainPatterns(cfg.AllowDomains)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseDomainPatterns(cfg.DenyDomains)
+	if err != nil {
+		return nil, err
+	}
+	include, err := compileRegexps(cfg.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileRegexps(cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Policy{
+		allow:          allow,
+		deny:           deny,
+		include:        include,
+		exclude:        exclude,
+		maxPathDepth:   cfg.MaxPathDepth,
+		maxQueryParams: cfg.MaxQueryParams,
+		maxDistance:    cfg.MaxLinkDistance,
+	}, nil
+}
+
+// InScope returns true if u, which is distance links away from the crawl
+// seeds, should be crawled. A negative distance denotes an unknown distance
+// which is not checked against MaxLinkDistance.
+func (p *Policy) InScope(u *url.URL, distance int) bool {
+	if p.maxDistance > 0 && distance > p.maxDistance {
+		return false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if matchesDomain(p.deny, host) {
+		return false
+	}
+	if len(p.allow) != 0 && !matchesDomain(p.allow, host) {
+		return false
+	}
+
+	if p.maxPathDepth > 0 && pathDepth(u.EscapedPath()) > p.maxPathDepth {
+		return false
+	}
+	if p.maxQueryParams > 0 && queryParamCount(u.RawQuery) > p.maxQueryParams {
+		return false
+	}
+
+	if len(p.include) == 0 && len(p.exclude) == 0 {
+		return true
+	}
+
+	urlStr := u.String()
+	for _, re := range p.exclude {
+		if re.MatchString(urlStr) {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, re := range p.include {
+		if re.MatchString(urlStr) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainPattern matches a host name either exactly or, if it is a wildcard
+// pattern, the domain and all of its subdomains.
+type domainPattern struct {
+	domain   string
+	wildcard bool
+}
+
+func (dp domainPattern) matches(host string) bool {
+	if host == dp.domain {
+		return true
+	}
+	return dp.wildcard && strings.HasSuffix(host, "."+dp.domain)
+}
+
+func matchesDomain(patterns []domainPattern, host string) bool {
+	for _, dp := range patterns {
+		if dp.matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDomainPatterns(domains []string) ([]domainPattern, error) {
+	patterns := make([]domainPattern, 0, len(domains))
+	for _, domain := range domains {
+		dp := domainPattern{domain: strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")}
+		if strings.HasPrefix(dp.domain, "*.") {
+			dp.domain, dp.wildcard = dp.domain[2:], true
+		}
+		if dp.domain == "" || strings.Contains(dp.domain, "*") {
+			return nil, fmt.Errorf("scope: invalid domain pattern %q", domain)
+		}
+		patterns = append(patterns, dp)
+	}
+	return patterns, nil
+}
+
+func compileRegexps(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("scope: %w", err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// pathDepth returns the number of non-empty segments in path.
+func pathDepth(path string) int {
+	var depth int
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			depth++
+		}
+	}
+	return depth
+}
+
+// queryParamCount returns the number of parameters in rawQuery.
+func queryParamCount(rawQuery string) int {
+	var count int
+	for _, param := range strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' || r == ';' }) {
+		if param != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,72 @@
+package scope
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestInScope(t *testing.T) {
+	specs := []struct {
+		descr    string
+		cfg      Config
+		url      string
+		distance int
+		exp      bool
+	}{
+		{descr: "zero config", url: "https://anything.example/a/b/c?x=1", exp: true},
+		{descr: "exact domain", cfg: Config{AllowDomains: []string{"example.com"}}, url: "https://EXAMPLE.com./", exp: true},
+		{descr: "exact domain excludes subdomains", cfg: Config{AllowDomains: []string{"example.com"}}, url: "https://www.example.com/", exp: false},
+		{descr: "wildcard domain", cfg: Config{AllowDomains: []string{"*.example.com"}}, url: "https://a.b.example.com/", exp: true},
+		{descr: "wildcard matches apex", cfg: Config{AllowDomains: []string{"*.example.com"}}, url: "https://example.com/", exp: true},
+		{descr: "wildcard is label aligned", cfg: Config{AllowDomains: []string{"*.example.com"}}, url: "https://badexample.com/", exp: false},
+		{descr: "port is ignored", cfg: Config{AllowDomains: []string{"example.com"}}, url: "https://example.com:8443/", exp: true},
+		{descr: "deny wins", cfg: Config{AllowDomains: []string{"*.example.com"}, DenyDomains: []string{"private.example.com"}}, url: "https://private.example.com/", exp: false},
+		{descr: "deny only", cfg: Config{DenyDomains: []string{"*.example.org"}}, url: "https://example.com/", exp: true},
+		{descr: "include match", cfg: Config{Include: []string{`/docs/`}}, url: "https://example.com/docs/intro", exp: true},
+		{descr: "include miss", cfg: Config{Include: []string{`/docs/`}}, url: "https://example.com/blog/", exp: false},
+		{descr: "exclude", cfg: Config{Exclude: []string{`\.pdf$`}}, url: "https://example.com/paper.pdf", exp: false},
+		{descr: "exclude wins over include", cfg: Config{Include: []string{`/docs/`}, Exclude: []string{`/docs/old/`}}, url: "https://example.com/docs/old/x", exp: false},
+		{descr: "path depth within limit", cfg: Config{MaxPathDepth: 2}, url: "https://example.com/docs/intro/", exp: true},
+		{descr: "path depth over limit", cfg: Config{MaxPathDepth: 2}, url: "https://example.com/a/b/c", exp: false},
+		{descr: "query params within limit", cfg: Config{MaxQueryParams: 2}, url: "https://example.com/?a=1&b=2", exp: true},
+		{descr: "query params over limit", cfg: Config{MaxQueryParams: 2}, url: "https://example.com/?a=1;b=2&c=3", exp: false},
+		{descr: "link distance within limit", cfg: Config{MaxLinkDistance: 2}, url: "https://example.com/", distance: 2, exp: true},
+		{descr: "link distance over limit", cfg: Config{MaxLinkDistance: 2}, url: "https://example.com/", distance: 3, exp: false},
+		{descr: "unknown link distance", cfg: Config{MaxLinkDistance: 2}, url: "https://example.com/", distance: -1, exp: true},
+	}
+
+	for _, spec := range specs {
+		p, err := New(spec.cfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", spec.descr, err)
+			continue
+		}
+
+		u, err := url.Parse(spec.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.InScope(u, spec.distance); got != spec.exp {
+			t.Errorf("%s: expected %t; got %t", spec.descr, spec.exp, got)
+		}
+	}
+}
+
+func TestNewRejectsInvalidConfig(t *testing.T) {
+	specs := []struct {
+		descr string
+		cfg   Config
+	}{
+		{"empty domain", Config{AllowDomains: []string{" "}}},
+		{"bare wildcard", Config{DenyDomains: []string{"*."}}},
+		{"inner wildcard", Config{AllowDomains: []string{"www.*.example.com"}}},
+		{"invalid include", Config{Include: []string{"("}}},
+		{"invalid exclude", Config{Exclude: []string{"[a-"}}},
+	}
+
+	for _, spec := range specs {
+		if _, err := New(spec.cfg); err == nil {
+			t.Errorf("%s: expected an error", spec.descr)
+		}
+	}
+}
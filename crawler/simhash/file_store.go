@@ -0,0 +1,169 @@
+package simhash
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// recordSize is the size of a FileStore log record: the link ID, the
+// fingerprint and the ID of the duplicated page.
+const recordSize = 16 + 8 + 16
+
+// FileStore is a fingerprint store that keeps its entries in memory and
+// persists them to an append-only log file that is replayed when the store
+// is opened. Logs that mostly consist of superseded records are compacted
+// on open. FileStore is safe for concurrent use.
+type FileStore struct {
+	mem *InMemoryStore
+
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// NewFileStore opens the fingerprint log at path, creating it if it does
+// not exist.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{
+		mem:  NewInMemoryStore(),
+		path: path,
+	}
+
+	records, err := s.load()
+	if err != nil {
+		return nil, fmt.Errorf("simhash: %w", err)
+	}
+	if records > 2*len(s.mem.entries) {
+		if err = s.compact(); err != nil {
+			return nil, fmt.Errorf("simhash: %w", err)
+		}
+	}
+
+	if s.f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return nil, fmt.Errorf("simhash: %w", err)
+	}
+	return s, nil
+}
+
+// Upsert creates or updates the entry for e.LinkID.
+func (s *FileStore) Upsert(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Write(encodeRecord(e)); err != nil {
+		return fmt.Errorf("simhash: upsert: %w", err)
+	}
+	return s.mem.Upsert(e)
+}
+
+// Lookup returns the entry for linkID.
+func (s *FileStore) Lookup(linkID uuid.UUID) (Entry, bool, error) {
+	return s.mem.Lookup(linkID)
+}
+
+// FindNearDuplicate returns the ID of a non-duplicate page other than
+// exclude whose fingerprint is within maxDistance of fp.
+func (s *FileStore) FindNearDuplicate(exclude uuid.UUID, fp uint64, maxDistance int) (uuid.UUID, bool, error) {
+	return s.mem.FindNearDuplicate(exclude, fp, maxDistance)
+}
+
+// Close syncs and closes the log file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.f.Sync()
+	if cErr := s.f.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// load replays the log into the in-memory store and returns the number of
+// records read. A partially written trailing record is truncated so that
+// subsequent appends stay aligned.
+func (s *FileStore) load() (int, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var (
+		r       = bufio.NewReader(f)
+		buf     = make([]byte, recordSize)
+		records int
+	)
+	for {
+		if _, err = io.ReadFull(r, buf); err == io.EOF {
+			return records, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return records, os.Truncate(s.path, int64(records)*recordSize)
+		} else if err != nil {
+			return records, err
+		}
+		if err = s.mem.Upsert(decodeRecord(buf)); err != nil {
+			return records, err
+		}
+		records++
+	}
+}
+
+// compact rewrites the log so that it only contains the live entries.
+func (s *FileStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range s.mem.entries {
+		if _, err = w.Write(encodeRecord(e)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func encodeRecord(e Entry) []byte {
+	buf := make([]byte, recordSize)
+	copy(buf[:16], e.LinkID[:])
+	binary.BigEndian.PutUint64(buf[16:24], e.Fingerprint)
+	copy(buf[24:], e.DuplicateOf[:])
+	return buf
+}
+
+func decodeRecord(buf []byte) Entry {
+	var e Entry
+	copy(e.LinkID[:], buf[:16])
+	e.Fingerprint = binary.BigEndian.Uint64(buf[16:24])
+	copy(e.DuplicateOf[:], buf[24:])
+	return e
+}
@@ -0,0 +1,76 @@
+// Package simhash computes SimHash fingerprints for text documents and
+// provides a store for finding near-duplicate fingerprints.
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words that make up a feature.
+const shingleSize = 3
+
+// Fingerprint returns the 64-bit SimHash of text and the number of features
+// that contributed to it. Texts with no features yield a zero fingerprint
+// which should not be used for duplicate detection.
+func Fingerprint(text string) (uint64, int) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0, 0
+	}
+
+	var (
+		weights  [64]int
+		features int
+		h        = fnv.New64a()
+	)
+
+	// Short texts are treated as a single shingle.
+	numShingles := len(words) - shingleSize + 1
+	if numShingles < 1 {
+		numShingles = 1
+	}
+
+	for i := 0; i < numShingles; i++ {
+		end := i + shingleSize
+		if end > len(words) {
+			end = len(words)
+		}
+
+		h.Reset()
+		for j, w := range words[i:end] {
+			if j != 0 {
+				_, _ = h.Write([]byte{' '})
+			}
+			_, _ = h.Write([]byte(w))
+		}
+
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+		features++
+	}
+
+	var fp uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fp |= 1 << uint(bit)
+		}
+	}
+
+	return fp, features
+}
+
+// Distance returns the Hamming distance between two fingerprints.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,50 @@
+package simhash
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog while the cat watches from the fence"
+
+	fp, features := Fingerprint(text)
+	if features != 14 {
+		t.Errorf("expected 14 features; got %d", features)
+	}
+
+	// Case and punctuation do not affect the fingerprint.
+	if other, _ := Fingerprint("THE QUICK, brown fox -- jumps over the lazy dog; while the cat watches from the fence!"); other != fp {
+		t.Errorf("expected fingerprints to match; distance %d", Distance(fp, other))
+	}
+
+	near, _ := Fingerprint(text + " today")
+	far, _ := Fingerprint("Lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore")
+	if nearDist, farDist := Distance(fp, near), Distance(fp, far); nearDist >= farDist {
+		t.Errorf("expected a similar text to be closer than an unrelated one; got %d and %d", nearDist, farDist)
+	}
+}
+
+func TestFingerprintShortTexts(t *testing.T) {
+	if fp, features := Fingerprint(" -- !! "); fp != 0 || features != 0 {
+		t.Errorf("expected no features for a text without words; got %x, %d", fp, features)
+	}
+	if _, features := Fingerprint("two words"); features != 1 {
+		t.Errorf("expected a short text to be a single feature; got %d", features)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	specs := []struct {
+		a, b uint64
+		exp  int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xff, 0x0f, 4},
+		{0, ^uint64(0), 64},
+	}
+
+	for _, spec := range specs {
+		if got := Distance(spec.a, spec.b); got != spec.exp {
+			t.Errorf("Distance(%x, %x): expected %d; got %d", spec.a, spec.b, spec.exp, got)
+		}
+	}
+}
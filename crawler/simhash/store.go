@@ -0,0 +1,134 @@
+package simhash
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// numBands is the number of 16-bit bands the in-memory store splits each
+// fingerprint into. By the pigeonhole principle, two fingerprints within
+// a Hamming distance of numBands-1 share at least one identical band.
+const numBands = 4
+
+// Entry describes the fingerprint of a page and, if the page is a
+// near-duplicate, the ID of the page it duplicates.
+type Entry struct {
+	LinkID      uuid.UUID
+	Fingerprint uint64
+	DuplicateOf uuid.UUID
+}
+
+// InMemoryStore is an in-memory fingerprint store. It is safe for
+// concurrent use.
+type InMemoryStore struct {
+	mu      sync.RWMutex
+	entries map[uuid.UUID]Entry
+	bands   [numBands]map[uint16][]uuid.UUID
+}
+
+// NewInMemoryStore returns a new, empty InMemoryStore.
+func NewInMemoryStore() *InMemoryStore {
+	s := &InMemoryStore{
+		entries: make(map[uuid.UUID]Entry),
+	}
+	for i := range s.bands {
+		s.bands[i] = make(map[uint16][]uuid.UUID)
+	}
+	return s
+}
+
+// Upsert creates or updates the entry for e.LinkID.
+func (s *InMemoryStore) Upsert(e Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, exists := s.entries[e.LinkID]; exists {
+		s.unindex(old)
+	}
+
+	s.entries[e.LinkID] = e
+	// Only pages that are not duplicates themselves are candidates for
+	// near-duplicate lookups.
+	if e.DuplicateOf == uuid.Nil {
+		for i := range s.bands {
+			key := band(e.Fingerprint, i)
+			s.bands[i][key] = append(s.bands[i][key], e.LinkID)
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the entry for linkID.
+func (s *InMemoryStore) Lookup(linkID uuid.UUID) (Entry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, found := s.entries[linkID]
+	return e, found, nil
+}
+
+// FindNearDuplicate returns the ID of a non-duplicate page other than
+// exclude whose fingerprint is within maxDistance of fp.
+func (s *InMemoryStore) FindNearDuplicate(exclude uuid.UUID, fp uint64, maxDistance int) (uuid.UUID, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		bestID   uuid.UUID
+		bestDist = maxDistance + 1
+		check    = func(id uuid.UUID) {
+			if id == exclude {
+				return
+			}
+			if dist := Distance(fp, s.entries[id].Fingerprint); dist < bestDist {
+				bestID, bestDist = id, dist
+			}
+		}
+	)
+
+	if maxDistance < numBands {
+		for i := range s.bands {
+			for _, id := range s.bands[i][band(fp, i)] {
+				check(id)
+			}
+		}
+	} else {
+		// The banding scheme cannot guarantee recall for larger
+		// distances so fall back to a full scan.
+		for id, e := range s.entries {
+			if e.DuplicateOf == uuid.Nil {
+				check(id)
+			}
+		}
+	}
+
+	return bestID, bestID != uuid.Nil, nil
+}
+
+func (s *InMemoryStore) unindex(e Entry) {
+	if e.DuplicateOf != uuid.Nil {
+		return
+	}
+
+	for i := range s.bands {
+		key := band(e.Fingerprint, i)
+		ids := s.bands[i][key]
+		for j, id := range ids {
+			if id == e.LinkID {
+				ids = append(ids[:j], ids[j+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(s.bands[i], key)
+		} else {
+			s.bands[i][key] = ids
+		}
+	}
+}
+
+func band(fp uint64, i int) uint16 {
+	return uint16(fp >> (uint(i) * 16))
+}
@@ -0,0 +1,142 @@
+package simhash
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// store is implemented by both fingerprint stores.
+type store interface {
+	Upsert(e Entry) error
+	Lookup(linkID uuid.UUID) (Entry, bool, error)
+	FindNearDuplicate(exclude uuid.UUID, fp uint64, maxDistance int) (uuid.UUID, bool, error)
+}
+
+func TestInMemoryStore(t *testing.T) {
+	testStore(t, NewInMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "fingerprints.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	testStore(t, s)
+}
+
+func testStore(t *testing.T, s store) {
+	var (
+		a   = uuid.New()
+		b   = uuid.New()
+		dup = uuid.New()
+	)
+	mustUpsert(t, s, Entry{LinkID: a, Fingerprint: 0x00000000000000ff})
+	mustUpsert(t, s, Entry{LinkID: b, Fingerprint: 0xffff000000000000})
+	mustUpsert(t, s, Entry{LinkID: dup, Fingerprint: 0x00000000000000fe, DuplicateOf: a})
+
+	if e, found, err := s.Lookup(dup); err != nil || !found || e.DuplicateOf != a {
+		t.Errorf("unexpected lookup result %+v, %t, %v", e, found, err)
+	}
+	if _, found, _ := s.Lookup(uuid.New()); found {
+		t.Error("expected lookup of an unknown ID to fail")
+	}
+
+	specs := []struct {
+		descr       string
+		exclude     uuid.UUID
+		fp          uint64
+		maxDistance int
+		exp         uuid.UUID
+	}{
+		{"exact match", uuid.Nil, 0xff, 3, a},
+		{"within distance", uuid.Nil, 0xfc, 3, a},
+		{"beyond distance", uuid.Nil, 0xf0, 3, uuid.Nil},
+		{"excluded", a, 0xff, 3, uuid.Nil},
+		{"full scan", uuid.Nil, 0x0001000100010fff, 8, a},
+		{"duplicates are not candidates", uuid.Nil, 0xfe, 0, uuid.Nil},
+	}
+	for _, spec := range specs {
+		id, found, err := s.FindNearDuplicate(spec.exclude, spec.fp, spec.maxDistance)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", spec.descr, err)
+		} else if id != spec.exp || found != (spec.exp != uuid.Nil) {
+			t.Errorf("%s: expected %s; got %s, %t", spec.descr, spec.exp, id, found)
+		}
+	}
+
+	// Updating an entry replaces its fingerprint in the index.
+	mustUpsert(t, s, Entry{LinkID: a, Fingerprint: 0x0f00000000000000})
+	if _, found, _ := s.FindNearDuplicate(uuid.Nil, 0xff, 0); found {
+		t.Error("expected the old fingerprint to be removed from the index")
+	}
+	if id, _, _ := s.FindNearDuplicate(uuid.Nil, 0x0f00000000000000, 0); id != a {
+		t.Errorf("expected the new fingerprint to match %s; got %s", a, id)
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprints.log")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := uuid.New()
+	for fp := uint64(1); fp <= 5; fp++ {
+		mustUpsert(t, s, Entry{LinkID: id, Fingerprint: fp})
+	}
+	other := Entry{LinkID: uuid.New(), Fingerprint: 42, DuplicateOf: id}
+	mustUpsert(t, s, other)
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a record that was only partially written before a crash.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(encodeRecord(Entry{LinkID: uuid.New()})[:recordSize/2]); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if s, err = NewFileStore(path); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	if e, found, _ := s.Lookup(id); !found || e.Fingerprint != 5 {
+		t.Errorf("expected the latest entry to be restored; got %+v, %t", e, found)
+	}
+	if e, found, _ := s.Lookup(other.LinkID); !found || e != other {
+		t.Errorf("expected %+v to be restored; got %+v, %t", other, e, found)
+	}
+
+	// The log holds 6 records for 2 entries so it is compacted on open
+	// and the partial record is gone.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 2*recordSize {
+		t.Errorf("expected the log to be compacted to %d bytes; got %d", 2*recordSize, info.Size())
+	}
+
+	// New records are appended after the compacted ones.
+	mustUpsert(t, s, Entry{LinkID: uuid.New(), Fingerprint: 7})
+	if info, _ = os.Stat(path); info.Size() != 3*recordSize {
+		t.Errorf("expected the log to be %d bytes; got %d", 3*recordSize, info.Size())
+	}
+}
+
+func mustUpsert(t *testing.T, s store, e Entry) {
+	t.Helper()
+	if err := s.Upsert(e); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/iamleson98/go-search/crawler/robots"
+)
+
+const (
+	defaultMaxSitemaps  = 1000
+	defaultMaxURLs      = 1000000
+	defaultMaxSize      = 50 << 20
+	defaultFetchTimeout = 30 * time.Second
+	defaultMinDelay     = time.Second
+)
+
+// Getter is implemented by objects that can perform HTTP requests.
+// *http.Client satisfies this interface.
+type Getter interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// PrivateNetworkDetector is implemented by objects that can detect whether a
+// host belongs to a private network. *privnet.Detector satisfies this
+// interface.
+type PrivateNetworkDetector interface {
+	IsPrivate(host string) (bool, error)
+}
+
+// RobotsSource is implemented by objects that can provide the robots.txt
+// rules for a host. *robots.Checker satisfies this interface.
+type RobotsSource interface {
+	Rules(ctx context.Context, u *url.URL) (*robots.Rules, error)
+}
+
+// Config encapsulates the settings for a Fetcher.
+type Config struct {
+	// The Getter for retrieving sitemaps. Required.
+	Getter Getter
+
+	// An optional RobotsSource for discovering the sitemaps advertised in
+	// robots.txt files. Sites that do not advertise any sitemaps are
+	// checked for a /sitemap.xml file.
+	Robots RobotsSource
+
+	// An optional PrivateNetworkDetector. If specified, sitemaps on hosts
+	// that belong to a private network are skipped. This also applies to
+	// the cross-host sitemaps advertised by robots.txt files and indices.
+	NetDetector PrivateNetworkDetector
+
+	// The max number of sitemaps retrieved per site, including sitemap
+	// index files. Defaults to 1000.
+	MaxSitemaps int
+
+	// The max number of URLs reported per site. Defaults to 1M.
+	MaxURLs int
+
+	// The max (decompressed) size of a sitemap. Defaults to 50MiB as per
+	// the sitemap protocol.
+	MaxSize int64
+
+	// FetchTimeout bounds the time spent retrieving a sitemap. Defaults
+	// to 30 seconds.
+	FetchTimeout time.Duration
+
+	// The min delay between the sitemap requests of a walk, measured
+	// from the completion of the previous request. The Crawl-delay of
+	// the site is used instead if it is longer. Defaults to 1 second.
+	MinDelay time.Duration
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Getter == nil {
+		return fmt.Errorf("getter not specified")
+	}
+	if cfg.MaxSitemaps <= 0 {
+		cfg.MaxSitemaps = defaultMaxSitemaps
+	}
+	if cfg.MaxURLs <= 0 {
+		cfg.MaxURLs = defaultMaxURLs
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultMaxSize
+	}
+	if cfg.FetchTimeout <= 0 {
+		cfg.FetchTimeout = defaultFetchTimeout
+	}
+	if cfg.MinDelay <= 0 {
+		cfg.MinDelay = defaultMinDelay
+	}
+	return nil
+}
+
+// Fetcher discovers and retrieves the sitemaps of a site. It is safe for
+// concurrent use.
+type Fetcher struct {
+	cfg Config
+}
+
+// NewFetcher returns a new Fetcher using the provided config.
+func NewFetcher(cfg Config) (*Fetcher, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("sitemap: config validation failed: %w", err)
+	}
+
+	return &Fetcher{cfg: cfg}, nil
+}
+
+// Discover returns the URLs of the sitemaps of the site that u belongs to:
+// the ones advertised by its robots.txt file or, if there are none, the
+// default /sitemap.xml location.
+func (f *Fetcher) Discover(ctx context.Context, u *url.URL) ([]string, error) {
+	sitemaps, _, err := f.discover(ctx, u)
+	return sitemaps, err
+}
+
+// discover returns the sitemap URLs of the site that u belongs to along
+// with the delay between requests to the site.
+func (f *Fetcher) discover(ctx context.Context, u *url.URL) ([]string, time.Duration, error) {
+	delay := f.cfg.MinDelay
+	if f.cfg.Robots != nil {
+		rules, err := f.cfg.Robots.Rules(ctx, u)
+		if err != nil {
+			return nil, 0, fmt.Errorf("sitemap discovery: %w", err)
+		}
+		if crawlDelay := rules.CrawlDelay(); crawlDelay > delay {
+			delay = crawlDelay
+		}
+		if len(rules.Sitemaps) != 0 {
+			return append([]string(nil), rules.Sitemaps...), delay, nil
+		}
+	}
+
+	return []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}, delay, nil
+}
+
+// Walk retrieves the sitemaps of the site that u belongs to, following
+// sitemap indices, and invokes fn for each listed URL. Only URLs on the
+// same host as u are reported. Sitemaps listed in an index with a lastmod
+// before since are not retrieved; pass the zero time to retrieve all of
+// them. Sitemaps that cannot be retrieved or parsed are skipped and their
+// errors returned once all other sitemaps have been processed. An error
+// returned by fn aborts the walk.
+func (f *Fetcher) Walk(ctx context.Context, u *url.URL, since time.Time, fn func(URL) error) error {
+	queue, delay, err := f.discover(ctx, u)
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs    error
+		visited = make(map[string]bool)
+		urls    int
+	)
+	for len(queue) != 0 && len(visited) < f.cfg.MaxSitemaps {
+		sitemapURL := queue[0]
+		queue = queue[1:]
+		if visited[sitemapURL] {
+			continue
+		}
+
+		if isPrivate, err := f.isPrivate(sitemapURL); err != nil || isPrivate {
+			visited[sitemapURL] = true
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			continue
+		}
+
+		if len(visited) != 0 {
+			if err = sleep(ctx, delay); err != nil {
+				return err
+			}
+		}
+		visited[sitemapURL] = true
+
+		sm, err := f.Fetch(ctx, sitemapURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		for _, ref := range sm.Sitemaps {
+			if !ref.LastMod.IsZero() && ref.LastMod.Before(since) {
+				continue
+			}
+			queue = append(queue, ref.Loc)
+		}
+		for _, entry := range sm.URLs {
+			if !sameHost(u, entry.Loc) {
+				continue
+			}
+			if urls == f.cfg.MaxURLs {
+				return errs
+			}
+			urls++
+			if err = fn(entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errs
+}
+
+// Fetch retrieves and parses a single sitemap.
+func (f *Fetcher) Fetch(ctx context.Context, sitemapURL string) (*Sitemap, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, f.cfg.FetchTimeout)
+	defer cancelFn()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+
+	res, err := f.cfg.Getter.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("sitemap %s: unexpected status code %d", sitemapURL, res.StatusCode)
+	}
+
+	sm, err := Parse(res.Body, f.cfg.MaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+	return sm, nil
+}
+
+// isPrivate returns true if sitemapURL points to a host on a private
+// network.
+func (f *Fetcher) isPrivate(sitemapURL string) (bool, error) {
+	if f.cfg.NetDetector == nil {
+		return false, nil
+	}
+
+	u, err := url.Parse(sitemapURL)
+	if err != nil {
+		return false, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+	isPrivate, err := f.cfg.NetDetector.IsPrivate(u.Hostname())
+	if err != nil {
+		return false, fmt.Errorf("sitemap %s: %w", sitemapURL, err)
+	}
+	return isPrivate, nil
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sameHost returns true if rawURL is an absolute http(s) URL on the same
+// host as u.
+func sameHost(u *url.URL, rawURL string) bool {
+	other, err := url.Parse(rawURL)
+	if err != nil || (other.Scheme != "http" && other.Scheme != "https") {
+		return false
+	}
+	return strings.EqualFold(other.Host, u.Host)
+}
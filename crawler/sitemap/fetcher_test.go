@@ -0,0 +1,213 @@
+package sitemap
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamleson98/go-search/crawler/robots"
+)
+
+func TestDiscover(t *testing.T) {
+	u, _ := url.Parse("https://example.com/page")
+
+	f := newTestFetcher(t, Config{Getter: http.DefaultClient})
+	if got, err := f.Discover(context.Background(), u); err != nil || len(got) != 1 || got[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("expected the default sitemap location; got %v, %v", got, err)
+	}
+
+	f = newTestFetcher(t, Config{Getter: http.DefaultClient, Robots: fakeRobots("Sitemap: https://cdn.example.com/sitemap.xml\n")})
+	if got, err := f.Discover(context.Background(), u); err != nil || len(got) != 1 || got[0] != "https://cdn.example.com/sitemap.xml" {
+		t.Errorf("expected the advertised sitemap; got %v, %v", got, err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	site := newSitemapServer(t, map[string]string{
+		"/sitemap_index.xml": `<sitemapindex>
+  <sitemap><loc>{{base}}/pages.xml</loc><lastmod>2024-03-01</lastmod></sitemap>
+  <sitemap><loc>{{base}}/old.xml</loc><lastmod>2020-01-01</lastmod></sitemap>
+  <sitemap><loc>{{base}}/missing.xml</loc></sitemap>
+  <sitemap><loc>{{base}}/sitemap_index.xml</loc></sitemap>
+</sitemapindex>`,
+		"/pages.xml": `<urlset>
+  <url><loc>{{base}}/a</loc><changefreq>daily</changefreq></url>
+  <url><loc>https://other.example.com/b</loc></url>
+  <url><loc>{{base}}/c</loc></url>
+</urlset>`,
+		"/old.xml": `<urlset><url><loc>{{base}}/old</loc></url></urlset>`,
+	})
+
+	f := newTestFetcher(t, Config{
+		Getter: site.srv.Client(),
+		Robots: fakeRobots("Sitemap: " + site.srv.URL + "/sitemap_index.xml\n"),
+	})
+	u, _ := url.Parse(site.srv.URL + "/")
+
+	var got []string
+	err := f.Walk(context.Background(), u, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), func(entry URL) error {
+		got = append(got, strings.TrimPrefix(entry.Loc, site.srv.URL))
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing.xml: unexpected status code 404") {
+		t.Errorf("expected the error for the missing sitemap; got %v", err)
+	}
+	if strings.Join(got, ",") != "/a,/c" {
+		t.Errorf("expected the same-host URLs of the recent sitemaps; got %v", got)
+	}
+
+	// Each sitemap is only retrieved once.
+	if exp := []string{"/missing.xml", "/pages.xml", "/sitemap_index.xml"}; strings.Join(site.requests(), ",") != strings.Join(exp, ",") {
+		t.Errorf("expected requests for %v; got %v", exp, site.requests())
+	}
+}
+
+func TestWalkLimits(t *testing.T) {
+	site := newSitemapServer(t, map[string]string{
+		"/sitemap.xml": `<sitemapindex>
+  <sitemap><loc>{{base}}/1.xml</loc></sitemap>
+  <sitemap><loc>{{base}}/2.xml</loc></sitemap>
+</sitemapindex>`,
+		"/1.xml": "{{base}}/a\n{{base}}/b\n{{base}}/c\n",
+		"/2.xml": "{{base}}/d\n",
+	})
+	u, _ := url.Parse(site.srv.URL + "/")
+
+	f := newTestFetcher(t, Config{Getter: site.srv.Client(), MaxURLs: 2})
+	var urls int
+	if err := f.Walk(context.Background(), u, time.Time{}, func(URL) error { urls++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if urls != 2 {
+		t.Errorf("expected the walk to stop after 2 URLs; got %d", urls)
+	}
+
+	f = newTestFetcher(t, Config{Getter: site.srv.Client(), MaxSitemaps: 2})
+	urls = 0
+	if err := f.Walk(context.Background(), u, time.Time{}, func(URL) error { urls++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if urls != 3 {
+		t.Errorf("expected the walk to stop after 2 sitemaps; got %d URLs", urls)
+	}
+
+	errStop := fmt.Errorf("stop")
+	if err := f.Walk(context.Background(), u, time.Time{}, func(URL) error { return errStop }); err != errStop {
+		t.Errorf("expected the callback error to abort the walk; got %v", err)
+	}
+}
+
+func TestWalkSkipsPrivateSitemaps(t *testing.T) {
+	site := newSitemapServer(t, map[string]string{
+		"/sitemap.xml": "{{base}}/a\n",
+	})
+	u, _ := url.Parse(site.srv.URL + "/")
+
+	f := newTestFetcher(t, Config{
+		Getter:      site.srv.Client(),
+		Robots:      fakeRobots("Sitemap: http://intranet.example.com/sitemap.xml\nSitemap: " + site.srv.URL + "/sitemap.xml\n"),
+		NetDetector: fakeDetector{"intranet.example.com": true},
+	})
+
+	var got []string
+	if err := f.Walk(context.Background(), u, time.Time{}, func(entry URL) error {
+		got = append(got, entry.Loc)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(site.requests()) != 1 {
+		t.Errorf("expected only the public sitemap to be retrieved; got %v", got)
+	}
+}
+
+func TestWalkHonorsCancellation(t *testing.T) {
+	site := newSitemapServer(t, map[string]string{
+		"/sitemap.xml": `<sitemapindex><sitemap><loc>{{base}}/1.xml</loc></sitemap></sitemapindex>`,
+		"/1.xml":       "{{base}}/a\n",
+	})
+	u, _ := url.Parse(site.srv.URL + "/")
+
+	f := newTestFetcher(t, Config{Getter: site.srv.Client(), MinDelay: time.Hour})
+	ctx, cancelFn := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelFn()
+
+	if err := f.Walk(ctx, u, time.Time{}, func(URL) error { return nil }); err != context.DeadlineExceeded {
+		t.Errorf("expected the walk to be interrupted while waiting; got %v", err)
+	}
+}
+
+func TestNewFetcherRequiresGetter(t *testing.T) {
+	if _, err := NewFetcher(Config{}); err == nil {
+		t.Error("expected an error for a missing getter")
+	}
+}
+
+func newTestFetcher(t *testing.T, cfg Config) *Fetcher {
+	if cfg.MinDelay == 0 {
+		cfg.MinDelay = time.Millisecond
+	}
+	f, err := NewFetcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// sitemapServer serves documents in which "{{base}}" is replaced with the
+// URL of the server.
+type sitemapServer struct {
+	srv *httptest.Server
+
+	mu   sync.Mutex
+	reqs []string
+}
+
+func newSitemapServer(t *testing.T, docs map[string]string) *sitemapServer {
+	s := new(sitemapServer)
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.reqs = append(s.reqs, r.URL.Path)
+		s.mu.Unlock()
+
+		doc, found := docs[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, strings.ReplaceAll(doc, "{{base}}", s.srv.URL))
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// requests returns the sorted paths requested from the server.
+func (s *sitemapServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := append([]string(nil), s.reqs...)
+	sort.Strings(reqs)
+	return reqs
+}
+
+// fakeRobots is a RobotsSource that serves the same robots.txt file for
+// every host.
+type fakeRobots string
+
+func (r fakeRobots) Rules(context.Context, *url.URL) (*robots.Rules, error) {
+	return robots.Parse(strings.NewReader(string(r)), "GoSearchBot")
+}
+
+// fakeDetector maps host names to whether they are private.
+type fakeDetector map[string]bool
+
+func (d fakeDetector) IsPrivate(host string) (bool, error) {
+	return d[host], nil
+}
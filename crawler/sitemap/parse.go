@@ -0,0 +1,199 @@
+// Package sitemap discovers, retrieves and parses XML sitemaps as described
+// by the sitemaps.org protocol.
+package sitemap
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPriority is the priority of URLs that do not declare one.
+const DefaultPriority = 0.5
+
+// ChangeFreq is the change frequency that a sitemap declares for a URL.
+type ChangeFreq string
+
+// The change frequencies defined by the sitemap protocol.
+const (
+	ChangeAlways  ChangeFreq = "always"
+	ChangeHourly  ChangeFreq = "hourly"
+	ChangeDaily   ChangeFreq = "daily"
+	ChangeWeekly  ChangeFreq = "weekly"
+	ChangeMonthly ChangeFreq = "monthly"
+	ChangeYearly  ChangeFreq = "yearly"
+	ChangeNever   ChangeFreq = "never"
+)
+
+var changeFreqIntervals = map[ChangeFreq]time.Duration{
+	ChangeAlways:  time.Minute,
+	ChangeHourly:  time.Hour,
+	ChangeDaily:   24 * time.Hour,
+	ChangeWeekly:  7 * 24 * time.Hour,
+	ChangeMonthly: 30 * 24 * time.Hour,
+	ChangeYearly:  365 * 24 * time.Hour,
+	// Archived pages; callers are expected to clamp the interval.
+	ChangeNever: 10 * 365 * 24 * time.Hour,
+}
+
+// Interval returns the expected time between changes of a URL with this
+// change frequency or zero if the frequency is unknown.
+func (c ChangeFreq) Interval() time.Duration {
+	return changeFreqIntervals[c]
+}
+
+// URL is an entry of a urlset sitemap.
+type URL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq ChangeFreq
+	Priority   float64
+}
+
+// Ref is an entry of a sitemap index that points to another sitemap.
+type Ref struct {
+	Loc     string
+	LastMod time.Time
+}
+
+// Sitemap is a parsed sitemap. For sitemap index files only Sitemaps is
+// populated; for urlset and text sitemaps only URLs.
+type Sitemap struct {
+	URLs     []URL
+	Sitemaps []Ref
+}
+
+type xmlSitemap struct {
+	XMLName xml.Name
+	URLs    []struct {
+		Loc        string `xml:"loc"`
+		LastMod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"sitemap"`
+}
+
+// Parse parses an XML (urlset or sitemap index) or text sitemap. Gzipped
+// sitemaps are decompressed transparently. At most maxSize bytes of
+// (decompressed) content are read; a non-positive maxSize disables the
+// limit.
+func Parse(r io.Reader, maxSize int64) (*Sitemap, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("sitemap: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		br = bufio.NewReader(gz)
+	}
+
+	var src io.Reader = br
+	if maxSize > 0 {
+		src = io.LimitReader(br, maxSize)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("sitemap: %w", err)
+	}
+
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("\xef\xbb\xbf"))
+	if len(data) == 0 || data[0] != '<' {
+		return parseText(data), nil
+	}
+	return parseXML(data)
+}
+
+func parseXML(data []byte) (*Sitemap, error) {
+	var doc xmlSitemap
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("sitemap: %w", err)
+	}
+
+	sm := new(Sitemap)
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" {
+				continue
+			}
+			sm.URLs = append(sm.URLs, URL{
+				Loc:        loc,
+				LastMod:    parseLastMod(u.LastMod),
+				ChangeFreq: ChangeFreq(strings.ToLower(strings.TrimSpace(u.ChangeFreq))),
+				Priority:   parsePriority(u.Priority),
+			})
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			loc := strings.TrimSpace(s.Loc)
+			if loc == "" {
+				continue
+			}
+			sm.Sitemaps = append(sm.Sitemaps, Ref{Loc: loc, LastMod: parseLastMod(s.LastMod)})
+		}
+	default:
+		return nil, fmt.Errorf("sitemap: unexpected root element %q", doc.XMLName.Local)
+	}
+	return sm, nil
+}
+
+// parseText parses a text sitemap which lists one URL per line.
+func parseText(data []byte) *Sitemap {
+	sm := new(Sitemap)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			sm.URLs = append(sm.URLs, URL{Loc: line, Priority: DefaultPriority})
+		}
+	}
+	return sm
+}
+
+// lastModLayouts are the W3C datetime formats allowed for lastmod values.
+var lastModLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseLastMod returns the parsed lastmod value or the zero time if it is
+// missing or malformed.
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range lastModLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// parsePriority returns the parsed priority clamped to [0, 1] or the default
+// priority if it is missing or malformed.
+func parsePriority(s string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(p) {
+		return DefaultPriority
+	}
+	if p < 0 {
+		return 0
+	} else if p > 1 {
+		return 1
+	}
+	return p
+}
@@ -0,0 +1,113 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseURLSet(t *testing.T) {
+	sm, err := Parse(strings.NewReader("\xef\xbb\xbf"+`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc> https://example.com/ </loc>
+    <lastmod>2024-03-01T10:20:30+02:00</lastmod>
+    <changefreq>Daily</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>https://example.com/about</loc>
+    <lastmod>2024-02</lastmod>
+    <priority>1.7</priority>
+  </url>
+  <url>
+    <loc>https://example.com/old</loc>
+    <lastmod>yesterday</lastmod>
+    <priority>high</priority>
+  </url>
+  <url><loc></loc></url>
+</urlset>`), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []URL{
+		{Loc: "https://example.com/", LastMod: time.Date(2024, 3, 1, 8, 20, 30, 0, time.UTC), ChangeFreq: ChangeDaily, Priority: 0.8},
+		{Loc: "https://example.com/about", LastMod: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Priority: 1},
+		{Loc: "https://example.com/old", Priority: DefaultPriority},
+	}
+	if !reflect.DeepEqual(sm.URLs, exp) {
+		t.Errorf("expected %+v; got %+v", exp, sm.URLs)
+	}
+	if len(sm.Sitemaps) != 0 {
+		t.Errorf("expected no sitemap refs; got %+v", sm.Sitemaps)
+	}
+}
+
+func TestParseIndex(t *testing.T) {
+	sm, err := Parse(strings.NewReader(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/a.xml</loc><lastmod>2024-01-02</lastmod></sitemap>
+  <sitemap><loc>https://example.com/b.xml.gz</loc></sitemap>
+</sitemapindex>`), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Ref{
+		{Loc: "https://example.com/a.xml", LastMod: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Loc: "https://example.com/b.xml.gz"},
+	}
+	if !reflect.DeepEqual(sm.Sitemaps, exp) {
+		t.Errorf("expected %+v; got %+v", exp, sm.Sitemaps)
+	}
+}
+
+func TestParseTextAndGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, _ = gz.Write([]byte("https://example.com/a\r\n\n  https://example.com/b  \n"))
+	_ = gz.Close()
+
+	sm, err := Parse(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []URL{
+		{Loc: "https://example.com/a", Priority: DefaultPriority},
+		{Loc: "https://example.com/b", Priority: DefaultPriority},
+	}
+	if !reflect.DeepEqual(sm.URLs, exp) {
+		t.Errorf("expected %+v; got %+v", exp, sm.URLs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	specs := []struct {
+		descr   string
+		doc     string
+		maxSize int64
+	}{
+		{"unexpected root", `<rss><channel/></rss>`, 0},
+		{"malformed xml", `<urlset><url><loc>x</url>`, 0},
+		{"truncated by size limit", `<urlset><url><loc>https://example.com/</loc></url></urlset>`, 20},
+		{"corrupt gzip", "\x1f\x8b\x00\x00", 0},
+	}
+
+	for _, spec := range specs {
+		if _, err := Parse(strings.NewReader(spec.doc), spec.maxSize); err == nil {
+			t.Errorf("%s: expected an error", spec.descr)
+		}
+	}
+}
+
+func TestChangeFreqInterval(t *testing.T) {
+	if got := ChangeWeekly.Interval(); got != 7*24*time.Hour {
+		t.Errorf("expected a weekly interval; got %s", got)
+	}
+	if got := ChangeFreq("sometimes").Interval(); got != 0 {
+		t.Errorf("expected no interval for an unknown frequency; got %s", got)
+	}
+}
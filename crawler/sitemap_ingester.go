@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iamleson98/go-search/crawler/frontier"
+	"github.com/iamleson98/go-search/crawler/sitemap"
+	"github.com/iamleson98/go-search/crawler/traps"
+	"github.com/iamleson98/go-search/crawler/urlnorm"
+	"github.com/iamleson98/go-search/linkgraph/graph"
+)
+
+// SitemapSource is implemented by objects that can enumerate the URLs
+// listed in the sitemaps of a site. *sitemap.Fetcher satisfies this
+// interface.
+type SitemapSource interface {
+	Walk(ctx context.Context, site *url.URL, since time.Time, fn func(sitemap.URL) error) error
+}
+
+// ScheduleHinter is implemented by objects that accept the scheduling
+// information declared by sites. *frontier.Frontier satisfies this
+// interface.
+type ScheduleHinter interface {
+	Hint(link *graph.Link, h frontier.Hint) error
+}
+
+// SitemapIngester seeds the link graph with the URLs listed in the sitemaps
+// of a site. This makes deep pages that are poorly linked from the rest of
+// the site reachable by the crawler. SitemapIngester is safe for concurrent
+// use.
+type SitemapIngester struct {
+	source      SitemapSource
+	graph       Graph
+	hinter      ScheduleHinter
+	netDetector PrivateNetworkDetector
+	scope       ScopePolicy
+	traps       TrapDetector
+	normalizer  *urlnorm.Normalizer
+
+	// The start time of the last successful ingest of each site.
+	mu           sync.Mutex
+	lastIngested map[string]time.Time
+}
+
+// NewSitemapIngester returns a SitemapIngester that adds the URLs obtained
+// from source to cfg.Graph, subject to the private network detector, URL
+// normalizer, scope and trap detector in cfg. If hinter is not nil, the lastmod, changefreq and
+// priority values of the sitemap entries are forwarded to it.
+func NewSitemapIngester(cfg Config, source SitemapSource, hinter ScheduleHinter) *SitemapIngester {
+	if cfg.URLNormalizer == nil {
+		cfg.URLNormalizer = urlnorm.New(urlnorm.Config{})
+	}
+
+	return &SitemapIngester{
+		source:       source,
+		graph:        cfg.Graph,
+		hinter:       hinter,
+		netDetector:  cfg.PrivateNetworkDetector,
+		scope:        cfg.Scope,
+		traps:        cfg.Traps,
+		normalizer:   cfg.URLNormalizer,
+		lastIngested: make(map[string]time.Time),
+	}
+}
+
+// Ingest adds the URLs listed in the sitemaps of site to the link graph and
+// returns the number of added (or refreshed) links. Sitemaps that an index
+// reports as unmodified since the last successful ingest of the site are
+// skipped. Sites on private networks are not ingested. New sitemap URLs
+// have an unknown depth and are thus not subject to the max link distance.
+func (si *SitemapIngester) Ingest(ctx context.Context, site *url.URL) (int, error) {
+	// Walk requests the robots.txt file of the site before it retrieves
+	// any sitemaps.
+	if isPrivate, err := si.netDetector.IsPrivate(site.Hostname()); err != nil || isPrivate {
+		return 0, err
+	}
+
+	siteKey := strings.ToLower(site.Host)
+	si.mu.Lock()
+	since := si.lastIngested[siteKey]
+	si.mu.Unlock()
+
+	var (
+		count     int
+		startedAt = time.Now()
+	)
+	err := si.source.Walk(ctx, site, since, func(entry sitemap.URL) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		u, err := url.Parse(entry.Loc)
+		if err != nil {
+			return nil
+		}
+		if u, err = si.normalizer.Normalize(u); err != nil {
+			return nil
+		}
+		if exclusionRegex.MatchString(u.String()) {
+			return nil
+		}
+		if si.scope != nil && !si.scope.InScope(u, graph.UnknownDepth) {
+			return nil
+		}
+		if si.traps != nil && si.traps.Check(u) != traps.ReasonNone {
+			return nil
+		}
+
+		// Sitemaps do not tell how far a URL is from the seeds; links
+		// that are already known keep their depth.
+		link := &graph.Link{URL: u.String(), Depth: graph.UnknownDepth}
+		if err = si.graph.UpsertLink(link); err != nil {
+			return err
+		}
+		count++
+
+		if si.hinter == nil {
+			return nil
+		}
+		return si.hinter.Hint(link, frontier.Hint{
+			LastModified:   entry.LastMod,
+			ChangeInterval: entry.ChangeFreq.Interval(),
+			Priority:       entry.Priority,
+		})
+	})
+
+	if err == nil {
+		si.mu.Lock()
+		si.lastIngested[siteKey] = startedAt
+		si.mu.Unlock()
+	}
+	return count, err
+}
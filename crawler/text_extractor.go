@@ -3,6 +3,7 @@ package crawler
 import (
 	"context"
 	"html"
+	"net/url"
 	"regexp"
 	"strings"
 	"sync"
@@ -13,6 +14,7 @@ import (
 
 var (
 	titleRegex         = regexp.MustCompile(`(?i)<title.*?>(.*?)</title>`)
+	htmlLangRegex      = regexp.MustCompile(`(?i)<html\s[^>]*?\blang\s*=\s*["']?([a-z0-9_-]+)`)
 	repeatedSpaceRegex = regexp.MustCompile(`\s+`)
 )
 
@@ -32,6 +34,10 @@ func newTextExtrator() *textExtrator {
 
 func (te *textExtrator) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if payload.NotModified {
+		return payload, nil
+	}
+
 	policy := te.policyPool.Get().(*bluemonday.Policy)
 
 	if titleMatch := titleRegex.FindStringSubmatch(payload.RawContent.String()); len(titleMatch) == 2 {
@@ -40,6 +46,19 @@ func (te *textExtrator) Process(ctx context.Context, p pipeline.Payload) (pipeli
 		)))
 	}
 
+	if langMatch := htmlLangRegex.FindStringSubmatch(payload.RawContent.String()); len(langMatch) == 2 {
+		payload.DeclaredLanguage = langMatch[1]
+	}
+
+	payload.Metadata = scanMetadata(payload.RawContent.Bytes())
+	if payload.Metadata.OGImage != "" {
+		if pageURL, err := url.Parse(payload.URL); err == nil {
+			if imageURL := resolveURL(pageURL, payload.Metadata.OGImage); imageURL != nil {
+				payload.Metadata.OGImage = imageURL.String()
+			}
+		}
+	}
+
 	payload.TextContent = strings.TrimSpace(html.UnescapeString(repeatedSpaceRegex.ReplaceAllString(
 		policy.SanitizeReader(&payload.RawContent).String(), " ",
 	)))
@@ -8,29 +8,72 @@ import (
 	"github.com/iamleson98/go-search/textindexer/index"
 )
 
+// maxInboundAnchors is the max number of distinct inbound anchor texts that
+// are indexed for each document.
+const maxInboundAnchors = 100
+
 type textIndexer struct {
 	indexer Indexer
+	graph   Graph
 }
 
-func newTextIndexer(indexer Indexer) *textIndexer {
+func newTextIndexer(indexer Indexer, graph Graph) *textIndexer {
 	return &textIndexer{
 		indexer: indexer,
+		graph:   graph,
 	}
 }
 
 func (i *textIndexer) Process(ctx context.Context, p pipeline.Payload) (pipeline.Payload, error) {
 	payload := p.(*crawlerPayload)
+	if payload.isDuplicate() {
+		return p, nil
+	}
+
+	// Unchanged pages are not re-indexed but links to them may have been
+	// added or changed since they were indexed.
+	if payload.NotModified {
+		anchors, err := i.graph.InboundAnchorText(payload.LinkID, maxInboundAnchors)
+		if err != nil {
+			return nil, err
+		}
+		if err = i.indexer.UpdateAnchorText(payload.LinkID, anchors); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
 
 	doc := &index.Document{
 		LinkID:    payload.LinkID,
 		URL:       payload.URL,
 		Title:     payload.Title,
 		Content:   payload.TextContent,
+		Charset:   payload.Charset,
+		Language:  payload.Language,
 		IndexedAt: time.Now(),
+
+		Description:   payload.Metadata.Description,
+		Keywords:      payload.Metadata.Keywords,
+		Headings:      payload.Metadata.Headings,
+		OGTitle:       payload.Metadata.OGTitle,
+		OGDescription: payload.Metadata.OGDescription,
+		OGImage:       payload.Metadata.OGImage,
+		CanonicalURL:  payload.CanonicalURL,
+		PublishedAt:   payload.Metadata.PublishedAt,
 	}
+
+	// Pages are usually crawled after (some of) the pages linking to them
+	// so the anchor text of their inbound links is already known.
+	anchors, err := i.graph.InboundAnchorText(payload.LinkID, maxInboundAnchors)
+	if err != nil {
+		return nil, err
+	}
+	doc.AnchorText = anchors
+
 	if err := i.indexer.Index(doc); err != nil {
 		return nil, err
 	}
+	reportCollectorFrom(ctx).recordIndexed(payload.LinkURL)
 
 	return p, nil
 }
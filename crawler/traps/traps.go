@@ -0,0 +1,424 @@
+// Package traps detects crawler traps: sites that generate an unbounded
+// number of URLs, e.g. via infinite calendar pagination, repeating path
+// segments, session IDs or faceted navigation.
+package traps
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxURLLength       = 2048
+	defaultMaxSegmentRepeats  = 2
+	defaultCalendarYearsBack  = 20
+	defaultCalendarYearsAhead = 1
+	defaultMaxURLsPerHost     = 10000
+	defaultTrapThreshold      = 50
+	defaultTrappedHostBudget  = 500
+	defaultTrappedPasses      = 3
+
+	// Numbers outside this range are not treated as years, which avoids
+	// mistaking IDs such as "/products/1234/10" for dates.
+	minPlausibleYear = 1800
+	maxPlausibleYear = 2999
+)
+
+// Reason describes why a URL was rejected.
+type Reason int
+
+// The possible rejection reasons.
+const (
+	// The URL was not rejected.
+	ReasonNone Reason = iota
+
+	// The URL is longer than Config.MaxURLLength.
+	ReasonURLTooLong
+
+	// A path segment is repeated more than Config.MaxSegmentRepeats times.
+	ReasonRepeatedSegments
+
+	// The URL path embeds a cookieless session segment such as the
+	// "(S(...))" segments of ASP.NET.
+	ReasonSessionID
+
+	// The URL refers to a calendar date outside the configured window.
+	ReasonCalendar
+
+	// The host has exhausted its per-pass URL budget.
+	ReasonHostBudget
+)
+
+var reasonNames = map[Reason]string{
+	ReasonNone:             "none",
+	ReasonURLTooLong:       "url-too-long",
+	ReasonRepeatedSegments: "repeated-segments",
+	ReasonSessionID:        "session-id",
+	ReasonCalendar:         "calendar",
+	ReasonHostBudget:       "host-budget",
+}
+
+func (r Reason) String() string {
+	if name, ok := reasonNames[r]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var (
+	// Matches the cookieless session segments of ASP.NET, e.g. "(S(abc))".
+	aspNetSessionRegex = regexp.MustCompile(`^\((?:[A-Za-z]\([^)]*\))+\)$`)
+
+	// Matches dates such as "2021-05" or "2021-05-17" in path segments.
+	pathDateRegex = regexp.MustCompile(`^(\d{4})-(\d{1,2})(?:-\d{1,2})?$`)
+
+	// Matches a year in a query parameter value.
+	queryYearRegex = regexp.MustCompile(`(?:^|\D)(\d{4})(?:\D|$)`)
+
+	// Matches the names of query parameters that hold dates.
+	dateParamRegex = regexp.MustCompile(`(?i)date|year|month|day|week|cal`)
+
+	yearSegmentRegex  = regexp.MustCompile(`^\d{4}$`)
+	monthSegmentRegex = regexp.MustCompile(`^\d{1,2}$`)
+)
+
+// Config encapsulates the settings for a Detector.
+type Config struct {
+	// The max length of a URL. Defaults to 2048.
+	MaxURLLength int
+
+	// The max number of times a path segment may appear in a URL.
+	// Defaults to 2.
+	MaxSegmentRepeats int
+
+	// Calendar URLs referring to years more than CalendarYearsBack years
+	// in the past or CalendarYearsAhead years in the future are rejected.
+	// Default to 20 and 1 respectively.
+	CalendarYearsBack  int
+	CalendarYearsAhead int
+
+	// The max number of unique URLs admitted per host and pass. Defaults
+	// to 10000.
+	MaxURLsPerHost int
+
+	// The number of unique URLs rejected by the trap heuristics during a
+	// pass after which a host is flagged as trapped. Defaults to 50.
+	TrapThreshold int
+
+	// The number of subsequent passes during which a host that was
+	// flagged as trapped keeps the reduced budget. Flagging the host
+	// again restarts the count. Defaults to 3.
+	TrappedPasses int
+
+	// The max number of unique URLs admitted per pass for hosts that have
+	// been flagged as trapped. Defaults to 500 (or MaxURLsPerHost if
+	// lower).
+	TrappedHostBudget int
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	if cfg.MaxURLLength <= 0 {
+		cfg.MaxURLLength = defaultMaxURLLength
+	}
+	if cfg.MaxSegmentRepeats <= 0 {
+		cfg.MaxSegmentRepeats = defaultMaxSegmentRepeats
+	}
+	if cfg.CalendarYearsBack <= 0 {
+		cfg.CalendarYearsBack = defaultCalendarYearsBack
+	}
+	if cfg.CalendarYearsAhead <= 0 {
+		cfg.CalendarYearsAhead = defaultCalendarYearsAhead
+	}
+	if cfg.MaxURLsPerHost <= 0 {
+		cfg.MaxURLsPerHost = defaultMaxURLsPerHost
+	}
+	if cfg.TrapThreshold <= 0 {
+		cfg.TrapThreshold = defaultTrapThreshold
+	}
+	if cfg.TrappedHostBudget <= 0 {
+		cfg.TrappedHostBudget = defaultTrappedHostBudget
+		if cfg.TrappedHostBudget > cfg.MaxURLsPerHost {
+			cfg.TrappedHostBudget = cfg.MaxURLsPerHost
+		}
+	}
+	if cfg.TrappedPasses <= 0 {
+		cfg.TrappedPasses = defaultTrappedPasses
+	}
+	if cfg.TrappedHostBudget > cfg.MaxURLsPerHost {
+		return fmt.Errorf("trapped host budget cannot exceed the max URLs per host")
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// HostReport describes a host that was flagged as trapped or exhausted
+// its URL budget during a pass.
+type HostReport struct {
+	Host string
+
+	// The number of unique URLs admitted during the pass.
+	URLs int
+
+	// The number of rejected URLs per reason. URLs rejected by the trap
+	// heuristics are counted once per pass until the host is flagged as
+	// trapped; after that every rejection is counted.
+	Rejected map[Reason]int
+
+	// Trapped is set if the host was flagged as trapped during the pass
+	// or one of the previous Config.TrappedPasses passes and
+	// BudgetExhausted if its URL budget was used up.
+	Trapped         bool
+	BudgetExhausted bool
+}
+
+// Detector applies the trap heuristics to URLs and enforces per-host URL
+// budgets. Budgets are tracked per crawl pass; EndPass must be called at
+// the end of each pass. Detector is safe for concurrent use.
+type Detector struct {
+	cfg Config
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// hostState tracks the URLs seen for a host during the current pass.
+type hostState struct {
+	seen      map[uint64]struct{}
+	rejected  map[Reason]int
+	exhausted bool
+
+	// trapHits holds the unique URLs rejected by the trap heuristics
+	// until the host is flagged; it is released afterwards.
+	trapHits map[uint64]struct{}
+
+	// trapped is set if the host is subject to the trapped host budget;
+	// flagged if it reached the trap threshold during the current pass.
+	// trappedPasses is the number of passes, including the current one,
+	// for which a host flagged earlier remains trapped.
+	trapped       bool
+	flagged       bool
+	trappedPasses int
+}
+
+func newHostState() *hostState {
+	return &hostState{
+		seen:     make(map[uint64]struct{}),
+		rejected: make(map[Reason]int),
+		trapHits: make(map[uint64]struct{}),
+	}
+}
+
+// New returns a new Detector using the provided config.
+func New(cfg Config) (*Detector, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("traps: config validation failed: %w", err)
+	}
+
+	return &Detector{
+		cfg:   cfg,
+		hosts: make(map[string]*hostState),
+	}, nil
+}
+
+// Check applies the trap heuristics to u without consulting or updating
+// the per-host budgets.
+func (d *Detector) Check(u *url.URL) Reason {
+	if len(u.String()) > d.cfg.MaxURLLength {
+		return ReasonURLTooLong
+	}
+
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if d.hasRepeatedSegments(segments) {
+		return ReasonRepeatedSegments
+	}
+	if hasSessionSegment(segments) {
+		return ReasonSessionID
+	}
+	if d.isCalendarTrap(u, segments) {
+		return ReasonCalendar
+	}
+	return ReasonNone
+}
+
+// Admit applies the trap heuristics to a newly discovered URL and charges
+// it against the URL budget of its host. URLs that were already admitted
+// during the current pass are not charged again.
+func (d *Detector) Admit(u *url.URL) Reason {
+	reason := d.Check(u)
+	host := strings.ToLower(u.Hostname())
+	key := hashURL(u.String())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hs, exists := d.hosts[host]
+	if !exists {
+		hs = newHostState()
+		d.hosts[host] = hs
+	}
+
+	if reason != ReasonNone {
+		if hs.flagged {
+			hs.rejected[reason]++
+			return reason
+		}
+
+		// Links to the same trap URL are usually found on many pages;
+		// only unique URLs count towards the threshold.
+		if _, hit := hs.trapHits[key]; !hit {
+			hs.trapHits[key] = struct{}{}
+			hs.rejected[reason]++
+			if len(hs.trapHits) >= d.cfg.TrapThreshold {
+				hs.trapped, hs.flagged = true, true
+				hs.trapHits = nil
+			}
+		}
+		return reason
+	}
+
+	if _, seen := hs.seen[key]; seen {
+		return ReasonNone
+	}
+
+	budget := d.cfg.MaxURLsPerHost
+	if hs.trapped {
+		budget = d.cfg.TrappedHostBudget
+	}
+	if len(hs.seen) >= budget {
+		hs.rejected[ReasonHostBudget]++
+		hs.exhausted = true
+		return ReasonHostBudget
+	}
+
+	hs.seen[key] = struct{}{}
+	return ReasonNone
+}
+
+// EndPass returns the hosts that were trapped or exhausted their URL budget
+// during the current pass, sorted by host name, and resets the per-pass
+// state. Trapped hosts remain trapped for the next Config.TrappedPasses
+// passes.
+func (d *Detector) EndPass() []HostReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var (
+		reports []HostReport
+		next    = make(map[string]*hostState)
+	)
+	for host, hs := range d.hosts {
+		remaining := hs.trappedPasses - 1
+		if hs.flagged {
+			remaining = d.cfg.TrappedPasses
+		}
+		if remaining > 0 {
+			ns := newHostState()
+			ns.trapped, ns.trappedPasses = true, remaining
+			next[host] = ns
+		}
+
+		if !hs.trapped && !hs.exhausted {
+			continue
+		}
+		reports = append(reports, HostReport{
+			Host:            host,
+			URLs:            len(hs.seen),
+			Rejected:        hs.rejected,
+			Trapped:         hs.trapped,
+			BudgetExhausted: hs.exhausted,
+		})
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Host < reports[j].Host })
+
+	d.hosts = next
+	return reports
+}
+
+func (d *Detector) hasRepeatedSegments(segments []string) bool {
+	counts := make(map[string]int, len(segments))
+	for _, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		seg = strings.ToLower(seg)
+		if counts[seg]++; counts[seg] > d.cfg.MaxSegmentRepeats {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSessionSegment returns true if a path segment is an ASP.NET cookieless
+// session segment.
+func hasSessionSegment(segments []string) bool {
+	for _, seg := range segments {
+		if aspNetSessionRegex.MatchString(seg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCalendarTrap returns true if u refers to a date that lies outside the
+// configured calendar window. Dates are recognized in "/2021/05" and
+// "/2021-05-17" style path segments and in date-related query params.
+func (d *Detector) isCalendarTrap(u *url.URL, segments []string) bool {
+	for i, seg := range segments {
+		if m := pathDateRegex.FindStringSubmatch(seg); m != nil && isMonth(m[2]) {
+			if d.outsideWindow(m[1]) {
+				return true
+			}
+			continue
+		}
+		if yearSegmentRegex.MatchString(seg) && i+1 < len(segments) &&
+			monthSegmentRegex.MatchString(segments[i+1]) && isMonth(segments[i+1]) {
+			if d.outsideWindow(seg) {
+				return true
+			}
+		}
+	}
+
+	for name, values := range u.Query() {
+		if !dateParamRegex.MatchString(name) {
+			continue
+		}
+		for _, v := range values {
+			if m := queryYearRegex.FindStringSubmatch(v); m != nil && d.outsideWindow(m[1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (d *Detector) outsideWindow(yearStr string) bool {
+	year, err := strconv.Atoi(yearStr)
+	if err != nil || year < minPlausibleYear || year > maxPlausibleYear {
+		return false
+	}
+	now := d.cfg.Clock().Year()
+	return year < now-d.cfg.CalendarYearsBack || year > now+d.cfg.CalendarYearsAhead
+}
+
+func isMonth(s string) bool {
+	month, err := strconv.Atoi(s)
+	return err == nil && month >= 1 && month <= 12
+}
+
+func hashURL(u string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(u))
+	return h.Sum64()
+}
@@ -0,0 +1,198 @@
+package traps
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func TestCheck(t *testing.T) {
+	d := newTestDetector(t, Config{MaxURLLength: 100})
+
+	specs := []struct {
+		url string
+		exp Reason
+	}{
+		{"https://example.com/", ReasonNone},
+		{"https://example.com/docs/intro?page=2", ReasonNone},
+		{"https://example.com/" + strings.Repeat("a", 100), ReasonURLTooLong},
+		{"https://example.com/a/b/a/b/a", ReasonRepeatedSegments},
+		{"https://example.com/A/b/a/c/a", ReasonRepeatedSegments},
+		{"https://example.com/a/b/a/b", ReasonNone},
+		{"https://example.com/(S(lit3py55t21z5v55vlm25s55))/page.aspx", ReasonSessionID},
+		{"https://example.com/(X(1)S(abc))/page.aspx", ReasonSessionID},
+		{"https://example.com/(draft)/page", ReasonNone},
+		{"https://example.com/events/2024/06", ReasonNone},
+		{"https://example.com/events/2003/06", ReasonCalendar},
+		{"https://example.com/events/2026/01", ReasonCalendar},
+		{"https://example.com/events/1999-12-31", ReasonCalendar},
+		{"https://example.com/events/2024-13", ReasonNone},
+		{"https://example.com/products/1234/10", ReasonNone},
+		{"https://example.com/archive/1850/05", ReasonCalendar},
+		{"https://example.com/cal?date=1990-01-01", ReasonCalendar},
+		{"https://example.com/cal?Month=2030-01", ReasonCalendar},
+		{"https://example.com/cal?date=2024-05-01", ReasonNone},
+		{"https://example.com/item?id=1990", ReasonNone},
+	}
+
+	for _, spec := range specs {
+		u, err := url.Parse(spec.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Check(u); got != spec.exp {
+			t.Errorf("Check(%s): expected %s; got %s", spec.url, spec.exp, got)
+		}
+	}
+}
+
+func TestAdmitEnforcesHostBudget(t *testing.T) {
+	d := newTestDetector(t, Config{MaxURLsPerHost: 3})
+
+	for i := 0; i < 3; i++ {
+		if got := admit(d, fmt.Sprintf("https://example.com/%d", i)); got != ReasonNone {
+			t.Fatalf("URL %d: expected to be admitted; got %s", i, got)
+		}
+	}
+
+	// Admitted URLs are not charged again.
+	if got := admit(d, "https://example.com/0"); got != ReasonNone {
+		t.Errorf("expected a known URL to be admitted; got %s", got)
+	}
+	if got := admit(d, "https://example.com/3"); got != ReasonHostBudget {
+		t.Errorf("expected the budget to be exhausted; got %s", got)
+	}
+	if got := admit(d, "https://other.example.com/"); got != ReasonNone {
+		t.Errorf("expected budgets to be tracked per host; got %s", got)
+	}
+
+	reports := d.EndPass()
+	if len(reports) != 1 {
+		t.Fatalf("expected a single report; got %+v", reports)
+	}
+	if r := reports[0]; r.Host != "example.com" || r.URLs != 3 || !r.BudgetExhausted || r.Trapped || r.Rejected[ReasonHostBudget] != 1 {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// Budgets are reset at the end of each pass.
+	if got := admit(d, "https://example.com/3"); got != ReasonNone {
+		t.Errorf("expected the budget to be reset; got %s", got)
+	}
+}
+
+func TestAdmitFlagsTrappedHosts(t *testing.T) {
+	d := newTestDetector(t, Config{MaxURLsPerHost: 10, TrapThreshold: 3, TrappedHostBudget: 2, TrappedPasses: 2})
+	trapURL := func(i int) string { return fmt.Sprintf("https://example.com/cal/%d/01", 1900+i) }
+
+	// Repeated links to the same trap URL count once.
+	for i := 0; i < 5; i++ {
+		if got := admit(d, trapURL(0)); got != ReasonCalendar {
+			t.Fatalf("expected a calendar trap; got %s", got)
+		}
+	}
+	admit(d, trapURL(1))
+	for i := 0; i < 5; i++ {
+		if got := admit(d, fmt.Sprintf("https://example.com/page/%d", i)); got != ReasonNone {
+			t.Fatalf("expected URL %d to be admitted before the host is flagged; got %s", i, got)
+		}
+	}
+
+	// Reaching the threshold flags the host and applies the reduced
+	// budget; rejections after that are all counted.
+	admit(d, trapURL(2))
+	admit(d, trapURL(2))
+	if got := admit(d, "https://example.com/page/5"); got != ReasonHostBudget {
+		t.Errorf("expected the trapped host budget to apply; got %s", got)
+	}
+
+	reports := d.EndPass()
+	if len(reports) != 1 {
+		t.Fatalf("expected a single report; got %+v", reports)
+	}
+	if r := reports[0]; !r.Trapped || !r.BudgetExhausted || r.Rejected[ReasonCalendar] != 4 || r.URLs != 5 {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// The host stays trapped for the configured number of passes.
+	for pass := 1; pass <= 2; pass++ {
+		admit(d, "https://example.com/a")
+		admit(d, "https://example.com/b")
+		if got := admit(d, "https://example.com/c"); got != ReasonHostBudget {
+			t.Errorf("pass %d: expected the trapped host budget to apply; got %s", pass, got)
+		}
+		if reports = d.EndPass(); len(reports) != 1 || !reports[0].Trapped {
+			t.Errorf("pass %d: expected the host to be reported as trapped; got %+v", pass, reports)
+		}
+	}
+
+	admit(d, "https://example.com/a")
+	admit(d, "https://example.com/b")
+	if got := admit(d, "https://example.com/c"); got != ReasonNone {
+		t.Errorf("expected the regular budget to apply again; got %s", got)
+	}
+	if reports = d.EndPass(); len(reports) != 0 {
+		t.Errorf("expected no reports; got %+v", reports)
+	}
+}
+
+func TestAdmitReflagsTrappedHosts(t *testing.T) {
+	d := newTestDetector(t, Config{TrapThreshold: 1, TrappedPasses: 2})
+
+	admit(d, "https://example.com/a/a/a")
+	d.EndPass()
+	d.EndPass()
+
+	// Flagging the host again restarts the count.
+	admit(d, "https://example.com/b/b/b")
+	d.EndPass()
+	if reports := d.EndPass(); len(reports) != 1 || !reports[0].Trapped {
+		t.Errorf("expected the host to still be trapped; got %+v", reports)
+	}
+	if reports := d.EndPass(); len(reports) != 1 || !reports[0].Trapped {
+		t.Errorf("expected the host to still be trapped; got %+v", reports)
+	}
+	if reports := d.EndPass(); len(reports) != 0 {
+		t.Errorf("expected the host to be released; got %+v", reports)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	if _, err := New(Config{MaxURLsPerHost: 10, TrappedHostBudget: 20}); err == nil {
+		t.Error("expected an error when the trapped host budget exceeds the max URLs per host")
+	}
+
+	d := newTestDetector(t, Config{MaxURLsPerHost: 100})
+	if d.cfg.TrappedHostBudget != 100 {
+		t.Errorf("expected the default trapped host budget to be capped at 100; got %d", d.cfg.TrappedHostBudget)
+	}
+}
+
+func TestReasonString(t *testing.T) {
+	if got := ReasonHostBudget.String(); got != "host-budget" {
+		t.Errorf("unexpected name %q", got)
+	}
+	if got := Reason(42).String(); got != "unknown" {
+		t.Errorf("unexpected name %q", got)
+	}
+}
+
+func newTestDetector(t *testing.T, cfg Config) *Detector {
+	cfg.Clock = func() time.Time { return testNow }
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func admit(d *Detector, rawURL string) Reason {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return d.Admit(u)
+}
@@ -0,0 +1,353 @@
+// Package urlnorm converts URLs into a canonical form so that equivalent
+// URLs map to the same link graph entry.
+package urlnorm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// TrailingSlashPolicy controls how trailing slashes in non-root paths are
+// handled.
+type TrailingSlashPolicy uint8
+
+const (
+	// TrailingSlashRemove strips trailing slashes from non-root paths.
+	TrailingSlashRemove TrailingSlashPolicy = iota
+
+	// TrailingSlashKeep leaves trailing slashes untouched.
+	TrailingSlashKeep
+
+	// TrailingSlashAdd appends a trailing slash to paths whose last
+	// segment does not look like a file name (i.e. has no extension).
+	TrailingSlashAdd
+)
+
+// DefaultStripParams is the list of tracking and session query parameters
+// that are removed when Config.StripParams is nil. A trailing "*" matches
+// any parameter with the given prefix and a leading "*" any parameter with
+// the given suffix. Session ID params are stripped so that every session
+// maps to the same URL.
+var DefaultStripParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"gclsrc",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_ga",
+	"_hsenc",
+	"_hsmi",
+	"*sessid",
+	"*sessionid",
+	"*session_id",
+	"aspsessionid*",
+	"sid",
+	"cfid",
+	"cftoken",
+	"zenid",
+	"oscsid",
+}
+
+// Config encapsulates the settings for a Normalizer.
+type Config struct {
+	// StripParams lists the (case-insensitive) names of query parameters
+	// to drop. A trailing "*" matches by prefix and a leading "*" by
+	// suffix. If nil, DefaultStripParams is used; use an empty non-nil
+	// slice to keep all params.
+	StripParams []string
+
+	// TrailingSlash selects how trailing slashes are unified.
+	TrailingSlash TrailingSlashPolicy
+
+	// KeepQueryOrder disables the sorting of query params.
+	KeepQueryOrder bool
+}
+
+// Normalizer applies a set of normalization rules to URLs. It is safe for
+// concurrent use.
+type Normalizer struct {
+	exact    map[string]struct{}
+	prefixes []string
+	suffixes []string
+	cfg      Config
+}
+
+// New returns a new Normalizer using the provided config.
+func New(cfg Config) *Normalizer {
+	stripParams := cfg.StripParams
+	if stripParams == nil {
+		stripParams = DefaultStripParams
+	}
+
+	n := &Normalizer{
+		exact: make(map[string]struct{}),
+		cfg:   cfg,
+	}
+	for _, p := range stripParams {
+		p = strings.ToLower(p)
+		switch {
+		case strings.HasSuffix(p, "*"):
+			n.prefixes = append(n.prefixes, strings.TrimSuffix(p, "*"))
+		case strings.HasPrefix(p, "*"):
+			n.suffixes = append(n.suffixes, strings.TrimPrefix(p, "*"))
+		default:
+			n.exact[p] = struct{}{}
+		}
+	}
+
+	return n
+}
+
+// NormalizeString parses and normalizes a URL string.
+func (n *Normalizer) NormalizeString(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("normalize URL: %w", err)
+	}
+
+	u, err = n.Normalize(u)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Normalize returns a normalized copy of u. The following rules are applied:
+//   - the scheme and host are lower-cased and internationalized host names
+//     are converted to punycode,
+//   - default ports for http and https are removed,
+//   - percent-encodings are upper-cased and unreserved characters decoded,
+//   - dot segments are resolved and ";jsessionid=" style path params dropped,
+//   - tracking params are removed and the remaining params sorted,
+//   - trailing slashes are unified according to the configured policy,
+//   - the fragment is removed.
+func (n *Normalizer) Normalize(u *url.URL) (*url.URL, error) {
+	if !u.IsAbs() || u.Opaque != "" {
+		return nil, fmt.Errorf("normalize URL: %q is not an absolute hierarchical URL", u.String())
+	}
+
+	host, err := normalizeHost(strings.ToLower(u.Scheme), u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("normalize URL: %w", err)
+	}
+
+	rawPath := n.normalizePath(u.EscapedPath())
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("normalize URL: %w", err)
+	}
+
+	out := &url.URL{
+		Scheme:   strings.ToLower(u.Scheme),
+		User:     u.User,
+		Host:     host,
+		Path:     path,
+		RawQuery: n.normalizeQuery(u.RawQuery),
+	}
+	// Preserve reserved characters that were escaped in the original.
+	if rawPath != out.EscapedPath() {
+		out.RawPath = rawPath
+	}
+
+	return out, nil
+}
+
+func normalizeHost(scheme, hostPort string) (string, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// No port specified.
+		host, port = hostPort, ""
+	}
+
+	// IPv6 literals are kept as-is apart from lower-casing.
+	if strings.HasPrefix(host, "[") || net.ParseIP(host) != nil {
+		host = strings.ToLower(host)
+	} else {
+		host = strings.TrimSuffix(strings.ToLower(host), ".")
+		if host, err = idna.Lookup.ToASCII(host); err != nil {
+			return "", err
+		}
+	}
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	if port == "" {
+		if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			return "[" + host + "]", nil
+		}
+		return host, nil
+	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), port), nil
+}
+
+func (n *Normalizer) normalizePath(escaped string) string {
+	if escaped == "" {
+		return "/"
+	}
+
+	// Drop path params such as ";jsessionid=1234" from each segment.
+	segments := strings.Split(escaped, "/")
+	for i, seg := range segments {
+		if idx := strings.IndexByte(seg, ';'); idx != -1 {
+			if n.stripParam(strings.SplitN(seg[idx+1:], "=", 2)[0]) {
+				segments[i] = seg[:idx]
+			}
+		}
+	}
+
+	p := removeDotSegments(normalizePercentEncoding(strings.Join(segments, "/")))
+	if p == "" || p[0] != '/' {
+		p = "/" + p
+	}
+
+	if p == "/" {
+		return p
+	}
+
+	switch n.cfg.TrailingSlash {
+	case TrailingSlashRemove:
+		p = strings.TrimRight(p, "/")
+		if p == "" {
+			p = "/"
+		}
+	case TrailingSlashAdd:
+		last := p[strings.LastIndexByte(p, '/')+1:]
+		if last != "" && !strings.Contains(last, ".") {
+			p += "/"
+		}
+	}
+
+	return p
+}
+
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	// Only "&" separates params; a ";" may be part of a value.
+	pairs := strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' })
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		key := pair
+		if idx := strings.IndexByte(pair, '='); idx != -1 {
+			key = pair[:idx]
+		}
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if n.stripParam(key) {
+			continue
+		}
+		kept = append(kept, normalizePercentEncoding(pair))
+	}
+
+	if !n.cfg.KeepQueryOrder {
+		sort.Strings(kept)
+	}
+	return strings.Join(kept, "&")
+}
+
+func (n *Normalizer) stripParam(name string) bool {
+	name = strings.ToLower(name)
+	if _, found := n.exact[name]; found {
+		return true
+	}
+	for _, prefix := range n.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range n.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePercentEncoding upper-cases the hex digits of percent-encoded
+// octets and decodes octets that correspond to unreserved characters.
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteByte('%')
+			sb.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return sb.String()
+}
+
+// removeDotSegments implements the algorithm from RFC 3986, section 5.2.4.
+func removeDotSegments(p string) string {
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		switch seg {
+		case ".":
+			// A trailing "." leaves a trailing slash behind.
+			if i == len(segments)-1 {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if i == len(segments)-1 {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
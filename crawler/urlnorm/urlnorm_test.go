@@ -0,0 +1,80 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalizeString(t *testing.T) {
+	specs := []struct {
+		descr string
+		cfg   Config
+		in    string
+		exp   string
+	}{
+		{descr: "scheme and host case", in: "HTTP://Example.COM/Path", exp: "http://example.com/Path"},
+		{descr: "empty path", in: "http://example.com", exp: "http://example.com/"},
+		{descr: "default http port", in: "http://example.com:80/", exp: "http://example.com/"},
+		{descr: "default https port", in: "https://example.com:443/", exp: "https://example.com/"},
+		{descr: "non-default port", in: "https://example.com:8443/", exp: "https://example.com:8443/"},
+		{descr: "trailing dot in host", in: "http://example.com./a", exp: "http://example.com/a"},
+		{descr: "idn host", in: "http://bücher.example/", exp: "http://xn--bcher-kva.example/"},
+		{descr: "ipv6 host", in: "http://[2001:DB8::1]:80/", exp: "http://[2001:db8::1]/"},
+		{descr: "ipv6 host with port", in: "http://[2001:db8::1]:8080/", exp: "http://[2001:db8::1]:8080/"},
+		{descr: "fragment", in: "http://example.com/a#section", exp: "http://example.com/a"},
+		{descr: "dot segments", in: "http://example.com/a/./b/../c", exp: "http://example.com/a/c"},
+		{descr: "dot segments above root", in: "http://example.com/../../a", exp: "http://example.com/a"},
+		{descr: "unreserved escapes", in: "http://example.com/%7Euser/%41", exp: "http://example.com/~user/A"},
+		{descr: "reserved escapes", in: "http://example.com/a%2fb", exp: "http://example.com/a%2Fb"},
+		{descr: "session path param", in: "http://example.com/a;jsessionid=123/b", exp: "http://example.com/a/b"},
+		{descr: "other path param", in: "http://example.com/a;v=1", exp: "http://example.com/a;v=1"},
+		{descr: "tracking params", in: "http://example.com/?utm_source=x&b=2&fbclid=y&a=1", exp: "http://example.com/?a=1&b=2"},
+		{descr: "session params", in: "http://example.com/?PHPSESSID=1&sid=2&ASPSESSIONIDQA=3&q=go", exp: "http://example.com/?q=go"},
+		{descr: "only tracking params", in: "http://example.com/?utm_medium=email", exp: "http://example.com/"},
+		{descr: "escaped param name", in: "http://example.com/?utm%5Fsource=x&q=1", exp: "http://example.com/?q=1"},
+		{descr: "semicolon in value", in: "http://example.com/?a=1;2", exp: "http://example.com/?a=1;2"},
+		{descr: "keep query order", cfg: Config{KeepQueryOrder: true}, in: "http://example.com/?b=2&a=1", exp: "http://example.com/?b=2&a=1"},
+		{descr: "custom strip params", cfg: Config{StripParams: []string{"ref"}}, in: "http://example.com/?ref=x&utm_source=y", exp: "http://example.com/?utm_source=y"},
+		{descr: "empty strip params", cfg: Config{StripParams: []string{}}, in: "http://example.com/?utm_source=y", exp: "http://example.com/?utm_source=y"},
+		{descr: "remove trailing slash", in: "http://example.com/a/b/", exp: "http://example.com/a/b"},
+		{descr: "keep root slash", in: "http://example.com/", exp: "http://example.com/"},
+		{descr: "keep trailing slash", cfg: Config{TrailingSlash: TrailingSlashKeep}, in: "http://example.com/a/", exp: "http://example.com/a/"},
+		{descr: "add trailing slash", cfg: Config{TrailingSlash: TrailingSlashAdd}, in: "http://example.com/a", exp: "http://example.com/a/"},
+		{descr: "add trailing slash to file", cfg: Config{TrailingSlash: TrailingSlashAdd}, in: "http://example.com/a.html", exp: "http://example.com/a.html"},
+	}
+
+	for _, spec := range specs {
+		got, err := New(spec.cfg).NormalizeString(spec.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", spec.descr, err)
+		} else if got != spec.exp {
+			t.Errorf("%s: expected %q; got %q", spec.descr, spec.exp, got)
+		}
+	}
+}
+
+func TestNormalizeStringErrors(t *testing.T) {
+	n := New(Config{})
+	for _, in := range []string{"/relative/path", "mailto:user@example.com", "http://", "http://exa mple.com/"} {
+		if got, err := n.NormalizeString(in); err == nil {
+			t.Errorf("%q: expected an error; got %q", in, got)
+		}
+	}
+}
+
+func TestNormalizeIsIdempotent(t *testing.T) {
+	n := New(Config{})
+	for _, in := range []string{
+		"HTTP://Example.COM:80/a/./b/../%7Ec/?utm_source=x&b=2&a=1#frag",
+		"http://example.com/a%2Fb;jsessionid=1?q=%e2%82%ac",
+	} {
+		once, err := n.NormalizeString(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		twice, err := n.NormalizeString(once)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if once != twice {
+			t.Errorf("%q: normalizing twice changed %q into %q", in, once, twice)
+		}
+	}
+}
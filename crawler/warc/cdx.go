@@ -0,0 +1,98 @@
+package warc
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cdxHeader describes the fields of the generated CDX files: massaged URL,
+// date, original URL, MIME type, status code, payload digest, redirect,
+// meta tags, compressed record length, offset and WARC file name.
+const cdxHeader = " CDX N b a m s k r M S V g\n"
+
+// cdxName returns the name of the CDX file for a WARC file.
+func cdxName(warcName string) string {
+	return strings.TrimSuffix(warcName, ".warc.gz") + ".cdx"
+}
+
+// cdxLine is a CDX index entry for a response or revisit record.
+type cdxLine struct {
+	url         string
+	timestamp   time.Time
+	mime        string
+	status      int
+	digest      string
+	redirect    string
+	length      int64
+	offset      int64
+	filename    string
+	notModified bool
+}
+
+func (l cdxLine) String() string {
+	digest := l.digest
+	if l.notModified {
+		digest = "-"
+	}
+	redirect := "-"
+	if l.redirect != "" {
+		redirect = cdxEscape(l.redirect)
+	}
+	return fmt.Sprintf("%s %s %s %s %d %s %s - %d %d %s\n",
+		SURT(l.url),
+		l.timestamp.UTC().Format("20060102150405"),
+		cdxEscape(l.url),
+		cdxEscape(l.mime),
+		l.status,
+		digest,
+		redirect,
+		l.length,
+		l.offset,
+		l.filename,
+	)
+}
+
+// SURT returns the Sort-friendly URI Reordering Transform of rawURL as used
+// for the massaged URL field of CDX files, e.g. "com,example)/path?a=1" for
+// "http://www.example.com/path?a=1". Invalid URLs are returned as-is.
+func SURT(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return cdxEscape(rawURL)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	labels := strings.Split(host, ".")
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(labels, ","))
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		sb.WriteString(":" + port)
+	}
+	sb.WriteString(")")
+
+	path := strings.ToLower(u.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	sb.WriteString(path)
+
+	if u.RawQuery != "" {
+		params := strings.Split(strings.ToLower(u.RawQuery), "&")
+		sort.Strings(params)
+		sb.WriteString("?" + strings.Join(params, "&"))
+	}
+
+	return cdxEscape(sb.String())
+}
+
+// cdxEscape escapes the spaces in CDX fields.
+func cdxEscape(s string) string {
+	return strings.ReplaceAll(s, " ", "%20")
+}
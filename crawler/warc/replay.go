@@ -0,0 +1,267 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultMaxReplayRedirects = 10
+
+// ErrNotArchived is returned by Replayer when no record exists for the
+// requested URL.
+var ErrNotArchived = errors.New("url not archived")
+
+// ReplayConfig encapsulates the settings for a Replayer.
+type ReplayConfig struct {
+	// The directory containing the WARC and CDX files. Required.
+	Dir string
+
+	// If specified, only records captured at or before this time are
+	// served, which allows replaying the state of an earlier crawl.
+	At time.Time
+
+	// The max number of archived redirects to follow. Defaults to 10.
+	MaxRedirects int
+}
+
+// Replayer serves responses from the WARC files written by Writer. It
+// implements the crawler.URLGetter interface so that a crawl can be rerun
+// offline over the exact bytes that were originally retrieved. Like
+// http.Client, it follows redirects and links the intermediate responses
+// via the Response field of the returned response's Request.
+//
+// Replayer is safe for concurrent use.
+type Replayer struct {
+	dir          string
+	maxRedirects int
+
+	// index maps original URLs to the record to serve.
+	index map[string]cdxEntry
+}
+
+// cdxEntry locates the record for a URL.
+type cdxEntry struct {
+	timestamp time.Time
+	revisit   bool
+	filename  string
+	offset    int64
+	length    int64
+}
+
+// NewReplayer returns a Replayer for the WARC files in cfg.Dir. The CDX
+// files in the directory are loaded into memory; for each URL the latest
+// capture is served, with full responses preferred over revisit records.
+func NewReplayer(cfg ReplayConfig) (*Replayer, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("warc: archive directory not specified")
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxReplayRedirects
+	}
+
+	cdxFiles, err := filepath.Glob(filepath.Join(cfg.Dir, "*.cdx"))
+	if err != nil {
+		return nil, fmt.Errorf("warc: %w", err)
+	}
+
+	r := &Replayer{
+		dir:          cfg.Dir,
+		maxRedirects: cfg.MaxRedirects,
+		index:        make(map[string]cdxEntry),
+	}
+	for _, cdxFile := range cdxFiles {
+		if err = r.loadCDX(cdxFile, cfg.At); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Do serves the archived response for req, following archived redirects.
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	for hops := 0; ; hops++ {
+		res, err := r.replay(req)
+		if err != nil {
+			return nil, err
+		}
+
+		location := res.Header.Get("Location")
+		if res.StatusCode < 300 || res.StatusCode > 399 || res.StatusCode == http.StatusNotModified || location == "" {
+			return res, nil
+		}
+		if hops == r.maxRedirects {
+			_ = res.Body.Close()
+			return nil, fmt.Errorf("warc replay: stopped after %d redirects", r.maxRedirects)
+		}
+
+		target, err := req.URL.Parse(location)
+		if err != nil {
+			_ = res.Body.Close()
+			return nil, fmt.Errorf("warc replay: invalid redirect location %q: %w", location, err)
+		}
+		_ = res.Body.Close()
+
+		next := req.Clone(req.Context())
+		next.URL = target
+		next.Host = ""
+		next.Response = res
+		req = next
+	}
+}
+
+// replay returns the archived response for req without following redirects.
+func (r *Replayer) replay(req *http.Request) (*http.Response, error) {
+	entry, found := r.index[req.URL.String()]
+	if !found {
+		return nil, fmt.Errorf("warc replay: %s: %w", req.URL, ErrNotArchived)
+	}
+
+	block, err := r.readBlock(entry)
+	if err != nil {
+		return nil, fmt.Errorf("warc replay: %s: %w", req.URL, err)
+	}
+
+	br := bufio.NewReader(bytes.NewReader(block))
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("warc replay: %s: %w", req.URL, err)
+	}
+
+	// Serve the body from memory so that the archive file can be closed.
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil && !entry.revisit {
+		return nil, fmt.Errorf("warc replay: %s: %w", req.URL, err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+// readBlock reads the block of the record described by entry.
+func (r *Replayer) readBlock(entry cdxEntry) ([]byte, error) {
+	f, err := os.Open(filepath.Join(r.dir, entry.filename))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err = f.Seek(entry.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(io.LimitReader(f, entry.length))
+	if err != nil {
+		return nil, err
+	}
+	gz.Multistream(false)
+
+	tr := textproto.NewReader(bufio.NewReader(gz))
+	version, err := tr.ReadLine()
+	if err != nil {
+		return nil, err
+	} else if !strings.HasPrefix(version, "WARC/") {
+		return nil, fmt.Errorf("malformed record at offset %d of %s", entry.offset, entry.filename)
+	}
+	headers, err := tr.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.ParseInt(headers.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed record length at offset %d of %s", entry.offset, entry.filename)
+	}
+
+	block := make([]byte, length)
+	if _, err = io.ReadFull(tr.R, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// loadCDX adds the entries of a CDX file to the index. Entries captured after
+// at (if not zero) are ignored.
+func (r *Replayer) loadCDX(path string, at time.Time) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " CDX") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		key, entry, err := parseCDXLine(line)
+		if err != nil {
+			return fmt.Errorf("warc: %s:%d: %w", path, lineNum, err)
+		}
+		if !at.IsZero() && entry.timestamp.After(at) {
+			continue
+		}
+
+		// Prefer full responses over revisits and newer captures over
+		// older ones.
+		if cur, exists := r.index[key]; exists {
+			if entry.revisit && !cur.revisit {
+				continue
+			}
+			if entry.revisit == cur.revisit && entry.timestamp.Before(cur.timestamp) {
+				continue
+			}
+		}
+		r.index[key] = entry
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("warc: %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseCDXLine parses a line in the format described by cdxHeader.
+func parseCDXLine(line string) (string, cdxEntry, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 11 {
+		return "", cdxEntry{}, fmt.Errorf("expected 11 fields; got %d", len(fields))
+	}
+
+	timestamp, err := time.Parse("20060102150405", fields[1])
+	if err != nil {
+		return "", cdxEntry{}, err
+	}
+	length, err := strconv.ParseInt(fields[8], 10, 64)
+	if err != nil {
+		return "", cdxEntry{}, err
+	}
+	offset, err := strconv.ParseInt(fields[9], 10, 64)
+	if err != nil {
+		return "", cdxEntry{}, err
+	}
+
+	// Records are keyed by their original URL rather than the massaged
+	// one, which folds case and ignores the scheme. Recorded URLs never
+	// contain spaces so cdxEscape leaves them unchanged.
+	return fields[2], cdxEntry{
+		timestamp: timestamp,
+		revisit:   fields[3] == "warc/revisit",
+		filename:  fields[10],
+		offset:    offset,
+		length:    length,
+	}, nil
+}
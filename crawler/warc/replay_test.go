@@ -0,0 +1,174 @@
+package warc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayer(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir})
+	writeExchanges(t, w,
+		&Exchange{
+			TargetURI:      "https://example.com/final",
+			StatusCode:     http.StatusOK,
+			ResponseHeader: http.Header{"Content-Type": {"text/html"}},
+			Body:           []byte("final"),
+			Redirects: []Redirect{
+				{URL: "http://example.com/start", StatusCode: http.StatusMovedPermanently},
+				{URL: "https://example.com/start", StatusCode: http.StatusFound},
+			},
+			FetchedAt: testFetchedAt,
+		},
+		&Exchange{
+			TargetURI:      "https://example.com/other",
+			StatusCode:     http.StatusNotFound,
+			ResponseHeader: http.Header{"Content-Type": {"text/plain"}},
+			Body:           []byte("missing"),
+			FetchedAt:      testFetchedAt,
+		},
+	)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newTestReplayer(t, ReplayConfig{Dir: dir})
+
+	res := mustReplay(t, r, "http://example.com/start")
+	if res.StatusCode != http.StatusOK || readBody(t, res) != "final" {
+		t.Errorf("unexpected response %d", res.StatusCode)
+	}
+	if res.Request.URL.String() != "https://example.com/final" {
+		t.Errorf("expected the final request URL; got %s", res.Request.URL)
+	}
+	var chain []int
+	for prev := res.Request.Response; prev != nil; prev = prev.Request.Response {
+		chain = append(chain, prev.StatusCode)
+	}
+	if len(chain) != 2 || chain[0] != http.StatusFound || chain[1] != http.StatusMovedPermanently {
+		t.Errorf("unexpected redirect chain %v", chain)
+	}
+
+	if res = mustReplay(t, r, "https://example.com/other"); res.StatusCode != http.StatusNotFound || readBody(t, res) != "missing" {
+		t.Errorf("expected the archived 404 response; got %d", res.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/unknown", nil)
+	if _, err := r.Do(req); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected ErrNotArchived; got %v", err)
+	}
+}
+
+func TestReplayerSelectsCaptures(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir, MaxFileSize: 1})
+	writeExchanges(t, w,
+		&Exchange{TargetURI: "https://example.com/", StatusCode: http.StatusOK, Body: []byte("v1"), FetchedAt: testFetchedAt},
+		&Exchange{TargetURI: "https://example.com/", StatusCode: http.StatusOK, Body: []byte("v2"), FetchedAt: testFetchedAt.Add(time.Hour)},
+		&Exchange{TargetURI: "https://example.com/", StatusCode: http.StatusNotModified, NotModified: true, FetchedAt: testFetchedAt.Add(2 * time.Hour)},
+		&Exchange{TargetURI: "https://example.com/revisited", StatusCode: http.StatusNotModified, NotModified: true, FetchedAt: testFetchedAt},
+	)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The latest full response wins over older ones and over revisits.
+	if got := readBody(t, mustReplay(t, newTestReplayer(t, ReplayConfig{Dir: dir}), "https://example.com/")); got != "v2" {
+		t.Errorf("expected the latest capture; got %q", got)
+	}
+
+	r := newTestReplayer(t, ReplayConfig{Dir: dir, At: testFetchedAt.Add(30 * time.Minute)})
+	if got := readBody(t, mustReplay(t, r, "https://example.com/")); got != "v1" {
+		t.Errorf("expected the capture before the replay time; got %q", got)
+	}
+
+	// Revisits are served if no full response exists.
+	if res := mustReplay(t, r, "https://example.com/revisited"); res.StatusCode != http.StatusNotModified {
+		t.Errorf("expected the revisit to be served as 304; got %d", res.StatusCode)
+	}
+}
+
+func TestReplayerStopsRedirectLoops(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir})
+	writeExchanges(t, w, &Exchange{
+		TargetURI:  "https://example.com/a",
+		StatusCode: http.StatusOK,
+		Redirects: []Redirect{
+			{URL: "https://example.com/a", StatusCode: http.StatusFound},
+		},
+		FetchedAt: testFetchedAt,
+	})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both records for /a share a timestamp so the redirect, which was
+	// indexed last, shadows the final response and yields a loop.
+	r := newTestReplayer(t, ReplayConfig{Dir: dir, MaxRedirects: 3})
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	if _, err := r.Do(req); err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Errorf("expected the redirect limit error; got %v", err)
+	}
+}
+
+func TestReplayerRejectsMalformedCDX(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.cdx"), []byte(cdxHeader+"com,example)/ 20240301123000 https://example.com/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewReplayer(ReplayConfig{Dir: dir}); err == nil {
+		t.Error("expected an error for a malformed CDX file")
+	}
+	if _, err := NewReplayer(ReplayConfig{}); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func writeExchanges(t *testing.T, w *Writer, exchanges ...*Exchange) {
+	t.Helper()
+	for _, ex := range exchanges {
+		if err := w.WriteExchange(ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestReplayer(t *testing.T, cfg ReplayConfig) *Replayer {
+	t.Helper()
+	r, err := NewReplayer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func mustReplay(t *testing.T, r *Replayer, rawURL string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := r.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func readBody(t *testing.T, res *http.Response) string {
+	t.Helper()
+	defer func() { _ = res.Body.Close() }()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
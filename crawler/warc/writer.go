@@ -0,0 +1,553 @@
+// Package warc writes fetched HTTP exchanges into gzip-compressed WARC 1.1
+// files along with CDX index files that allow the records to be looked up
+// by URL.
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPrefix      = "GOSEARCH"
+	defaultMaxFileSize = 1 << 30
+	defaultSoftware    = "go-search crawler"
+
+	warcVersion = "WARC/1.1"
+
+	// openSuffix is appended to the names of WARC files that are still
+	// being written to.
+	openSuffix = ".open"
+
+	profileServerNotModified = "http://netpreserve.org/warc/1.1/revisits/server-not-modified"
+)
+
+// Exchange describes a fetched HTTP request/response pair.
+type Exchange struct {
+	// The URL of the request and the method used to retrieve it.
+	TargetURI string
+	Method    string
+
+	// The headers sent with the request.
+	RequestHeader http.Header
+
+	// The response status line and headers. Proto defaults to HTTP/1.1.
+	Proto          string
+	StatusCode     int
+	ResponseHeader http.Header
+
+	// The decoded response body. Any Content-Encoding and
+	// Transfer-Encoding headers are dropped from the archived response
+	// and Content-Length is set to the length of the body.
+	Body []byte
+
+	// Truncated indicates that Body is incomplete, e.g. because it was
+	// cut short due to a size limit.
+	Truncated bool
+
+	// NotModified indicates that the server responded to a conditional
+	// request with 304 Not Modified. Such exchanges are archived as
+	// revisit records.
+	NotModified bool
+
+	// The address of the server that sent the response.
+	RemoteAddr string
+
+	// The redirects that were followed to reach TargetURI, in the order
+	// they were encountered.
+	Redirects []Redirect
+
+	// Timing information for the exchange.
+	FetchedAt       time.Time
+	TimeToFirstByte time.Duration
+	FetchDuration   time.Duration
+}
+
+// Redirect describes a redirect response that was followed while fetching an
+// exchange. Only the status code and the target of redirects are known so
+// they are archived as response records with an empty body and a single
+// Location header.
+type Redirect struct {
+	URL        string
+	StatusCode int
+}
+
+// Config encapsulates the settings for a Writer.
+type Config struct {
+	// The directory where WARC and CDX files are created. Required.
+	Dir string
+
+	// The prefix for the names of the generated files. Defaults to
+	// "GOSEARCH".
+	Prefix string
+
+	// The size after which a new WARC file is started. Defaults to 1 GiB.
+	MaxFileSize int64
+
+	// The software name recorded in the warcinfo record of each file.
+	Software string
+
+	// An optional description of the crawl operator, recorded in the
+	// warcinfo record of each file.
+	Operator string
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+func (cfg *Config) validate() error {
+	if cfg.Dir == "" {
+		return fmt.Errorf("warc: output directory not specified")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultPrefix
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = defaultMaxFileSize
+	}
+	if cfg.Software == "" {
+		cfg.Software = defaultSoftware
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return nil
+}
+
+// Writer appends exchanges to rotating WARC files. Each record is stored
+// as a separate gzip member so that records can be read individually
+// using the offsets recorded in the CDX files. Writer is safe for
+// concurrent use.
+type Writer struct {
+	cfg      Config
+	hostname string
+
+	mu     sync.Mutex
+	serial int
+	file   *os.File
+	name   string
+	offset int64
+	cdx    *os.File
+	cdxBuf *bufio.Writer
+}
+
+// NewWriter returns a new Writer using the provided config. WARC files are
+// created lazily when the first exchange is written.
+func NewWriter(cfg Config) (*Writer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("warc: %w", err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	return &Writer{cfg: cfg, hostname: hostname}, nil
+}
+
+// WriteExchange archives ex as a response (or revisit) record followed by
+// the matching request and metadata records.
+func (w *Writer) WriteExchange(ex *Exchange) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil && w.offset >= w.cfg.MaxFileSize {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.file == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	fetchedAt := ex.FetchedAt
+	if fetchedAt.IsZero() {
+		fetchedAt = w.cfg.Clock()
+	}
+	warcDate := fetchedAt.UTC().Format(time.RFC3339)
+
+	var ip string
+	if host, _, err := net.SplitHostPort(ex.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	// Response or revisit record.
+	var (
+		respID      = newRecordID()
+		respBlock   = responseBlock(ex)
+		payloadHash = digest(ex.Body)
+		respType    = "response"
+	)
+	if ex.NotModified {
+		respType = "revisit"
+	}
+	respHeaders := []field{
+		{"WARC-Type", respType},
+		{"WARC-Record-ID", respID},
+		{"WARC-Date", warcDate},
+		{"WARC-Target-URI", ex.TargetURI},
+	}
+	if ex.NotModified {
+		respHeaders = append(respHeaders, field{"WARC-Profile", profileServerNotModified})
+	} else {
+		respHeaders = append(respHeaders, field{"WARC-Payload-Digest", payloadHash})
+	}
+	if ip != "" {
+		respHeaders = append(respHeaders, field{"WARC-IP-Address", ip})
+	}
+	if ex.Truncated {
+		respHeaders = append(respHeaders, field{"WARC-Truncated", "length"})
+	}
+	respHeaders = append(respHeaders,
+		field{"WARC-Block-Digest", digest(respBlock)},
+		field{"Content-Type", "application/http;msgtype=response"},
+	)
+
+	respOffset := w.offset
+	respLen, err := w.writeRecord(respHeaders, respBlock)
+	if err != nil {
+		return err
+	}
+
+	// Request record.
+	reqBlock := requestBlock(ex)
+	_, err = w.writeRecord([]field{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", warcDate},
+		{"WARC-Target-URI", ex.TargetURI},
+		{"WARC-Concurrent-To", respID},
+		{"WARC-Block-Digest", digest(reqBlock)},
+		{"Content-Type", "application/http;msgtype=request"},
+	}, reqBlock)
+	if err != nil {
+		return err
+	}
+
+	// Metadata record with the fetch timings.
+	metaBlock := warcFields([]field{
+		{"fetchTimeMs", strconv.FormatInt(ex.FetchDuration.Milliseconds(), 10)},
+		{"timeToFirstByteMs", strconv.FormatInt(ex.TimeToFirstByte.Milliseconds(), 10)},
+		{"truncated", strconv.FormatBool(ex.Truncated)},
+	})
+	_, err = w.writeRecord([]field{
+		{"WARC-Type", "metadata"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", warcDate},
+		{"WARC-Target-URI", ex.TargetURI},
+		{"WARC-Concurrent-To", respID},
+		{"WARC-Block-Digest", digest(metaBlock)},
+		{"Content-Type", "application/warc-fields"},
+	}, metaBlock)
+	if err != nil {
+		return err
+	}
+
+	lines := []cdxLine{{
+		url:         ex.TargetURI,
+		timestamp:   fetchedAt,
+		mime:        mimeType(ex),
+		status:      ex.StatusCode,
+		digest:      strings.TrimPrefix(payloadHash, "sha1:"),
+		length:      respLen,
+		offset:      respOffset,
+		filename:    w.name,
+		notModified: ex.NotModified,
+	}}
+
+	for i, redirect := range ex.Redirects {
+		location := ex.TargetURI
+		if i+1 < len(ex.Redirects) {
+			location = ex.Redirects[i+1].URL
+		}
+
+		line, err := w.writeRedirect(redirect, location, fetchedAt)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		if _, err = w.cdxBuf.WriteString(line.String()); err != nil {
+			return fmt.Errorf("warc: write cdx: %w", err)
+		}
+	}
+	if err = w.cdxBuf.Flush(); err != nil {
+		return fmt.Errorf("warc: write cdx: %w", err)
+	}
+
+	return nil
+}
+
+// writeRedirect archives a redirect to location and returns its CDX entry.
+func (w *Writer) writeRedirect(redirect Redirect, location string, fetchedAt time.Time) (cdxLine, error) {
+	block := []byte(fmt.Sprintf("HTTP/1.1 %d %s\r\nLocation: %s\r\nContent-Length: 0\r\n\r\n",
+		redirect.StatusCode, http.StatusText(redirect.StatusCode), location,
+	))
+
+	offset := w.offset
+	length, err := w.writeRecord([]field{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", fetchedAt.UTC().Format(time.RFC3339)},
+		{"WARC-Target-URI", redirect.URL},
+		{"WARC-Payload-Digest", digest(nil)},
+		{"WARC-Block-Digest", digest(block)},
+		{"Content-Type", "application/http;msgtype=response"},
+	}, block)
+	if err != nil {
+		return cdxLine{}, err
+	}
+
+	return cdxLine{
+		url:       redirect.URL,
+		timestamp: fetchedAt,
+		mime:      "unk",
+		status:    redirect.StatusCode,
+		digest:    strings.TrimPrefix(digest(nil), "sha1:"),
+		redirect:  location,
+		length:    length,
+		offset:    offset,
+		filename:  w.name,
+	}, nil
+}
+
+// Close finalizes the current WARC and CDX files.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	return w.closeFile()
+}
+
+// openFile creates a new WARC file and its CDX sidecar and writes the
+// warcinfo record.
+func (w *Writer) openFile() error {
+	w.serial++
+	w.name = fmt.Sprintf("%s-%s-%05d-%s.warc.gz",
+		w.cfg.Prefix, w.cfg.Clock().UTC().Format("20060102150405"), w.serial, w.hostname,
+	)
+
+	f, err := os.OpenFile(filepath.Join(w.cfg.Dir, w.name+openSuffix), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	cdx, err := os.OpenFile(filepath.Join(w.cfg.Dir, cdxName(w.name)+openSuffix), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("warc: %w", err)
+	}
+
+	w.file, w.cdx, w.offset = f, cdx, 0
+	w.cdxBuf = bufio.NewWriter(cdx)
+	if _, err = w.cdxBuf.WriteString(cdxHeader); err != nil {
+		return fmt.Errorf("warc: write cdx: %w", err)
+	}
+
+	info := []field{
+		{"software", w.cfg.Software},
+		{"format", "WARC File Format 1.1"},
+		{"conformsTo", "http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"},
+		{"hostname", w.hostname},
+	}
+	if w.cfg.Operator != "" {
+		info = append(info, field{"operator", w.cfg.Operator})
+	}
+	infoBlock := warcFields(info)
+	_, err = w.writeRecord([]field{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Date", w.cfg.Clock().UTC().Format(time.RFC3339)},
+		{"WARC-Filename", w.name},
+		{"WARC-Block-Digest", digest(infoBlock)},
+		{"Content-Type", "application/warc-fields"},
+	}, infoBlock)
+	return err
+}
+
+// closeFile closes the current WARC and CDX files and removes the suffix
+// that marks them as being written to.
+func (w *Writer) closeFile() error {
+	var errs []string
+	if err := w.cdxBuf.Flush(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, f := range []*os.File{w.file, w.cdx} {
+		if err := f.Close(); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if err := os.Rename(f.Name(), strings.TrimSuffix(f.Name(), openSuffix)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	w.file, w.cdx, w.cdxBuf = nil, nil, nil
+	if len(errs) != 0 {
+		return fmt.Errorf("warc: close %s: %s", w.name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// writeRecord appends a record as a separate gzip member to the current
+// file and returns its compressed length.
+func (w *Writer) writeRecord(headers []field, block []byte) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString(warcVersion + "\r\n")
+	for _, h := range headers {
+		buf.WriteString(h.name + ": " + h.value + "\r\n")
+	}
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(block)) + "\r\n\r\n")
+	buf.Write(block)
+	buf.WriteString("\r\n\r\n")
+
+	cw := &countingWriter{w: w.file}
+	gz := gzip.NewWriter(cw)
+	if _, err := gz.Write(buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("warc: write record: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return 0, fmt.Errorf("warc: write record: %w", err)
+	}
+
+	w.offset += cw.n
+	return cw.n, nil
+}
+
+// field is a named header or warc-fields entry.
+type field struct {
+	name  string
+	value string
+}
+
+// warcFields formats fields using the application/warc-fields syntax.
+func warcFields(fields []field) []byte {
+	var buf bytes.Buffer
+	for _, f := range fields {
+		buf.WriteString(f.name + ": " + f.value + "\r\n")
+	}
+	return buf.Bytes()
+}
+
+// responseBlock returns the HTTP response message for ex. Revisit records
+// only contain the status line and headers.
+func responseBlock(ex *Exchange) []byte {
+	proto := ex.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	header := ex.ResponseHeader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	if !ex.NotModified {
+		header.Set("Content-Length", strconv.Itoa(len(ex.Body)))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %d %s\r\n", proto, ex.StatusCode, http.StatusText(ex.StatusCode))
+	_ = header.Write(&buf)
+	buf.WriteString("\r\n")
+	if !ex.NotModified {
+		buf.Write(ex.Body)
+	}
+	return buf.Bytes()
+}
+
+// requestBlock returns the HTTP request message for ex.
+func requestBlock(ex *Exchange) []byte {
+	method := ex.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	requestURI, host := ex.TargetURI, ""
+	if idx := strings.Index(requestURI, "://"); idx != -1 {
+		rest := requestURI[idx+3:]
+		if slash := strings.IndexByte(rest, '/'); slash != -1 {
+			host, requestURI = rest[:slash], rest[slash:]
+		} else {
+			host, requestURI = rest, "/"
+		}
+	}
+	if hash := strings.IndexByte(requestURI, '#'); hash != -1 {
+		requestURI = requestURI[:hash]
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", method, requestURI)
+	if host != "" {
+		buf.WriteString("Host: " + host + "\r\n")
+	}
+	header := ex.RequestHeader.Clone()
+	if header != nil {
+		header.Del("Host")
+		_ = header.Write(&buf)
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// mimeType returns the media type of the response without parameters.
+func mimeType(ex *Exchange) string {
+	if ex.NotModified {
+		return "warc/revisit"
+	}
+	mime := ex.ResponseHeader.Get("Content-Type")
+	if idx := strings.IndexByte(mime, ';'); idx != -1 {
+		mime = mime[:idx]
+	}
+	if mime = strings.ToLower(strings.TrimSpace(mime)); mime == "" {
+		return "unk"
+	}
+	return mime
+}
+
+// digest returns the labelled, base32-encoded SHA-1 digest of data.
+func digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func newRecordID() string {
+	return "<urn:uuid:" + uuid.New().String() + ">"
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
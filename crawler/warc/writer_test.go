@@ -0,0 +1,343 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testFetchedAt = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+func TestWriteExchange(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir, Operator: "test"})
+
+	err := w.WriteExchange(&Exchange{
+		TargetURI:      "https://www.example.com/page?b=2&a=1",
+		RequestHeader:  http.Header{"User-Agent": {"GoSearchBot/1.0"}},
+		StatusCode:     http.StatusOK,
+		ResponseHeader: http.Header{"Content-Type": {"text/html; charset=utf-8"}, "Content-Encoding": {"gzip"}},
+		Body:           []byte("<html>hello</html>"),
+		Truncated:      true,
+		RemoteAddr:     "93.184.216.34:443",
+		Redirects: []Redirect{
+			{URL: "http://example.com/page?b=2&a=1", StatusCode: http.StatusMovedPermanently},
+			{URL: "https://example.com/page?b=2&a=1", StatusCode: http.StatusFound},
+		},
+		FetchedAt:     testFetchedAt,
+		FetchDuration: 1500 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files are marked as open until the writer is closed.
+	warcPath, cdxPath := singleWARC(t, dir, openSuffix)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	warcPath, cdxPath = strings.TrimSuffix(warcPath, openSuffix), strings.TrimSuffix(cdxPath, openSuffix)
+
+	records := readRecords(t, warcPath)
+	var types []string
+	for _, rec := range records {
+		types = append(types, rec.header.Get("WARC-Type"))
+	}
+	if got := strings.Join(types, ","); got != "warcinfo,response,request,metadata,response,response" {
+		t.Fatalf("unexpected record types %s", got)
+	}
+
+	info := records[0]
+	if info.header.Get("WARC-Filename") != filepath.Base(warcPath) || !strings.Contains(string(info.block), "operator: test\r\n") {
+		t.Errorf("unexpected warcinfo record %v: %q", info.header, info.block)
+	}
+
+	resp := records[1]
+	expHeaders := map[string]string{
+		"WARC-Target-URI":     "https://www.example.com/page?b=2&a=1",
+		"WARC-Date":           "2024-03-01T12:30:00Z",
+		"WARC-IP-Address":     "93.184.216.34",
+		"WARC-Truncated":      "length",
+		"WARC-Payload-Digest": digest([]byte("<html>hello</html>")),
+		"WARC-Block-Digest":   digest(resp.block),
+	}
+	for key, val := range expHeaders {
+		if got := resp.header.Get(key); got != val {
+			t.Errorf("expected response %s %q; got %q", key, val, got)
+		}
+	}
+	httpRes, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(resp.block)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(httpRes.Body); string(body) != "<html>hello</html>" {
+		t.Errorf("unexpected archived body %q", body)
+	}
+	if httpRes.Header.Get("Content-Encoding") != "" || httpRes.ContentLength != 18 {
+		t.Errorf("expected the archived response to describe the decoded body; got %v", httpRes.Header)
+	}
+
+	req := records[2]
+	if req.header.Get("WARC-Concurrent-To") != resp.header.Get("WARC-Record-ID") {
+		t.Error("expected the request record to refer to the response record")
+	}
+	if !strings.HasPrefix(string(req.block), "GET /page?b=2&a=1 HTTP/1.1\r\nHost: www.example.com\r\nUser-Agent: GoSearchBot/1.0\r\n") {
+		t.Errorf("unexpected request block %q", req.block)
+	}
+
+	if meta := string(records[3].block); !strings.Contains(meta, "fetchTimeMs: 1500\r\n") || !strings.Contains(meta, "truncated: true\r\n") {
+		t.Errorf("unexpected metadata block %q", meta)
+	}
+
+	// Each redirect points at the next hop and the last one at the target.
+	if got := records[4].block; !bytes.Contains(got, []byte("301 Moved Permanently\r\nLocation: https://example.com/page?b=2&a=1\r\n")) {
+		t.Errorf("unexpected first redirect %q", got)
+	}
+	if got := records[5].block; !bytes.Contains(got, []byte("302 Found\r\nLocation: https://www.example.com/page?b=2&a=1\r\n")) {
+		t.Errorf("unexpected second redirect %q", got)
+	}
+
+	// The CDX entries locate the response records.
+	lines := readCDX(t, cdxPath)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 CDX entries; got %d", len(lines))
+	}
+	expFields := [][]string{
+		{"com,example)/page?a=1&b=2", "20240301123000", "https://www.example.com/page?b=2&a=1", "text/html", "200", strings.TrimPrefix(expHeaders["WARC-Payload-Digest"], "sha1:"), "-"},
+		{"com,example)/page?a=1&b=2", "20240301123000", "http://example.com/page?b=2&a=1", "unk", "301", strings.TrimPrefix(digest(nil), "sha1:"), "https://example.com/page?b=2&a=1"},
+		{"com,example)/page?a=1&b=2", "20240301123000", "https://example.com/page?b=2&a=1", "unk", "302", strings.TrimPrefix(digest(nil), "sha1:"), "https://www.example.com/page?b=2&a=1"},
+	}
+	for i, fields := range lines {
+		if got := strings.Join(fields[:7], " "); got != strings.Join(expFields[i], " ") {
+			t.Errorf("CDX entry %d: expected %q; got %q", i, strings.Join(expFields[i], " "), got)
+		}
+		if fields[10] != filepath.Base(warcPath) {
+			t.Errorf("CDX entry %d: unexpected file name %s", i, fields[10])
+		}
+
+		rec := readRecordAt(t, warcPath, fields[9], fields[8])
+		if rec.header.Get("WARC-Target-URI") != fields[2] {
+			t.Errorf("CDX entry %d: expected the record for %s; got %s", i, fields[2], rec.header.Get("WARC-Target-URI"))
+		}
+	}
+}
+
+func TestWriteExchangeRevisit(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir})
+
+	err := w.WriteExchange(&Exchange{
+		TargetURI:      "https://example.com/",
+		StatusCode:     http.StatusNotModified,
+		NotModified:    true,
+		ResponseHeader: http.Header{"Etag": {`"v1"`}},
+		FetchedAt:      testFetchedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	warcPath, cdxPath := singleWARC(t, dir, "")
+	rec := readRecords(t, warcPath)[1]
+	if rec.header.Get("WARC-Type") != "revisit" || rec.header.Get("WARC-Profile") != profileServerNotModified {
+		t.Errorf("unexpected revisit record headers %v", rec.header)
+	}
+	if rec.header.Get("WARC-Payload-Digest") != "" {
+		t.Error("expected revisit records to have no payload digest")
+	}
+	if strings.Contains(string(rec.block), "Content-Length") {
+		t.Errorf("expected no Content-Length in the revisit block %q", rec.block)
+	}
+
+	if fields := readCDX(t, cdxPath)[0]; fields[3] != "warc/revisit" || fields[4] != "304" || fields[5] != "-" {
+		t.Errorf("unexpected CDX entry %v", fields)
+	}
+}
+
+func TestWriterRotatesFiles(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestWriter(t, Config{Dir: dir, Prefix: "TEST", MaxFileSize: 1})
+
+	for i := 0; i < 3; i++ {
+		err := w.WriteExchange(&Exchange{
+			TargetURI:  "https://example.com/" + strconv.Itoa(i),
+			StatusCode: http.StatusOK,
+			FetchedAt:  testFetchedAt,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	warcFiles, _ := filepath.Glob(filepath.Join(dir, "TEST-*.warc.gz"))
+	cdxFiles, _ := filepath.Glob(filepath.Join(dir, "TEST-*.cdx"))
+	if len(warcFiles) != 3 || len(cdxFiles) != 3 {
+		t.Fatalf("expected 3 WARC and CDX files; got %v and %v", warcFiles, cdxFiles)
+	}
+	for _, warcFile := range warcFiles {
+		records := readRecords(t, warcFile)
+		if len(records) != 4 || records[0].header.Get("WARC-Type") != "warcinfo" {
+			t.Errorf("%s: expected a warcinfo record and one exchange; got %d records", warcFile, len(records))
+		}
+	}
+}
+
+func TestSURT(t *testing.T) {
+	specs := []struct {
+		in  string
+		exp string
+	}{
+		{"http://www.Example.com/Path", "com,example)/path"},
+		{"https://example.com", "com,example)/"},
+		{"http://sub.example.co.uk:8080/a?b=2&A=1", "uk,co,example,sub:8080)/a?a=1&b=2"},
+		{"https://example.com:443/", "com,example)/"},
+		{"http://example.com/a%20b", "com,example)/a%20b"},
+		{"not a url", "not%20a%20url"},
+	}
+
+	for _, spec := range specs {
+		if got := SURT(spec.in); got != spec.exp {
+			t.Errorf("SURT(%q): expected %q; got %q", spec.in, spec.exp, got)
+		}
+	}
+}
+
+func TestNewWriterRequiresDir(t *testing.T) {
+	if _, err := NewWriter(Config{}); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func newTestWriter(t *testing.T, cfg Config) *Writer {
+	cfg.Clock = func() time.Time { return testFetchedAt }
+	w, err := NewWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// singleWARC returns the paths of the only WARC file in dir and its CDX
+// file, both ending in suffix.
+func singleWARC(t *testing.T, dir, suffix string) (string, string) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*.warc.gz"+suffix))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(matches) != 1 {
+		t.Fatalf("expected a single WARC file; got %v", matches)
+	}
+
+	cdxPath := filepath.Join(dir, cdxName(strings.TrimSuffix(filepath.Base(matches[0]), suffix))+suffix)
+	if _, err = os.Stat(cdxPath); err != nil {
+		t.Fatal(err)
+	}
+	return matches[0], cdxPath
+}
+
+type testRecord struct {
+	header textproto.MIMEHeader
+	block  []byte
+}
+
+// readRecords returns all records of a WARC file.
+func readRecords(t *testing.T, path string) []testRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(gz)
+
+	var records []testRecord
+	for {
+		if _, err = r.Peek(1); err == io.EOF {
+			return records
+		}
+		records = append(records, readRecord(t, r))
+	}
+}
+
+// readRecordAt reads the record stored as a single gzip member at the
+// offset and length given as CDX fields.
+func readRecordAt(t *testing.T, path, offsetField, lengthField string) testRecord {
+	t.Helper()
+	offset, _ := strconv.ParseInt(offsetField, 10, 64)
+	length, _ := strconv.ParseInt(lengthField, 10, 64)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data[offset : offset+length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz.Multistream(false)
+	return readRecord(t, bufio.NewReader(gz))
+}
+
+func readRecord(t *testing.T, r *bufio.Reader) testRecord {
+	t.Helper()
+	tr := textproto.NewReader(r)
+	if version, err := tr.ReadLine(); err != nil || version != warcVersion {
+		t.Fatalf("unexpected record version %q, %v", version, err)
+	}
+	header, err := tr.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := make([]byte, length+4)
+	if _, err = io.ReadFull(r, block); err != nil {
+		t.Fatal(err)
+	} else if string(block[length:]) != "\r\n\r\n" {
+		t.Fatalf("expected the record to be terminated by two CRLFs; got %q", block[length:])
+	}
+	return testRecord{header: header, block: block[:length]}
+}
+
+// readCDX returns the fields of the entries in a CDX file.
+func readCDX(t *testing.T, path string) [][]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if lines[0] != strings.TrimSuffix(cdxHeader, "\n") {
+		t.Fatalf("unexpected CDX header %q", lines[0])
+	}
+
+	var entries [][]string
+	for _, line := range lines[1:] {
+		entries = append(entries, strings.Fields(line))
+	}
+	return entries
+}
@@ -0,0 +1,33 @@
+package crawler
+
+import (
+	"net/http"
+
+	"github.com/iamleson98/go-search/crawler/warc"
+)
+
+// archiveExchange writes the request/response pair recorded on payload by
+// the fetcher to archive.
+func archiveExchange(archive ArchiveWriter, payload *crawlerPayload) error {
+	redirects := make([]warc.Redirect, len(payload.RedirectChain))
+	for i, hop := range payload.RedirectChain {
+		redirects[i] = warc.Redirect{URL: hop.URL, StatusCode: hop.StatusCode}
+	}
+
+	return archive.WriteExchange(&warc.Exchange{
+		TargetURI:       payload.URL,
+		Method:          payload.RequestMethod,
+		RequestHeader:   payload.RequestHeader,
+		Proto:           payload.Proto,
+		StatusCode:      payload.StatusCode,
+		ResponseHeader:  payload.Header,
+		Body:            payload.RawContent.Bytes(),
+		Truncated:       payload.Truncated,
+		NotModified:     payload.StatusCode == http.StatusNotModified,
+		RemoteAddr:      payload.RemoteAddr,
+		FetchedAt:       payload.FetchedAt,
+		TimeToFirstByte: payload.TimeToFirstByte,
+		FetchDuration:   payload.FetchDuration,
+		Redirects:       redirects,
+	})
+}
@@ -2,15 +2,20 @@ module github.com/iamleson98/go-search
 
 go 1.17
 
-require github.com/hashicorp/go-multierror v1.1.1
+require (
+	github.com/andybalholm/brotli v1.0.4
+	github.com/elastic/go-elasticsearch v0.0.0
+	github.com/google/uuid v1.3.0
+	github.com/hashicorp/go-multierror v1.1.1
+	github.com/lib/pq v1.10.4
+	github.com/microcosm-cc/bluemonday v1.0.16
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/aymerick/douceur v0.2.0 // indirect
-	github.com/elastic/go-elasticsearch v0.0.0 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/gorilla/css v1.0.0 // indirect
 	github.com/hashicorp/errwrap v1.0.0 // indirect
-	github.com/lib/pq v1.10.4 // indirect
-	github.com/microcosm-cc/bluemonday v1.0.16 // indirect
-	golang.org/x/net v0.0.0-20210614182718-04defd469f4e // indirect
+	golang.org/x/text v0.3.6 // indirect
 )
@@ -18,12 +18,124 @@ type Link struct {
 	ID          uuid.UUID
 	URL         string
 	RetrievedAt time.Time
+
+	// HTTP validators and content hash captured when the link was last
+	// retrieved; used for issuing conditional requests.
+	ETag         string
+	LastModified string
+	ContentHash  string
+
+	// Depth is the min number of links that separate this link from a
+	// crawl seed; seeds have a depth of zero. Links whose depth is not
+	// known have a depth of UnknownDepth; upserting a link with an
+	// unknown depth leaves its stored depth untouched.
+	Depth int
+
+	// Kind is the type of resource the link points to. Once a link has
+	// been upserted as a feed it remains one.
+	Kind LinkKind
+
+	// Status describes the outcome of the last attempt to fetch the link.
+	Status FetchStatus
+}
+
+// UnknownDepth is the depth of links whose distance from the crawl seeds is
+// not known, e.g. links added before depths were tracked.
+const UnknownDepth = -1
+
+// ChildDepth returns the depth of a link discovered on a page with the
+// specified depth. Pages with an unknown depth are treated as seeds.
+func ChildDepth(depth int) int {
+	if depth < 0 {
+		return 1
+	}
+	return depth + 1
+}
+
+// LinkKind describes the type of resource that a link points to.
+type LinkKind int
+
+const (
+	// LinkKindPage is a regular page; this is the default kind.
+	LinkKindPage LinkKind = iota
+
+	// LinkKindFeed is an RSS or Atom feed. Feeds are polled for new items
+	// on their own schedule instead of being crawled.
+	LinkKindFeed
+)
+
+// FetchStatus describes the outcome of the last attempt to fetch a link.
+type FetchStatus struct {
+	// The time of the last fetch attempt.
+	CheckedAt time.Time
+
+	// The HTTP status code of the last response or zero if no response
+	// was received.
+	StatusCode int
+
+	// The class of the error that caused the last fetch to fail (e.g.
+	// "timeout" or "http-status") or empty if it succeeded.
+	ErrorClass string
+
+	// The content type of the last response.
+	ContentType string
+
+	// The number of consecutive failed fetches.
+	Failures int
+
+	// The link should not be fetched again before this time.
+	RetryAfter time.Time
+
+	// Dead is set for links that have failed too many times in a row.
+	// Dead links are removed from the index but still retried after
+	// RetryAfter.
+	Dead bool
+}
+
+// CrawlSchedule is the recrawl schedule that the crawl frontier maintains
+// for a link.
+type CrawlSchedule struct {
+	// The time the link is due to be crawled.
+	NextCrawlAt time.Time
+
+	// The current estimate of the time between content changes.
+	Interval time.Duration
+
+	// The time of the last successful fetch and the last time a content
+	// change was observed.
+	LastCrawledAt time.Time
+	LastChangedAt time.Time
+
+	// The number of consecutive failed fetches.
+	Failures int
+
+	// The PageRank score of the link and its priority as declared by its
+	// site.
+	Score    float64
+	Priority float64
+}
+
+// ScheduledLink is a link along with its crawl schedule.
+type ScheduledLink struct {
+	Link     Link
+	Schedule CrawlSchedule
 }
 
 type Edge struct {
+	ID         uuid.UUID
+	Src        uuid.UUID // the origin link.
+	Dst        uuid.UUID // the destination link.
+	AnchorText string    // the text of the link in the origin page.
+	UpdatedAt  time.Time
+}
+
+// Redirect records that requests for the Src link are redirected to the
+// Dst link.
+type Redirect struct {
 	ID        uuid.UUID
-	Src       uuid.UUID // the origin link.
-	Dst       uuid.UUID // the destination link.
+	Src       uuid.UUID // the redirected link.
+	Dst       uuid.UUID // the final redirect target.
+	Permanent bool      // true for 301 and 308 redirects.
 	UpdatedAt time.Time
 }
 
@@ -40,9 +152,14 @@ type EdgeIterator interface {
 // Graph is implemented by objects that can mutate or query a link graph
 type Graph interface {
 	UpsertLink(link *Link) error
+	UpdateFetchStatus(id uuid.UUID, status *FetchStatus) error
 	FindLink(id uuid.UUID) (*Link, error)
+	FindLinkByURL(url string) (*Link, error)
 	Links(fromID, toID uuid.UUID, retrievedBefore time.Time) (LinkIterator, error)
 	UpsertEdge(edge *Edge) error
 	Edges(fromID, toID uuid.UUID, updatedBefore time.Time) (EdgeIterator, error)
 	RemoveStaleEdges(fromID uuid.UUID, updatedBefore time.Time) error
+	UpsertRedirect(redirect *Redirect) error
+	TransferInboundEdges(fromID, toID uuid.UUID) error
+	InboundAnchorText(dstID uuid.UUID, limit int) ([]string, error)
 }
@@ -10,12 +10,29 @@ import (
 )
 
 var (
-	upsertLinkQuery       = `INSERT INTO links (url, retrieved_at) VALUES ($1, $2) ON CONFLICT (url) DO UPDATE SET retrieved_at=GREATEST(links.retrieved_at, $2) RETURNING id, retrieved_at`
-	findLinkQuery         = `SELECT url, retrieved_at FROM links WHERE id=$1`
-	linksInPartitionQuery = `SELECT id, url, retrieved_at FROM links WHERE id >= $1 AND id < $2 retrieved_at < $3`
-	upsertEdgeQuery       = `INSERT INTO edges (src, dst, updated_at) VALUES ($1, $2, NOW()) ON CONFLICT (src, dst) DO UPDATE SET updated_at=NOW() RETURNING id, updated_at`
-	edgesInPartitionQuery = `SELECT id, src, dst, updated_at FROM edges WHERE src >= $1 AND src < $2 AND updated_at < $3`
-	removeStaleEdgesQuery = `DELETE FROM edges WHERE src=$1 AND updated_at < $2`
+	upsertLinkQuery = `INSERT INTO links (url, retrieved_at, etag, last_modified, content_hash, depth, kind) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (url) DO UPDATE SET
+	retrieved_at=GREATEST(links.retrieved_at, $2),
+	depth=LEAST(COALESCE(links.depth, $6::INT), COALESCE($6::INT, links.depth)),
+	kind=GREATEST(links.kind, $7),
+	etag=CASE WHEN $2 >= links.retrieved_at THEN $3 ELSE links.etag END,
+	last_modified=CASE WHEN $2 >= links.retrieved_at THEN $4 ELSE links.last_modified END,
+	content_hash=CASE WHEN $2 >= links.retrieved_at THEN $5 ELSE links.content_hash END
+	RETURNING id, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead`
+	findLinkQuery            = `SELECT url, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead FROM links WHERE id=$1`
+	findLinkByURLQuery       = `SELECT id, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead FROM links WHERE url=$1`
+	linksInPartitionQuery    = `SELECT id, url, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead FROM links WHERE id >= $1 AND id < $2 AND retrieved_at < $3`
+	updateFetchStatusQuery   = `UPDATE links SET checked_at=$2, status_code=$3, error_class=$4, content_type=$5, failures=$6, retry_after=$7, dead=$8 WHERE id=$1`
+	updateCrawlScheduleQuery = `UPDATE links SET scheduled=true, next_crawl_at=$2, crawl_interval=$3, last_crawled_at=$4, last_changed_at=$5, crawl_failures=$6, score=$7, priority=$8 WHERE id=$1`
+	findScheduledLinkQuery   = `SELECT id, url, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead, next_crawl_at, crawl_interval, last_crawled_at, last_changed_at, crawl_failures, score, priority FROM links WHERE id=$1 AND scheduled`
+	dueLinksQuery            = `SELECT id, url, retrieved_at, etag, last_modified, content_hash, depth, kind, checked_at, status_code, error_class, content_type, failures, retry_after, dead, next_crawl_at, crawl_interval, last_crawled_at, last_changed_at, crawl_failures, score, priority FROM links WHERE scheduled AND next_crawl_at <= $1`
+	maxScoreQuery            = `SELECT COALESCE(MAX(score), 0) FROM links`
+	upsertEdgeQuery          = `INSERT INTO edges (src, dst, anchor_text, updated_at) VALUES ($1, $2, $3, NOW()) ON CONFLICT (src, dst) DO UPDATE SET anchor_text=$3, updated_at=NOW() RETURNING id, updated_at`
+	edgesInPartitionQuery    = `SELECT id, src, dst, anchor_text, updated_at FROM edges WHERE src >= $1 AND src < $2 AND updated_at < $3`
+	removeStaleEdgesQuery    = `DELETE FROM edges WHERE src=$1 AND updated_at < $2`
+	upsertRedirectQuery      = `INSERT INTO redirects (src, dst, permanent, updated_at) VALUES ($1, $2, $3, NOW()) ON CONFLICT (src) DO UPDATE SET dst=$2, permanent=$3, updated_at=NOW() RETURNING id, updated_at`
+	copyInboundEdgesQuery    = `INSERT INTO edges (src, dst, anchor_text, updated_at) SELECT src, $2, anchor_text, updated_at FROM edges WHERE dst=$1 AND src != $2 ON CONFLICT (src, dst) DO UPDATE SET updated_at=GREATEST(edges.updated_at, excluded.updated_at)`
+	dropInboundEdgesQuery    = `DELETE FROM edges WHERE dst=$1`
+	inboundAnchorsQuery      = `SELECT anchor_text FROM edges WHERE dst=$1 AND anchor_text != '' GROUP BY anchor_text ORDER BY COUNT(*) DESC, anchor_text LIMIT $2`
 
 	_ graph.Graph = (*DbGraph)(nil)
 )
@@ -41,12 +58,28 @@ func (d *DbGraph) Close() error {
 
 // UpsertLink
 func (d *DbGraph) UpsertLink(link *graph.Link) error {
-	row := d.db.QueryRow(upsertLinkQuery, link.URL, link.RetrievedAt.UTC())
-	if err := row.Scan(&link.ID, &link.RetrievedAt); err != nil {
+	row := d.db.QueryRow(upsertLinkQuery, link.URL, link.RetrievedAt.UTC(), link.ETag, link.LastModified, link.ContentHash, depthArg(link.Depth), link.Kind)
+	if err := row.Scan(append([]interface{}{&link.ID, &link.RetrievedAt, &link.ETag, &link.LastModified, &link.ContentHash, depthField{&link.Depth}, &link.Kind}, fetchStatusFields(&link.Status)...)...); err != nil {
 		return fmt.Errorf("upsert link: %w", err)
 	}
 
 	link.RetrievedAt = link.RetrievedAt.UTC()
+	normalizeFetchStatus(&link.Status)
+	return nil
+}
+
+// UpdateFetchStatus replaces the fetch status of the link with the given ID.
+func (d *DbGraph) UpdateFetchStatus(id uuid.UUID, status *graph.FetchStatus) error {
+	res, err := d.db.Exec(updateFetchStatusQuery, id, status.CheckedAt.UTC(), status.StatusCode, status.ErrorClass, status.ContentType, status.Failures, status.RetryAfter.UTC(), status.Dead)
+	if err != nil {
+		return fmt.Errorf("update fetch status: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("update fetch status: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("update fetch status: %w", graph.ErrNotFound)
+	}
 	return nil
 }
 
@@ -59,7 +92,7 @@ func (d *DbGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
 		}
 	)
 
-	if err := row.Scan(&link.URL, &link.RetrievedAt); err != nil {
+	if err := row.Scan(append([]interface{}{&link.URL, &link.RetrievedAt, &link.ETag, &link.LastModified, &link.ContentHash, depthField{&link.Depth}, &link.Kind}, fetchStatusFields(&link.Status)...)...); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("find link: %w", graph.ErrNotFound)
 		}
@@ -68,9 +101,94 @@ func (d *DbGraph) FindLink(id uuid.UUID) (*graph.Link, error) {
 	}
 
 	link.RetrievedAt = link.RetrievedAt.UTC()
+	normalizeFetchStatus(&link.Status)
 	return link, nil
 }
 
+// FindLinkByURL looks up a link by its URL.
+func (d *DbGraph) FindLinkByURL(url string) (*graph.Link, error) {
+	var (
+		row  = d.db.QueryRow(findLinkByURLQuery, url)
+		link = &graph.Link{
+			URL: url,
+		}
+	)
+
+	if err := row.Scan(append([]interface{}{&link.ID, &link.RetrievedAt, &link.ETag, &link.LastModified, &link.ContentHash, depthField{&link.Depth}, &link.Kind}, fetchStatusFields(&link.Status)...)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find link by url: %w", graph.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("find link by url: %w", err)
+	}
+
+	link.RetrievedAt = link.RetrievedAt.UTC()
+	normalizeFetchStatus(&link.Status)
+	return link, nil
+}
+
+// UpdateCrawlSchedule replaces the crawl schedule of the link with the given
+// ID.
+func (d *DbGraph) UpdateCrawlSchedule(id uuid.UUID, schedule *graph.CrawlSchedule) error {
+	res, err := d.db.Exec(updateCrawlScheduleQuery, id, schedule.NextCrawlAt.UTC(), int64(schedule.Interval), schedule.LastCrawledAt.UTC(), schedule.LastChangedAt.UTC(), schedule.Failures, schedule.Score, schedule.Priority)
+	if err != nil {
+		return fmt.Errorf("update crawl schedule: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("update crawl schedule: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("update crawl schedule: %w", graph.ErrNotFound)
+	}
+	return nil
+}
+
+// FindScheduledLink looks up a link that has a crawl schedule by its ID.
+func (d *DbGraph) FindScheduledLink(id uuid.UUID) (*graph.ScheduledLink, error) {
+	sl := new(graph.ScheduledLink)
+	if err := scanScheduledLink(d.db.QueryRow(findScheduledLinkQuery, id), sl); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("find scheduled link: %w", graph.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("find scheduled link: %w", err)
+	}
+	return sl, nil
+}
+
+// DueLinks returns the links whose crawl schedule is due by the specified
+// time.
+func (d *DbGraph) DueLinks(dueBy time.Time) ([]*graph.ScheduledLink, error) {
+	rows, err := d.db.Query(dueLinksQuery, dueBy.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("due links: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var due []*graph.ScheduledLink
+	for rows.Next() {
+		sl := new(graph.ScheduledLink)
+		if err = scanScheduledLink(rows, sl); err != nil {
+			return nil, fmt.Errorf("due links: %w", err)
+		}
+		due = append(due, sl)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("due links: %w", err)
+	}
+	return due, nil
+}
+
+// MaxScore returns the highest score recorded in the crawl schedule of any
+// link.
+func (d *DbGraph) MaxScore() (float64, error) {
+	var maxScore float64
+	if err := d.db.QueryRow(maxScoreQuery).Scan(&maxScore); err != nil {
+		return 0, fmt.Errorf("max score: %w", err)
+	}
+	return maxScore, nil
+}
+
 // Links returns an iterator for the set of links whose IDs belong to the [fromID, toID) range were last accessed before provideed value
 func (d *DbGraph) Links(fromID, toID uuid.UUID, accessedBefore time.Time) (graph.LinkIterator, error) {
 	rows, err := d.db.Query(linksInPartitionQuery, fromID, toID, accessedBefore.UTC())
@@ -84,7 +202,7 @@ func (d *DbGraph) Links(fromID, toID uuid.UUID, accessedBefore time.Time) (graph
 }
 
 func (d *DbGraph) UpsertEdge(edge *graph.Edge) error {
-	row := d.db.QueryRow(upsertEdgeQuery, edge.Src, edge.Dst)
+	row := d.db.QueryRow(upsertEdgeQuery, edge.Src, edge.Dst, edge.AnchorText)
 	if err := row.Scan(&edge.ID, &edge.UpdatedAt); err != nil {
 		if isForeignKeyViolationError(err) {
 			err = graph.ErrUnknownEdgeLinks
@@ -113,3 +231,64 @@ func (d *DbGraph) RemoveStaleEdges(fromID uuid.UUID, updatedBefore time.Time) er
 
 	return nil
 }
+
+// UpsertRedirect creates a new redirect or updates the target of an existing
+// redirect for the same source link.
+func (d *DbGraph) UpsertRedirect(redirect *graph.Redirect) error {
+	row := d.db.QueryRow(upsertRedirectQuery, redirect.Src, redirect.Dst, redirect.Permanent)
+	if err := row.Scan(&redirect.ID, &redirect.UpdatedAt); err != nil {
+		if isForeignKeyViolationError(err) {
+			err = graph.ErrUnknownEdgeLinks
+		}
+		return fmt.Errorf("upsert redirect: %w", err)
+	}
+
+	redirect.UpdatedAt = redirect.UpdatedAt.UTC()
+	return nil
+}
+
+// TransferInboundEdges moves all edges that point to fromID so they point to
+// toID instead.
+func (d *DbGraph) TransferInboundEdges(fromID, toID uuid.UUID) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("transfer inbound edges: %w", err)
+	}
+
+	if _, err = tx.Exec(copyInboundEdgesQuery, fromID, toID); err == nil {
+		_, err = tx.Exec(dropInboundEdgesQuery, fromID)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("transfer inbound edges: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("transfer inbound edges: %w", err)
+	}
+	return nil
+}
+
+// InboundAnchorText returns up to limit distinct anchor texts of the edges
+// that point to dstID, most frequently used first.
+func (d *DbGraph) InboundAnchorText(dstID uuid.UUID, limit int) ([]string, error) {
+	rows, err := d.db.Query(inboundAnchorsQuery, dstID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("inbound anchor text: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var anchors []string
+	for rows.Next() {
+		var anchor string
+		if err = rows.Scan(&anchor); err != nil {
+			return nil, fmt.Errorf("inbound anchor text: %w", err)
+		}
+		anchors = append(anchors, anchor)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("inbound anchor text: %w", err)
+	}
+
+	return anchors, nil
+}
@@ -21,11 +21,12 @@ func (i *linkIterator) Next() bool {
 	}
 
 	l := new(graph.Link)
-	i.lastErr = i.rows.Scan(&l.ID, &l.URL, &l.RetrievedAt)
+	i.lastErr = i.rows.Scan(append([]interface{}{&l.ID, &l.URL, &l.RetrievedAt, &l.ETag, &l.LastModified, &l.ContentHash, depthField{&l.Depth}, &l.Kind}, fetchStatusFields(&l.Status)...)...)
 	if i.lastErr != nil {
 		return false
 	}
 	l.RetrievedAt = l.RetrievedAt.UTC()
+	normalizeFetchStatus(&l.Status)
 
 	i.latchedLink = l
 
@@ -63,7 +64,7 @@ func (i *edgeIterator) Next() bool {
 	}
 
 	e := new(graph.Edge)
-	i.lastErr = i.rows.Scan(&e.ID, &e.Src, &e.Dst, &e.UpdatedAt)
+	i.lastErr = i.rows.Scan(&e.ID, &e.Src, &e.Dst, &e.AnchorText, &e.UpdatedAt)
 	if i.lastErr != nil {
 		return false
 	}
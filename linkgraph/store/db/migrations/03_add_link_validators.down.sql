@@ -0,0 +1,3 @@
+ALTER TABLE links DROP COLUMN IF EXISTS etag;
+ALTER TABLE links DROP COLUMN IF EXISTS last_modified;
+ALTER TABLE links DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,3 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS etag STRING NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS last_modified STRING NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS content_hash STRING NOT NULL DEFAULT '';
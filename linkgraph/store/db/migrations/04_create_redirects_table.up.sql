@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS redirects (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    src UUID NOT NULL UNIQUE REFERENCES links(id) ON DELETE CASCADE,
+    dst UUID NOT NULL REFERENCES links(id) ON DELETE CASCADE,
+    permanent BOOL NOT NULL DEFAULT false,
+    updated_at TIMESTAMP
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS edges@edges_dst_idx;
+ALTER TABLE edges DROP COLUMN IF EXISTS anchor_text;
@@ -0,0 +1,2 @@
+ALTER TABLE edges ADD COLUMN IF NOT EXISTS anchor_text STRING NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS edges_dst_idx ON edges (dst) STORING (anchor_text);
@@ -0,0 +1 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS depth INT NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE links DROP COLUMN IF EXISTS dead;
+ALTER TABLE links DROP COLUMN IF EXISTS retry_after;
+ALTER TABLE links DROP COLUMN IF EXISTS failures;
+ALTER TABLE links DROP COLUMN IF EXISTS content_type;
+ALTER TABLE links DROP COLUMN IF EXISTS error_class;
+ALTER TABLE links DROP COLUMN IF EXISTS status_code;
+ALTER TABLE links DROP COLUMN IF EXISTS checked_at;
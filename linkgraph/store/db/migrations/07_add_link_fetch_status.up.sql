@@ -0,0 +1,7 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS checked_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS status_code INT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS error_class STRING NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS content_type STRING NOT NULL DEFAULT '';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS failures INT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS retry_after TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS dead BOOL NOT NULL DEFAULT false;
@@ -0,0 +1,3 @@
+UPDATE links SET depth = 0 WHERE depth IS NULL;
+ALTER TABLE links ALTER COLUMN depth SET DEFAULT 0;
+ALTER TABLE links ALTER COLUMN depth SET NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE links ALTER COLUMN depth DROP NOT NULL;
+ALTER TABLE links ALTER COLUMN depth DROP DEFAULT;
+UPDATE links SET depth = NULL WHERE depth = 0;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS links@links_score_idx;
+DROP INDEX IF EXISTS links@links_next_crawl_at_idx;
+ALTER TABLE links DROP COLUMN IF EXISTS priority;
+ALTER TABLE links DROP COLUMN IF EXISTS score;
+ALTER TABLE links DROP COLUMN IF EXISTS crawl_failures;
+ALTER TABLE links DROP COLUMN IF EXISTS last_changed_at;
+ALTER TABLE links DROP COLUMN IF EXISTS last_crawled_at;
+ALTER TABLE links DROP COLUMN IF EXISTS crawl_interval;
+ALTER TABLE links DROP COLUMN IF EXISTS next_crawl_at;
+ALTER TABLE links DROP COLUMN IF EXISTS scheduled;
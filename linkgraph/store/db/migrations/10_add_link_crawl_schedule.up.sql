@@ -0,0 +1,10 @@
+ALTER TABLE links ADD COLUMN IF NOT EXISTS scheduled BOOL NOT NULL DEFAULT false;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS next_crawl_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS crawl_interval INT8 NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS last_crawled_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS last_changed_at TIMESTAMP NOT NULL DEFAULT '0001-01-01 00:00:00';
+ALTER TABLE links ADD COLUMN IF NOT EXISTS crawl_failures INT NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS score FLOAT8 NOT NULL DEFAULT 0;
+ALTER TABLE links ADD COLUMN IF NOT EXISTS priority FLOAT8 NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS links_next_crawl_at_idx ON links (scheduled, next_crawl_at);
+CREATE INDEX IF NOT EXISTS links_score_idx ON links (score);
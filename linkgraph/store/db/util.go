@@ -1,6 +1,11 @@
 package db
 
-import "github.com/lib/pq"
+import (
+	"database/sql"
+
+	"github.com/iamleson98/go-search/linkgraph/graph"
+	"github.com/lib/pq"
+)
 
 func isForeignKeyViolationError(err error) bool {
 	pgErr, valid := err.(*pq.Error)
@@ -10,3 +15,75 @@ func isForeignKeyViolationError(err error) bool {
 
 	return pgErr.Code.Name() == "foreign_key_violation"
 }
+
+// depthArg returns the value stored in the depth column for depth. Unknown
+// depths are stored as NULL.
+func depthArg(depth int) interface{} {
+	if depth < 0 {
+		return nil
+	}
+	return depth
+}
+
+// depthField scans the depth column, mapping NULL to graph.UnknownDepth.
+type depthField struct {
+	depth *int
+}
+
+func (f depthField) Scan(src interface{}) error {
+	var depth sql.NullInt64
+	if err := depth.Scan(src); err != nil {
+		return err
+	}
+
+	*f.depth = graph.UnknownDepth
+	if depth.Valid {
+		*f.depth = int(depth.Int64)
+	}
+	return nil
+}
+
+// fetchStatusFields returns the scan destinations for the fetch status
+// columns of the links table, in the order they are selected.
+func fetchStatusFields(status *graph.FetchStatus) []interface{} {
+	return []interface{}{
+		&status.CheckedAt,
+		&status.StatusCode,
+		&status.ErrorClass,
+		&status.ContentType,
+		&status.Failures,
+		&status.RetryAfter,
+		&status.Dead,
+	}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanScheduledLink scans the link and crawl schedule columns of the links
+// table, in the order they are selected.
+func scanScheduledLink(row scanner, sl *graph.ScheduledLink) error {
+	var (
+		l = &sl.Link
+		s = &sl.Schedule
+	)
+	dest := append([]interface{}{&l.ID, &l.URL, &l.RetrievedAt, &l.ETag, &l.LastModified, &l.ContentHash, depthField{&l.Depth}, &l.Kind}, fetchStatusFields(&l.Status)...)
+	dest = append(dest, &s.NextCrawlAt, &s.Interval, &s.LastCrawledAt, &s.LastChangedAt, &s.Failures, &s.Score, &s.Priority)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	l.RetrievedAt = l.RetrievedAt.UTC()
+	normalizeFetchStatus(&l.Status)
+	s.NextCrawlAt = s.NextCrawlAt.UTC()
+	s.LastCrawledAt = s.LastCrawledAt.UTC()
+	s.LastChangedAt = s.LastChangedAt.UTC()
+	return nil
+}
+
+func normalizeFetchStatus(status *graph.FetchStatus) {
+	status.CheckedAt = status.CheckedAt.UTC()
+	status.RetryAfter = status.RetryAfter.UTC()
+}
@@ -0,0 +1,371 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Supported stage types for a StageSpec.
+const (
+	StageTypeFIFO              = "fifo"
+	StageTypeFixedWorkerPool   = "fixed_worker_pool"
+	StageTypeDynamicWorkerPool = "dynamic_worker_pool"
+	StageTypeBroadcast         = "broadcast"
+)
+
+// Supported error policies for a ProcessorSpec.
+const (
+	// ErrorPolicyAbort propagates processor errors and terminates the pipeline.
+	ErrorPolicyAbort = "abort"
+
+	// ErrorPolicySkip drops the payload that caused the error and keeps going.
+	ErrorPolicySkip = "skip"
+)
+
+// Spec describes the shape of a pipeline as a list of stages.
+type Spec struct {
+	Stages []StageSpec `yaml:"stages" json:"stages"`
+}
+
+// StageSpec describes a single pipeline stage.
+type StageSpec struct {
+	// Type selects the StageRunner factory for this stage.
+	Type string `yaml:"type" json:"type"`
+
+	// Workers is the number of workers for fixed_worker_pool stages or
+	// the max number of workers for dynamic_worker_pool stages. It must
+	// not be set for fifo and broadcast stages.
+	Workers int `yaml:"workers" json:"workers"`
+
+	// Processors lists the processors for this stage. All stage types
+	// except broadcast expect exactly one processor.
+	Processors []ProcessorSpec `yaml:"processors" json:"processors"`
+
+	// Params are passed verbatim to the StageRunnerFactory. The built-in
+	// stage types do not accept any params.
+	Params Params `yaml:"params" json:"params"`
+}
+
+// ProcessorSpec describes a processor and how its errors are handled.
+type ProcessorSpec struct {
+	// Name selects the ProcessorFactory for this processor.
+	Name string `yaml:"name" json:"name"`
+
+	// Timeout, if specified, bounds each Process call. It is parsed
+	// using time.ParseDuration.
+	Timeout string `yaml:"timeout" json:"timeout"`
+
+	// ErrorPolicy is either "abort" (default) or "skip".
+	ErrorPolicy string `yaml:"error_policy" json:"error_policy"`
+
+	// Params are passed verbatim to the ProcessorFactory.
+	Params Params `yaml:"params" json:"params"`
+}
+
+// Params holds the free-form parameters of a StageSpec or ProcessorSpec.
+type Params map[string]interface{}
+
+// CheckKnown returns an error if p contains a key that is not listed in
+// known. Factories use it to reject misspelled or unsupported params.
+func (p Params) CheckKnown(known ...string) error {
+	var unknown []string
+	for key := range p {
+		found := false
+		for _, k := range known {
+			if key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown param %q", unknown[0])
+	}
+	return nil
+}
+
+// Int returns the integer value of key or def if the key is not present.
+func (p Params) Int(key string, def int) (int, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n != float64(int(n)) {
+			return 0, fmt.Errorf("param %q: expected an integer value", key)
+		}
+		return int(n), nil
+	default:
+		return 0, fmt.Errorf("param %q: expected an integer value, got %T", key, v)
+	}
+}
+
+// Bool returns the boolean value of key or def if the key is not present.
+func (p Params) Bool(key string, def bool) (bool, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("param %q: expected a boolean value, got %T", key, v)
+	}
+	return b, nil
+}
+
+// String returns the string value of key or def if the key is not present.
+func (p Params) String(key string, def string) (string, error) {
+	v, ok := p[key]
+	if !ok {
+		return def, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("param %q: expected a string value, got %T", key, v)
+	}
+	return s, nil
+}
+
+// Duration returns the time.Duration value of key or def if the key is not present.
+func (p Params) Duration(key string, def time.Duration) (time.Duration, error) {
+	s, err := p.String(key, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("param %q: %w", key, err)
+	}
+	return d, nil
+}
+
+// LoadSpec decodes a YAML or JSON pipeline specification from r.
+func LoadSpec(r io.Reader) (*Spec, error) {
+	var spec Spec
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("load pipeline spec: %w", err)
+	}
+
+	return &spec, nil
+}
+
+// ProcessorFactory creates a Processor using the supplied params.
+type ProcessorFactory func(params Params) (Processor, error)
+
+// StageRunnerFactory creates a StageRunner for a stage spec and the
+// processors that have been built for it.
+type StageRunnerFactory func(spec StageSpec, procs []Processor) (StageRunner, error)
+
+// Registry maps names to processor and stage runner factories. The
+// built-in stage types are registered by NewRegistry.
+type Registry struct {
+	mu     sync.RWMutex
+	procs  map[string]ProcessorFactory
+	stages map[string]StageRunnerFactory
+}
+
+// NewRegistry returns a Registry with the built-in stage types registered.
+func NewRegistry() *Registry {
+	r := &Registry{
+		procs:  make(map[string]ProcessorFactory),
+		stages: make(map[string]StageRunnerFactory),
+	}
+
+	r.RegisterStage(StageTypeFIFO, func(spec StageSpec, procs []Processor) (StageRunner, error) {
+		if err := checkBuiltinStage(spec, false); err != nil {
+			return nil, err
+		} else if len(procs) != 1 {
+			return nil, fmt.Errorf("%s stage expects exactly one processor", spec.Type)
+		}
+		return FIFO(procs[0]), nil
+	})
+	r.RegisterStage(StageTypeFixedWorkerPool, func(spec StageSpec, procs []Processor) (StageRunner, error) {
+		if err := checkBuiltinStage(spec, true); err != nil {
+			return nil, err
+		} else if len(procs) != 1 {
+			return nil, fmt.Errorf("%s stage expects exactly one processor", spec.Type)
+		} else if spec.Workers <= 0 {
+			return nil, fmt.Errorf("%s stage expects workers > 0", spec.Type)
+		}
+		return FixedWorkerPool(procs[0], spec.Workers), nil
+	})
+	r.RegisterStage(StageTypeDynamicWorkerPool, func(spec StageSpec, procs []Processor) (StageRunner, error) {
+		if err := checkBuiltinStage(spec, true); err != nil {
+			return nil, err
+		} else if len(procs) != 1 {
+			return nil, fmt.Errorf("%s stage expects exactly one processor", spec.Type)
+		} else if spec.Workers <= 0 {
+			return nil, fmt.Errorf("%s stage expects workers > 0", spec.Type)
+		}
+		return DynamicWorkerPool(procs[0], spec.Workers), nil
+	})
+	r.RegisterStage(StageTypeBroadcast, func(spec StageSpec, procs []Processor) (StageRunner, error) {
+		if err := checkBuiltinStage(spec, false); err != nil {
+			return nil, err
+		} else if len(procs) == 0 {
+			return nil, fmt.Errorf("%s stage expects at least one processor", spec.Type)
+		}
+		return Broadcast(procs...), nil
+	})
+
+	return r
+}
+
+// checkBuiltinStage rejects the settings of spec that a built-in stage type
+// would silently ignore: any params and, unless usesWorkers is set, a
+// worker count.
+func checkBuiltinStage(spec StageSpec, usesWorkers bool) error {
+	if err := spec.Params.CheckKnown(); err != nil {
+		return fmt.Errorf("%s stage: %w", spec.Type, err)
+	}
+	if !usesWorkers && spec.Workers != 0 {
+		return fmt.Errorf("%s stage does not support workers", spec.Type)
+	}
+	return nil
+}
+
+// RegisterProcessor associates a processor factory with name, replacing
+// any previously registered factory.
+func (r *Registry) RegisterProcessor(name string, f ProcessorFactory) {
+	r.mu.Lock()
+	r.procs[name] = f
+	r.mu.Unlock()
+}
+
+// RegisterStage associates a stage runner factory with name, replacing
+// any previously registered factory.
+func (r *Registry) RegisterStage(name string, f StageRunnerFactory) {
+	r.mu.Lock()
+	r.stages[name] = f
+	r.mu.Unlock()
+}
+
+// Processors returns the sorted list of registered processor names.
+func (r *Registry) Processors() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.procs))
+	for name := range r.procs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build assembles a Pipeline from spec using the registered factories.
+func (r *Registry) Build(spec *Spec) (*Pipeline, error) {
+	if spec == nil || len(spec.Stages) == 0 {
+		return nil, fmt.Errorf("build pipeline: spec does not define any stages")
+	}
+
+	stages := make([]StageRunner, len(spec.Stages))
+	for i, stageSpec := range spec.Stages {
+		stage, err := r.buildStage(stageSpec)
+		if err != nil {
+			return nil, fmt.Errorf("build pipeline: stage %d: %w", i, err)
+		}
+		stages[i] = stage
+	}
+
+	return New(stages...), nil
+}
+
+func (r *Registry) buildStage(spec StageSpec) (StageRunner, error) {
+	r.mu.RLock()
+	stageFactory, ok := r.stages[spec.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown stage type %q", spec.Type)
+	}
+
+	procs := make([]Processor, len(spec.Processors))
+	for i, procSpec := range spec.Processors {
+		proc, err := r.buildProcessor(procSpec)
+		if err != nil {
+			return nil, fmt.Errorf("processor %q: %w", procSpec.Name, err)
+		}
+		procs[i] = proc
+	}
+
+	return stageFactory(spec, procs)
+}
+
+func (r *Registry) buildProcessor(spec ProcessorSpec) (Processor, error) {
+	r.mu.RLock()
+	procFactory, ok := r.procs[spec.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown processor")
+	}
+
+	proc, err := procFactory(spec.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		proc = WithTimeout(proc, timeout)
+	}
+
+	switch spec.ErrorPolicy {
+	case "", ErrorPolicyAbort:
+	case ErrorPolicySkip:
+		proc = SkipOnError(proc)
+	default:
+		return nil, fmt.Errorf("unknown error policy %q", spec.ErrorPolicy)
+	}
+
+	return proc, nil
+}
+
+// WithTimeout wraps proc so that each Process call receives a context
+// that expires after timeout.
+func WithTimeout(proc Processor, timeout time.Duration) Processor {
+	return ProcessorFunc(func(ctx context.Context, p Payload) (Payload, error) {
+		tCtx, cancelFn := context.WithTimeout(ctx, timeout)
+		defer cancelFn()
+		return proc.Process(tCtx, p)
+	})
+}
+
+// SkipOnError wraps proc so that errors cause the offending payload to be
+// dropped instead of terminating the pipeline. Errors caused by the
+// pipeline context being cancelled are still propagated.
+func SkipOnError(proc Processor) Processor {
+	return ProcessorFunc(func(ctx context.Context, p Payload) (Payload, error) {
+		out, err := proc.Process(ctx, p)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+		return out, nil
+	})
+}
@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSpec(t *testing.T) {
+	spec, err := LoadSpec(strings.NewReader(`
+stages:
+  - type: fixed_worker_pool
+    workers: 4
+    processors:
+      - name: double
+        timeout: 5s
+        error_policy: skip
+        params:
+          factor: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spec.Stages) != 1 {
+		t.Fatalf("expected 1 stage; got %d", len(spec.Stages))
+	}
+	stage := spec.Stages[0]
+	if stage.Type != StageTypeFixedWorkerPool || stage.Workers != 4 {
+		t.Errorf("unexpected stage spec %+v", stage)
+	}
+	proc := stage.Processors[0]
+	if proc.Name != "double" || proc.Timeout != "5s" || proc.ErrorPolicy != ErrorPolicySkip {
+		t.Errorf("unexpected processor spec %+v", proc)
+	}
+	if factor, err := proc.Params.Int("factor", 0); err != nil || factor != 2 {
+		t.Errorf("expected factor param 2; got %d, %v", factor, err)
+	}
+}
+
+func TestLoadSpecRejectsUnknownFields(t *testing.T) {
+	_, err := LoadSpec(strings.NewReader("stages:\n  - type: fifo\n    worker: 2\n"))
+	if err == nil {
+		t.Fatal("expected an error for a misspelled field")
+	}
+}
+
+func TestParams(t *testing.T) {
+	p := Params{
+		"int":      3,
+		"float":    4.0,
+		"fraction": 1.5,
+		"bool":     true,
+		"string":   "foo",
+		"duration": "2m",
+		"bad_dur":  "soon",
+	}
+
+	if v, err := p.Int("int", 0); err != nil || v != 3 {
+		t.Errorf("Int(int): got %d, %v", v, err)
+	}
+	if v, err := p.Int("float", 0); err != nil || v != 4 {
+		t.Errorf("Int(float): got %d, %v", v, err)
+	}
+	if _, err := p.Int("fraction", 0); err == nil {
+		t.Error("Int(fraction): expected an error")
+	}
+	if _, err := p.Int("string", 0); err == nil {
+		t.Error("Int(string): expected an error")
+	}
+	if v, err := p.Int("missing", 7); err != nil || v != 7 {
+		t.Errorf("Int(missing): got %d, %v", v, err)
+	}
+	if v, err := p.Bool("bool", false); err != nil || !v {
+		t.Errorf("Bool(bool): got %t, %v", v, err)
+	}
+	if _, err := p.Bool("string", false); err == nil {
+		t.Error("Bool(string): expected an error")
+	}
+	if v, err := p.String("string", ""); err != nil || v != "foo" {
+		t.Errorf("String(string): got %q, %v", v, err)
+	}
+	if v, err := p.Duration("duration", 0); err != nil || v != 2*time.Minute {
+		t.Errorf("Duration(duration): got %s, %v", v, err)
+	}
+	if v, err := p.Duration("missing", time.Second); err != nil || v != time.Second {
+		t.Errorf("Duration(missing): got %s, %v", v, err)
+	}
+	if _, err := p.Duration("bad_dur", 0); err == nil {
+		t.Error("Duration(bad_dur): expected an error")
+	}
+
+	if err := p.CheckKnown("int", "float", "fraction", "bool", "string", "duration", "bad_dur"); err != nil {
+		t.Errorf("CheckKnown: unexpected error %v", err)
+	}
+	if err := p.CheckKnown("int"); err == nil || !strings.Contains(err.Error(), `"bad_dur"`) {
+		t.Errorf("CheckKnown: expected the first unknown param to be reported; got %v", err)
+	}
+}
+
+func TestRegistryBuildErrors(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterProcessor("nop", func(params Params) (Processor, error) {
+		if err := params.CheckKnown(); err != nil {
+			return nil, err
+		}
+		return ProcessorFunc(func(_ context.Context, p Payload) (Payload, error) { return p, nil }), nil
+	})
+	nop := []ProcessorSpec{{Name: "nop"}}
+
+	specs := []struct {
+		descr string
+		spec  *Spec
+	}{
+		{"no stages", &Spec{}},
+		{"unknown stage type", &Spec{Stages: []StageSpec{{Type: "magic", Processors: nop}}}},
+		{"unknown processor", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: []ProcessorSpec{{Name: "missing"}}}}}},
+		{"unknown processor param", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: []ProcessorSpec{{Name: "nop", Params: Params{"x": 1}}}}}}},
+		{"invalid timeout", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: []ProcessorSpec{{Name: "nop", Timeout: "soon"}}}}}},
+		{"unknown error policy", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: []ProcessorSpec{{Name: "nop", ErrorPolicy: "retry"}}}}}},
+		{"fifo with two processors", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: append(nop, nop...)}}}},
+		{"fifo with workers", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Workers: 2, Processors: nop}}}},
+		{"fifo with params", &Spec{Stages: []StageSpec{{Type: StageTypeFIFO, Processors: nop, Params: Params{"workers": 2}}}}},
+		{"broadcast with workers", &Spec{Stages: []StageSpec{{Type: StageTypeBroadcast, Workers: 2, Processors: nop}}}},
+		{"broadcast without processors", &Spec{Stages: []StageSpec{{Type: StageTypeBroadcast}}}},
+		{"fixed pool without workers", &Spec{Stages: []StageSpec{{Type: StageTypeFixedWorkerPool, Processors: nop}}}},
+		{"fixed pool with params", &Spec{Stages: []StageSpec{{Type: StageTypeFixedWorkerPool, Workers: 2, Processors: nop, Params: Params{"size": 2}}}}},
+		{"dynamic pool without workers", &Spec{Stages: []StageSpec{{Type: StageTypeDynamicWorkerPool, Processors: nop}}}},
+	}
+
+	for _, spec := range specs {
+		if _, err := reg.Build(spec.spec); err == nil {
+			t.Errorf("%s: expected an error", spec.descr)
+		}
+	}
+}
+
+func TestRegistryBuildAndRun(t *testing.T) {
+	reg := NewRegistry()
+	reg.RegisterProcessor("add", func(params Params) (Processor, error) {
+		if err := params.CheckKnown("n"); err != nil {
+			return nil, err
+		}
+		n, err := params.Int("n", 1)
+		if err != nil {
+			return nil, err
+		}
+		return ProcessorFunc(func(_ context.Context, p Payload) (Payload, error) {
+			p.(*intPayload).val += n
+			return p, nil
+		}), nil
+	})
+	reg.RegisterProcessor("fail_odd", func(Params) (Processor, error) {
+		return ProcessorFunc(func(_ context.Context, p Payload) (Payload, error) {
+			if p.(*intPayload).val%2 != 0 {
+				return nil, errors.New("odd value")
+			}
+			return p, nil
+		}), nil
+	})
+
+	if got := reg.Processors(); len(got) != 2 || got[0] != "add" || got[1] != "fail_odd" {
+		t.Fatalf("unexpected processor list %v", got)
+	}
+
+	spec := &Spec{Stages: []StageSpec{
+		{Type: StageTypeFixedWorkerPool, Workers: 2, Processors: []ProcessorSpec{{Name: "add", Params: Params{"n": 10}}}},
+		{Type: StageTypeFIFO, Processors: []ProcessorSpec{{Name: "fail_odd", ErrorPolicy: ErrorPolicySkip}}},
+		{Type: StageTypeDynamicWorkerPool, Workers: 2, Processors: []ProcessorSpec{{Name: "add", Timeout: "1s"}}},
+	}}
+	p, err := reg.Build(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := &intSource{vals: []int{0, 1, 2, 3, 4}}
+	sink := new(intSink)
+	if err = p.Process(context.Background(), src, sink); err != nil {
+		t.Fatal(err)
+	}
+
+	// Odd values are dropped by the skipping processor; the order of the
+	// others depends on the worker pools.
+	sum := 0
+	for _, v := range sink.vals {
+		sum += v
+	}
+	if len(sink.vals) != 3 || sum != 11+13+15 {
+		t.Errorf("unexpected output %v", sink.vals)
+	}
+}
+
+func TestSkipOnErrorPropagatesCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	proc := SkipOnError(ProcessorFunc(func(ctx context.Context, p Payload) (Payload, error) {
+		return nil, ctx.Err()
+	}))
+	if _, err := proc.Process(ctx, &intPayload{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation error; got %v", err)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	proc := WithTimeout(ProcessorFunc(func(ctx context.Context, p Payload) (Payload, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}), 10*time.Millisecond)
+
+	if _, err := proc.Process(context.Background(), &intPayload{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error; got %v", err)
+	}
+}
+
+type intPayload struct {
+	val int
+}
+
+func (p *intPayload) Clone() Payload   { return &intPayload{val: p.val} }
+func (p *intPayload) MarkAsProcessed() {}
+
+type intSource struct {
+	vals []int
+	cur  int
+}
+
+func (s *intSource) Next(context.Context) bool {
+	if len(s.vals) == 0 {
+		return false
+	}
+	s.cur, s.vals = s.vals[0], s.vals[1:]
+	return true
+}
+
+func (s *intSource) Payload() Payload { return &intPayload{val: s.cur} }
+func (s *intSource) Error() error     { return nil }
+
+type intSink struct {
+	vals []int
+}
+
+func (s *intSink) Consume(_ context.Context, p Payload) error {
+	s.vals = append(s.vals, p.(*intPayload).val)
+	return nil
+}
@@ -14,4 +14,32 @@ type Document struct {
 	Content   string
 	IndexedAt time.Time
 	PageRank  float64
+
+	// Charset is the character encoding the page was served in.
+	Charset string
+
+	// Language is the ISO 639-1 code of the language the page is written
+	// in or empty if it could not be determined.
+	Language string
+
+	// The metadata that the page declares about itself.
+	Description string
+	Keywords    []string
+	Headings    []string
+
+	// The OpenGraph (or Twitter card) title, description and image URL.
+	OGTitle       string
+	OGDescription string
+	OGImage       string
+
+	// CanonicalURL is the URL the page declares as its canonical one.
+	CanonicalURL string
+
+	// PublishedAt is the publish date declared by the page or the zero
+	// time if the page does not declare one.
+	PublishedAt time.Time
+
+	// AnchorText contains the distinct anchor texts of the links that
+	// point to the page.
+	AnchorText []string
 }
@@ -10,6 +10,8 @@ type Indexer interface {
 	FindByID(linkID uuid.UUID) (*Document, error)
 	Search(query Query) (Iterator, error)
 	UpdateScore(linkID uuid.UUID, score float64) error
+	UpdateAnchorText(linkID uuid.UUID, anchors []string) error
+	Delete(linkID uuid.UUID) error
 }
 
 // Iterator is implemented by objects that can paginate search results
@@ -33,4 +35,9 @@ type Query struct {
 	Type       QueryType // the way indexer should interpret the search expression
 	Expression string    // the search expression
 	Offset     uint64
+
+	// Language optionally restricts the results to documents written in
+	// the specified language. Language tags such as "en-US" are reduced
+	// to their ISO 639-1 code.
+	Language string
 }
@@ -0,0 +1,31 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeLanguage converts a language tag such as "en-US" or "pt_BR" (as
+// used by the lang attribute and the Content-Language header) into a
+// lower-case primary language subtag, the form used by Document.Language
+// and Query.Language. It returns an empty string for tags that do not name
+// a language.
+func NormalizeLanguage(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if idx := strings.IndexAny(tag, ",;"); idx != -1 {
+		tag = strings.TrimSpace(tag[:idx])
+	}
+	if idx := strings.IndexAny(tag, "-_"); idx != -1 {
+		tag = tag[:idx]
+	}
+
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	for _, r := range tag {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return ""
+		}
+	}
+	return strings.ToLower(tag)
+}
@@ -0,0 +1,29 @@
+package index
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	specs := []struct {
+		tag string
+		exp string
+	}{
+		{"en", "en"},
+		{"EN-us", "en"},
+		{"pt_BR", "pt"},
+		{" de-DE ", "de"},
+		{"fr-CA,fr;q=0.9,en;q=0.8", "fr"},
+		{"fil", "fil"},
+		{"", ""},
+		{"x", ""},
+		{"english", ""},
+		{"e1", ""},
+		{"çe", ""},
+		{"*", ""},
+	}
+
+	for _, spec := range specs {
+		if got := NormalizeLanguage(spec.tag); got != spec.exp {
+			t.Errorf("NormalizeLanguage(%q): expected %q; got %q", spec.tag, spec.exp, got)
+		}
+	}
+}
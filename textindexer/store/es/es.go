@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -18,17 +19,29 @@ const indexName = "textindexer"
 
 const batchSize = 10
 
+// esMapping describes the document fields. It is used for creating the
+// index and for adding the fields introduced since an existing index was
+// created.
 var esMapping = `
 {
-	"mappings" : {
-		"properties": 	{
-			"LinkID": 	 {"type": "keyword"},
-			"URL": 		 {"type": "keyword"},
-			"Content": 	 {"type": "text"},
-			"Title": 	 {"type": "text"},
-			"IndexedAt": {"type": "date"},
-			"PageRank":  {"type": "double"}
-		}
+	"properties": {
+		"LinkID":        {"type": "keyword"},
+		"URL":           {"type": "keyword"},
+		"Content":       {"type": "text"},
+		"Title":         {"type": "text"},
+		"IndexedAt":     {"type": "date"},
+		"PageRank":      {"type": "double"},
+		"Charset":       {"type": "keyword"},
+		"Language":      {"type": "keyword"},
+		"Description":   {"type": "text"},
+		"Keywords":      {"type": "text"},
+		"Headings":      {"type": "text"},
+		"OGTitle":       {"type": "text"},
+		"OGDescription": {"type": "text"},
+		"OGImage":       {"type": "keyword", "index": false},
+		"CanonicalURL":  {"type": "keyword"},
+		"PublishedAt":   {"type": "date"},
+		"AnchorText":    {"type": "text"}
 	}
 }`
 
@@ -49,6 +62,10 @@ type esHitWrapper struct {
 	DocSource esDoc `json:"_source"`
 }
 
+// esDoc is the indexed representation of a document. Index performs a
+// partial update that preserves the PageRank score so all other fields are
+// always sent, even when empty, to clear values from earlier versions of the
+// page.
 type esDoc struct {
 	LinkID    string    `json:"LinkID"`
 	URL       string    `json:"URL"`
@@ -56,6 +73,18 @@ type esDoc struct {
 	Content   string    `json:"Content"`
 	IndexedAt time.Time `json:"IndexedAt"`
 	PageRank  float64   `json:"PageRank,omitempty"`
+	Charset   string    `json:"Charset"`
+	Language  string    `json:"Language"`
+
+	Description   string     `json:"Description"`
+	Keywords      []string   `json:"Keywords"`
+	Headings      []string   `json:"Headings"`
+	OGTitle       string     `json:"OGTitle"`
+	OGDescription string     `json:"OGDescription"`
+	OGImage       string     `json:"OGImage"`
+	CanonicalURL  string     `json:"CanonicalURL"`
+	PublishedAt   *time.Time `json:"PublishedAt"`
+	AnchorText    []string   `json:"AnchorText"`
 }
 
 type esErrorRes struct {
@@ -78,8 +107,9 @@ func (e esError) Error() string {
 var _ index.Indexer = (*ElasticSearchIndexer)(nil)
 
 type ElasticSearchIndexer struct {
-	es         *elasticsearch.Client
-	refreshOpt func(*esapi.UpdateRequest)
+	es               *elasticsearch.Client
+	refreshOpt       func(*esapi.UpdateRequest)
+	deleteRefreshOpt func(*esapi.DeleteRequest)
 }
 
 func NewElasticSearchIndexer(esNodes []string, syncUpdates bool) (*ElasticSearchIndexer, error) {
@@ -96,13 +126,16 @@ func NewElasticSearchIndexer(esNodes []string, syncUpdates bool) (*ElasticSearch
 	}
 
 	refreshOpt := es.Update.WithRefresh("false")
+	deleteRefreshOpt := es.Delete.WithRefresh("false")
 	if syncUpdates {
 		refreshOpt = es.Update.WithRefresh("true")
+		deleteRefreshOpt = es.Delete.WithRefresh("true")
 	}
 
 	return &ElasticSearchIndexer{
-		es:         es,
-		refreshOpt: refreshOpt,
+		es:               es,
+		refreshOpt:       refreshOpt,
+		deleteRefreshOpt: deleteRefreshOpt,
 	}, nil
 }
 
@@ -172,16 +205,34 @@ func (i *ElasticSearchIndexer) Search(q index.Query) (index.Iterator, error) {
 		qtype = "best_fields"
 	}
 
+	var matchQuery interface{} = map[string]interface{}{
+		"multi_match": map[string]interface{}{
+			"type":   qtype,
+			"query":  q.Expression,
+			"fields": []string{"Title^2", "Headings^1.5", "OGTitle", "Description", "OGDescription", "Keywords", "AnchorText^1.5", "Content"},
+		},
+	}
+	if q.Language != "" {
+		// Documents store normalized language codes; tags that do not
+		// name a language match nothing.
+		var filter interface{} = map[string]interface{}{"match_none": map[string]interface{}{}}
+		if lang := index.NormalizeLanguage(q.Language); lang != "" {
+			filter = map[string]interface{}{
+				"term": map[string]interface{}{"Language": lang},
+			}
+		}
+		matchQuery = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   matchQuery,
+				"filter": filter,
+			},
+		}
+	}
+
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
 			"function_score": map[string]interface{}{
-				"query": map[string]interface{}{
-					"multi_match": map[string]interface{}{
-						"type":   qtype,
-						"query":  q.Expression,
-						"fields": []string{"Title", "Content"},
-					},
-				},
+				"query": matchQuery,
 				"script_score": map[string]interface{}{
 					"script": map[string]interface{}{
 						"source": "_score + doc['PageRank'].value",
@@ -232,19 +283,86 @@ func (i *ElasticSearchIndexer) UpdateScore(linkID uuid.UUID, score float64) erro
 	return nil
 }
 
+// UpdateAnchorText replaces the inbound anchor text of an indexed document.
+// Documents that are not indexed are ignored.
+func (i *ElasticSearchIndexer) UpdateAnchorText(linkID uuid.UUID, anchors []string) error {
+	var buf bytes.Buffer
+	update := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"AnchorText": anchors,
+		},
+	}
+	if err := json.NewEncoder(&buf).Encode(update); err != nil {
+		return fmt.Errorf("update anchor text: %w", err)
+	}
+
+	res, err := i.es.Update(indexName, linkID.String(), &buf, i.refreshOpt)
+	if err != nil {
+		return fmt.Errorf("update anchor text: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return nil
+	}
+
+	var updateRes esUpdateRes
+	if err = unmarshalResponse(res, &updateRes); err != nil {
+		return fmt.Errorf("update anchor text: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the document with the specified link ID from the index.
+// Deleting a document that does not exist is not an error.
+func (i *ElasticSearchIndexer) Delete(linkID uuid.UUID) error {
+	res, err := i.es.Delete(indexName, linkID.String(), i.deleteRefreshOpt)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		_ = res.Body.Close()
+		return nil
+	}
+
+	var deleteRes esUpdateRes
+	if err = unmarshalResponse(res, &deleteRes); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	return nil
+}
+
 func ensureIndex(es *elasticsearch.Client) error {
-	mappingsReader := strings.NewReader(esMapping)
+	mappingsReader := strings.NewReader(`{"mappings": ` + esMapping + `}`)
 	res, err := es.Indices.Create(indexName, es.Indices.Create.WithBody(mappingsReader))
 	if err != nil {
 		return fmt.Errorf("cannot create ES index: %w", err)
 	} else if res.IsError() {
 		err := unmarshalError(res)
 		if esErr, valid := err.(esError); valid && esErr.Type == "resource_already_exists_exception" {
-			return nil
+			return updateMapping(es)
 		}
 		return fmt.Errorf("cannot create ES index: %w", err)
 	}
 
+	_ = res.Body.Close()
+	return nil
+}
+
+// updateMapping adds the fields that are missing from the mapping of an
+// existing index.
+func updateMapping(es *elasticsearch.Client) error {
+	res, err := es.Indices.PutMapping(strings.NewReader(esMapping), es.Indices.PutMapping.WithIndex(indexName))
+	if err != nil {
+		return fmt.Errorf("cannot update ES index mapping: %w", err)
+	} else if res.IsError() {
+		return fmt.Errorf("cannot update ES index mapping: %w", unmarshalError(res))
+	}
+
+	_ = res.Body.Close()
 	return nil
 }
 
@@ -293,22 +411,53 @@ func unmarshalResponse(res *esapi.Response, to interface{}) error {
 }
 
 func mapEsDoc(d *esDoc) *index.Document {
-	return &index.Document{
+	doc := &index.Document{
 		LinkID:    uuid.MustParse(d.LinkID),
 		URL:       d.URL,
 		Title:     d.Title,
 		Content:   d.Content,
 		IndexedAt: d.IndexedAt.UTC(),
 		PageRank:  d.PageRank,
+		Charset:   d.Charset,
+		Language:  d.Language,
+
+		Description:   d.Description,
+		Keywords:      d.Keywords,
+		Headings:      d.Headings,
+		OGTitle:       d.OGTitle,
+		OGDescription: d.OGDescription,
+		OGImage:       d.OGImage,
+		CanonicalURL:  d.CanonicalURL,
+		AnchorText:    d.AnchorText,
+	}
+	if d.PublishedAt != nil {
+		doc.PublishedAt = d.PublishedAt.UTC()
 	}
+	return doc
 }
 
 func makeEsDoc(d *index.Document) esDoc {
-	return esDoc{
+	doc := esDoc{
 		LinkID:    d.LinkID.String(),
 		URL:       d.URL,
 		Title:     d.Title,
 		Content:   d.Content,
 		IndexedAt: d.IndexedAt.UTC(),
+		Charset:   d.Charset,
+		Language:  d.Language,
+
+		Description:   d.Description,
+		Keywords:      d.Keywords,
+		Headings:      d.Headings,
+		OGTitle:       d.OGTitle,
+		OGDescription: d.OGDescription,
+		OGImage:       d.OGImage,
+		CanonicalURL:  d.CanonicalURL,
+		AnchorText:    d.AnchorText,
+	}
+	if !d.PublishedAt.IsZero() {
+		publishedAt := d.PublishedAt.UTC()
+		doc.PublishedAt = &publishedAt
 	}
+	return doc
 }